@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	certCAFrom    string
+	certCANoReuse bool
+	certIssueDays int
+)
+
+var certCmd = &cobra.Command{
+	Use:   "cert",
+	Short: "Manage a local CA and TLS certificates for mapped domains",
+}
+
+var certCACmd = &cobra.Command{
+	Use:   "ca",
+	Short: "Manage the local certificate authority",
+}
+
+var certCAInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create the local CA (reusing puma-dev's CA when present)",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		reuse := ""
+		if certCAFrom != "" {
+			if _, ok := internal.FindPumaDevCA(certCAFrom); !ok {
+				return fmt.Errorf("no CA (cert.pem, key.pem) found in %s", certCAFrom)
+			}
+			reuse = certCAFrom
+		} else if !certCANoReuse {
+			if dir, ok := internal.FindPumaDevCA(appConfig.PumaDevCADir, internal.DefaultPumaDevCADir()); ok {
+				reuse = dir
+			}
+		}
+		info, err := internal.InitCA(internal.CADir(), reuse, forceFlag)
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.CertView([]internal.CertInfo{*info}, true))
+		}
+		if !quietFlag {
+			f := internal.NewFormatter(cmd.OutOrStdout())
+			if reuse != "" {
+				f.Success("reused puma-dev CA from %s", reuse)
+			} else {
+				f.Success("created CA: %s", info.Subject)
+			}
+			f.KV("cert", info.CertPath)
+			f.KV("expires", info.NotAfter.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+var certIssueCmd = &cobra.Command{
+	Use:               "issue <domain>",
+	Short:             "Issue a certificate for <domain>.test and *.<domain>.test",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		domain, err := internal.NormalizeDomain(args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		ca, err := internal.LoadCA(internal.CADir())
+		if err != nil {
+			return err
+		}
+		validity := time.Duration(certIssueDays) * 24 * time.Hour
+		info, err := ca.Issue(domain, tldFlag, internal.CertsDir(), validity)
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.CertView([]internal.CertInfo{*info}, true))
+		}
+		if !quietFlag {
+			f := internal.NewFormatter(cmd.OutOrStdout())
+			f.Success("issued: %s", strings.Join(info.DNSNames, ", "))
+			f.KV("cert", info.CertPath)
+			f.KV("key", info.KeyPath)
+			f.KV("expires", info.NotAfter.Format(time.RFC3339))
+		}
+		return nil
+	},
+}
+
+var certListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List issued certificates",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		certs, err := internal.ListCerts(internal.CertsDir())
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.CertView(certs, false))
+		}
+		f := internal.NewFormatter(cmd.OutOrStdout())
+		if len(certs) == 0 {
+			if !quietFlag {
+				f.Info("no certificates issued")
+			}
+			return nil
+		}
+		for _, c := range certs {
+			line := c.Domain + "  " + strings.Join(c.DNSNames, ", ") + "  expires " + c.NotAfter.Format("2006-01-02")
+			if time.Now().After(c.NotAfter) {
+				f.Error("%s (expired)", line)
+				continue
+			}
+			f.Info("%s", line)
+		}
+		return nil
+	},
+}
+
+func init() {
+	certCAInitCmd.Flags().StringVar(&certCAFrom, "from", "", "directory holding a puma-dev CA (cert.pem, key.pem) to reuse")
+	certCAInitCmd.Flags().BoolVar(&certCANoReuse, "no-reuse", false, "always generate a new CA instead of reusing puma-dev's")
+	certIssueCmd.Flags().IntVar(&certIssueDays, "days", 825, "certificate validity in days")
+	certCACmd.AddCommand(certCAInitCmd)
+	certCmd.AddCommand(certCACmd, certIssueCmd, certListCmd)
+	rootCmd.AddCommand(certCmd)
+}
@@ -1,11 +1,8 @@
 package cmd
 
 import (
-	"bufio"
-	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
+	"sort"
 
 	"github.com/rolling-space/pumadevctl/internal"
 	"github.com/spf13/cobra"
@@ -13,30 +10,66 @@ import (
 
 var cleanupYes bool
 var cleanupDry bool
+var cleanupFilter filterFlags
+var cleanupAllContexts bool
 
 var cleanupCmd = &cobra.Command{
 	Use:   "cleanup",
 	Short: "Remove unreachable mappings (non-symlink)",
+	Long: "Remove unreachable mappings (non-symlink).\n\n" +
+		"With machine-readable output (-o json etc.) cleanup never prompts: without --yes or --force it only reports what would be deleted.",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dir, err := internal.ResolveDir(dirFlag)
+		r, err := newRenderer(cmd)
 		if err != nil {
 			return err
 		}
-		entries, err := internal.LoadEntries(dir)
+		filter, err := cleanupFilter.build(300)
 		if err != nil {
 			return err
 		}
-		results := internal.ValidateEntries(entries, 300)
+		entries, dirs, err := loadScope(cleanupAllContexts)
+		if err != nil {
+			return err
+		}
+		var results []internal.ValidationResult
+		if filter.NeedsReachability() {
+			results = filter.ApplyResults(internal.ValidateEntries(entries, 300))
+		} else {
+			results = internal.ValidateEntries(filter.Apply(entries), 300)
+		}
 		toDelete := []internal.Entry{}
 		for _, r := range results {
 			if !r.IsSymlink && !r.Reachable {
 				toDelete = append(toDelete, r.Entry)
 			}
 		}
-		if jsonFlag {
-			enc := json.NewEncoder(cmd.OutOrStdout())
-			enc.SetIndent("", "  ")
-			return enc.Encode(toDelete)
+		if !r.IsHuman() {
+			dry := cleanupDry || (!cleanupYes && !forceFlag)
+			if !dry {
+				release, err := lockDirs(dirs)
+				if err != nil {
+					return err
+				}
+				defer release()
+			}
+			res := internal.CleanupResult{DryRun: dry, Entries: make([]internal.CleanupItem, 0, len(toDelete))}
+			if dry {
+				for _, e := range toDelete {
+					res.Entries = append(res.Entries, internal.CleanupItem{Entry: e, Status: "pending"})
+				}
+				return internal.Render(r, internal.CleanupView(res))
+			}
+			err := cleanupDelete(cmd, dirs, toDelete, func(e internal.Entry, err error) {
+				item := internal.CleanupItem{Entry: e, Status: "deleted"}
+				if err != nil {
+					item.Status, item.Error = "failed", err.Error()
+				}
+				res.Entries = append(res.Entries, item)
+			})
+			if err != nil {
+				return err
+			}
+			return internal.Render(r, internal.CleanupView(res))
 		}
 		f := internal.NewFormatter(cmd.OutOrStdout())
 		if len(toDelete) == 0 {
@@ -47,35 +80,69 @@ var cleanupCmd = &cobra.Command{
 		}
 		f.Header("Unreachable entries")
 		for _, e := range toDelete {
-			f.Bullet(fmt.Sprintf("%s → %s", e.Domain, e.Mapping))
+			f.Bullet(fmt.Sprintf("%s → %s", e.QualifiedDomain(), e.Mapping))
 		}
 		if cleanupDry {
 			f.Warn("--dry-run set; no deletions performed.")
 			return nil
 		}
 		if !cleanupYes && !forceFlag {
-			fmt.Fprint(cmd.OutOrStdout(), "Delete these? [y/N]: ")
-			rdr := bufio.NewReader(os.Stdin)
-			line, _ := rdr.ReadString('\n')
-			if strings.ToLower(strings.TrimSpace(line)) != "y" {
+			if !confirm(cmd, "Delete these?") {
 				f.Warn("aborted")
 				return nil
 			}
 		}
 		// delete
-		for _, e := range toDelete {
-			if err := internal.DeleteEntry(dir, e.Domain); err != nil {
-				internal.NewFormatter(cmd.OutOrStdout()).Error("failed to delete %s: %v", e.Domain, err)
+		release, err := lockDirs(dirs)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return cleanupDelete(cmd, dirs, toDelete, func(e internal.Entry, err error) {
+			if err != nil {
+				f.Error("failed to delete %s: %v", e.QualifiedDomain(), err)
 			} else if !quietFlag {
-				f.Success("deleted: %s", e.Domain)
+				f.Success("deleted: %s", e.QualifiedDomain())
 			}
-		}
-		return nil
+		})
 	},
 }
 
+// cleanupDelete deletes entries one directory at a time, each batch between that directory's cleanup hooks,
+// and reports every deletion to done. A failing pre hook stops before its batch and is returned.
+func cleanupDelete(cmd *cobra.Command, dirs map[string]string, entries []internal.Entry, done func(internal.Entry, error)) error {
+	batches := map[string][]internal.Entry{}
+	var contexts []string
+	for _, e := range entries {
+		if _, ok := batches[e.Context]; !ok {
+			contexts = append(contexts, e.Context)
+		}
+		batches[e.Context] = append(batches[e.Context], e)
+	}
+	sort.Strings(contexts)
+	for _, name := range contexts {
+		dir, batch := dirs[name], batches[name]
+		changes := make([]internal.EntryChange, len(batch))
+		for i, e := range batch {
+			changes[i] = internal.NewEntryChange(e, "deleted")
+		}
+		err := withHooks(cmd, internal.EventCleanup, dir, changes, func() error {
+			for _, e := range batch {
+				done(e, internal.DeleteEntry(dir, e.Domain))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func init() {
 	cleanupCmd.Flags().BoolVar(&cleanupYes, "yes", false, "assume yes; do not prompt")
 	cleanupCmd.Flags().BoolVar(&cleanupDry, "dry-run", false, "show what would be deleted without doing it")
+	cleanupCmd.Flags().BoolVar(&cleanupAllContexts, "all-contexts", false, "clean up the entries of every context")
+	addFilterFlags(cleanupCmd, &cleanupFilter)
 	rootCmd.AddCommand(cleanupCmd)
 }
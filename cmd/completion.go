@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var completionInstallPath string
+
+var completionInstallCmd = &cobra.Command{
+	Use:   "install [bash|zsh|fish]",
+	Short: "Install the completion script where the shell loads it from",
+	Long: "Write the completion script for the shell (default: the one in $SHELL) to the per-user directory it\n" +
+		"loads completions from, replacing an earlier version:\n\n" +
+		"  bash  ~/.local/share/bash-completion/completions/pumadevctl (needs the bash-completion package)\n" +
+		"  zsh   ~/.local/share/zsh/site-functions/_pumadevctl (add the directory to $fpath before compinit)\n" +
+		"  fish  ~/.config/fish/completions/pumadevctl.fish\n\n" +
+		"XDG_DATA_HOME, XDG_CONFIG_HOME and BASH_COMPLETION_USER_DIR move these; --path writes elsewhere.",
+	Args:      cobra.MaximumNArgs(1),
+	ValidArgs: internal.CompletionShells,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		shell := internal.DetectShell()
+		if len(args) == 1 {
+			shell = args[0]
+		}
+		if shell == "" {
+			return internal.Errorf(internal.CodeUsage, "cannot tell the shell from $SHELL; name it: %s", strings.Join(internal.CompletionShells, ", "))
+		}
+		path, err := internal.CompletionPath(shell)
+		if err != nil {
+			return err
+		}
+		if completionInstallPath != "" {
+			path = completionInstallPath
+		}
+		var script bytes.Buffer
+		switch shell {
+		case "bash":
+			err = rootCmd.GenBashCompletionV2(&script, true)
+		case "zsh":
+			err = rootCmd.GenZshCompletion(&script)
+		case "fish":
+			err = rootCmd.GenFishCompletion(&script, true)
+		}
+		if err != nil {
+			return err
+		}
+		if err := internal.InstallCompletion(path, script.Bytes()); err != nil {
+			return err
+		}
+		res := internal.CompletionInstall{Shell: shell, Path: path, Hint: internal.CompletionHint(shell, path)}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.CompletionInstallView(res))
+		}
+		if !quietFlag {
+			f := internal.NewFormatter(cmd.OutOrStdout())
+			f.Success("installed %s completion: %s", shell, path)
+			if res.Hint != "" {
+				f.Info("%s", res.Hint)
+			} else {
+				f.Info("open a new shell to use it")
+			}
+		}
+		return nil
+	},
+}
+
+// addCompletionInstall adds `install` to cobra's default completion command, which cobra only creates when
+// the command runs.
+func addCompletionInstall() {
+	rootCmd.InitDefaultCompletionCmd()
+	for _, c := range rootCmd.Commands() {
+		if c.Name() == "completion" {
+			c.Long += "\nUse `" + rootCmd.Name() + " completion install` to install the script for your shell.\n"
+			c.AddCommand(completionInstallCmd)
+		}
+	}
+}
+
+// completionConfig resolves the config for a completion request: cobra parses the flags on the command
+// line but skips PersistentPreRunE.
+func completionConfig(cmd *cobra.Command) bool {
+	return loadConfig(cmd) == nil
+}
+
+// completionDir resolves the config and the mappings dir as the completed command will, so leases and
+// other contexts' blocks, keyed by the absolute dir, are recognised.
+func completionDir(cmd *cobra.Command) (string, bool) {
+	if !completionConfig(cmd) {
+		return "", false
+	}
+	dir, err := internal.ResolveDir(dirFlag)
+	return dir, err == nil
+}
+
+// completeDomain completes the domain argument of commands taking one first.
+func completeDomain(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return domainCompletions(cmd, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// domainCompletions lists the entries of the mappings dir starting with toComplete, described by their
+// mapping or link target.
+func domainCompletions(cmd *cobra.Command, toComplete string) []string {
+	dir, ok := completionDir(cmd)
+	if !ok {
+		return nil
+	}
+	entries, err := internal.LoadEntries(dir)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, e := range entries {
+		if !strings.HasPrefix(e.Domain, toComplete) {
+			continue
+		}
+		target := e.Mapping
+		if e.IsSymlink {
+			target = "→ " + e.LinkTarget
+		}
+		out = append(out, e.Domain+"\t"+target)
+	}
+	return out
+}
+
+// completeExec completes the domain, then leaves the command and its arguments to the shell.
+func completeExec(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveDefault
+	}
+	return domainCompletions(cmd, toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeCreate suggests the base port of the block `create <domain>` would allocate for the mapping.
+func completeCreate(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) != 1 || createLinkTarget != "" {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	dir, ok := completionDir(cmd)
+	if !ok {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	domain, err := internal.NormalizeDomain(args[0], tldFlag)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	entries, err := internal.LoadEntries(dir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	alloc, err := newAllocator(dir)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	base, err := alloc.Allocate(domain, entries)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return []string{strconv.Itoa(base) + "\tnext free block " + strconv.Itoa(base) + "-" + strconv.Itoa(base+portBlockSize-1)},
+		cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeContext completes the names of the declared contexts.
+func completeContext(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 || !completionConfig(cmd) {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []string
+	for _, c := range appConfig.ContextList() {
+		out = append(out, c.Name+"\t"+c.Dir)
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeOutput completes -o. template= takes its TEXT right after the "=", so no space follows it.
+func completeOutput(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var out []string
+	for _, f := range internal.OutputFormats {
+		if strings.HasPrefix(f, toComplete) {
+			out = append(out, f)
+		}
+	}
+	if len(out) == 1 && strings.HasSuffix(out[0], "=") {
+		return out, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeProfile(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return internal.ProfileNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeContextFlag(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completeContext(cmd, nil, toComplete)
+}
+
+func completeDirs(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return nil, cobra.ShellCompDirectiveFilterDirs
+}
+
+func init() {
+	completionInstallCmd.Flags().StringVar(&completionInstallPath, "path", "", "write the script to this file instead")
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+func TestCompleteCreate_RelativeDir(t *testing.T) {
+	root := isolateCLI(t)
+	dir := filepath.Join(root, "puma-dev")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	err := internal.UpdateLedger(internal.LedgerPath(), func(l *internal.Ledger) bool {
+		l.Reserve(dir, "app", 36500, 10, time.Now())
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, root)
+
+	// the lease is keyed by the absolute dir, as create resolves --dir
+	out, err := runCLI(t, "__complete", "create", "--dir", "puma-dev", "app", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(out, "36500\t") {
+		t.Errorf("expected app's leased block 36500 to be suggested, got:\n%s", out)
+	}
+}
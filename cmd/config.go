@@ -0,0 +1,521 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configShowOrigin bool
+	configSystem     bool
+	configStrict     bool
+	configConvertTo  string
+	configFormatFlag string
+
+	// configErr is why the config could not be resolved; config subcommands still run so they can repair it.
+	configErr error
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect and edit pumadevctl's configuration",
+	Long: "Inspect and edit pumadevctl's configuration.\n\n" +
+		"Values are resolved in layers, later ones winning: built-in defaults, the system config\n" +
+		"(" + internal.SystemConfigPath() + "), the user config (" + internal.ConfigPath() + "),\n" +
+		"the project's .pumadev.yml, " + internal.EnvPrefix + "<KEY> environment variables and flags.\n" +
+		"--profile NAME (or " + internal.EnvPrefix + "PROFILE) applies the \"profiles\" entry NAME of each config file\n" +
+		"on top of that file's own values.\n\n" +
+		"The config file may be config.json, config.yml/config.yaml or config.toml; only one may exist per directory.\n" +
+		"set, unset, edit and init write the user config (the system config with --system), inside the selected\n" +
+		"profile if there is one. Writes are atomic and keep keys pumadevctl does not know.",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupOutput(cmd); err != nil {
+			return err
+		}
+		configErr = loadConfig(cmd)
+		return nil
+	},
+}
+
+var configShowCmd = &cobra.Command{
+	Use:     "show",
+	Short:   "Print the effective configuration",
+	Example: "  pumadevctl config show --origin\n  pumadevctl --profile work config show -o json",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		if configErr != nil {
+			return configErr
+		}
+		if r.IsHuman() && appConfig.Profile != "" && !quietFlag {
+			internal.NewFormatter(cmd.OutOrStdout()).KV("profile", appConfig.Profile)
+		}
+		return internal.Render(r, internal.ConfigView(appConfig.Values, configShowOrigin))
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the effective value of a config key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		if configErr != nil {
+			return configErr
+		}
+		for _, v := range appConfig.Values {
+			if v.Key != args[0] {
+				continue
+			}
+			if !r.IsHuman() {
+				return internal.Render(r, internal.ConfigValueView(v))
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), v.Value)
+			return nil
+		}
+		return internal.Errorf(internal.CodeUsage, "unknown config key %q (known: %s)", args[0], strings.Join(internal.ConfigKeys(), ", "))
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:     "set <key> <value>",
+	Short:   "Write a config key",
+	Long:    "Write a config key. The value is checked against the rest of the file; --force writes it anyway.",
+	Example: "  pumadevctl config set port_min 40000\n  pumadevctl --profile work config set dir ~/work/.puma-dev",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editConfigKey(cmd, args[0], func(f *internal.ConfigFile) (string, error) {
+			return "set", f.Set(args[0], args[1])
+		})
+	},
+}
+
+var configUnsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a config key, falling back to lower layers",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return editConfigKey(cmd, args[0], func(f *internal.ConfigFile) (string, error) {
+			removed, err := f.Unset(args[0])
+			if !removed {
+				return "unchanged", err
+			}
+			return "unset", err
+		})
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edit the config file in $VISUAL or $EDITOR",
+	Long: "Edit the config file in $VISUAL or $EDITOR. The edit happens on a copy that replaces the file only\n" +
+		"when it parses and validates; otherwise the copy is kept and its path printed.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		f, err := openConfigTarget()
+		if err != nil {
+			return err
+		}
+		b, err := f.Bytes()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(f.Path), "."+filepath.Base(f.Path)+".edit*"+filepath.Ext(f.Path))
+		if err != nil {
+			return err
+		}
+		_, err = tmp.Write(b)
+		if cerr := tmp.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		if err := runEditor(tmp.Name()); err != nil {
+			os.Remove(tmp.Name())
+			return err
+		}
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		err = f.Replace(edited)
+		if err == nil {
+			err = checkConfigFile(cmd, f)
+		}
+		if err != nil {
+			return internal.Errorf(internal.CodeOf(err), "%v; your edit is kept in %s", err, tmp.Name())
+		}
+		os.Remove(tmp.Name())
+		if err := f.Save(); err != nil {
+			return err
+		}
+		return renderConfigFile(cmd, r, f, "saved")
+	},
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the effective configuration and the config files",
+	Long: "Check every key of the effective configuration (port ranges, block size, port roles, TLD, directories)\n" +
+		"and report unknown keys in the config files. Exits with the validation_failed code on errors, and on\n" +
+		"warnings too with --strict.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		if configErr != nil {
+			return configErr
+		}
+		issues := appConfig.Validate()
+		for _, layer := range []struct{ name, dir string }{{internal.OriginSystem, internal.SystemConfigDir()}, {internal.OriginUser, internal.XDGConfigDir()}} {
+			path, err := internal.FindConfigFile(layer.dir)
+			if err != nil {
+				return err
+			}
+			f, err := internal.OpenConfigFile(path, "")
+			if err != nil {
+				return err
+			}
+			for _, k := range f.UnknownKeys() {
+				issues = append(issues, internal.ConfigIssue{Key: k, Severity: internal.SeverityWarning,
+					Message: "unknown key, ignored", Origin: layer.name, Source: path})
+			}
+		}
+		rep := internal.NewConfigReport(appConfig.Profile, issues)
+		if !r.IsHuman() {
+			if err := internal.Render(r, internal.ConfigReportView(rep)); err != nil {
+				return err
+			}
+		} else {
+			printConfigIssues(cmd, rep.Issues)
+			if rep.Errors == 0 && rep.Warnings == 0 && !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("config ok")
+			}
+		}
+		if rep.Errors > 0 || configStrict && rep.Warnings > 0 {
+			return internal.Errorf(internal.CodeValidationFailed, "config invalid: %d errors, %d warnings", rep.Errors, rep.Warnings)
+		}
+		return nil
+	},
+}
+
+var configPathCmd = &cobra.Command{
+	Use:     "path",
+	Short:   "Print the path of the config file set, unset and edit write",
+	Example: "  $EDITOR \"$(pumadevctl config path)\"",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		path, err := internal.FindConfigFile(configDir())
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			_, statErr := os.Stat(path)
+			return internal.Render(r, configFileView(&internal.ConfigFile{Path: path, Profile: configProfile(), Exists: statErr == nil}))
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), path)
+		return nil
+	},
+}
+
+var configConvertCmd = &cobra.Command{
+	Use:   "convert --to json|yaml|toml",
+	Short: "Rewrite the config file in another format",
+	Long: "Rewrite the config file in another format (config.json, config.yaml or config.toml) and remove the old\n" +
+		"file. YAML and TOML reject unknown keys, so a JSON file with keys pumadevctl does not know is only\n" +
+		"converted with --force, which drops them.",
+	Example: "  pumadevctl config convert --to yaml",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		name, err := internal.ConfigFileName(configConvertTo)
+		if err != nil {
+			return err
+		}
+		path, err := internal.FindConfigFile(configDir())
+		if err != nil {
+			return err
+		}
+		f, err := internal.OpenConfigFile(path, "")
+		if err != nil {
+			return err
+		}
+		if !f.Exists {
+			return internal.Errorf(internal.CodeNotFound, "no config file in %s to convert", configDir())
+		}
+		out := f.As(filepath.Join(configDir(), name))
+		if out.Path == f.Path {
+			return renderConfigFile(cmd, r, f, "already "+f.Format()+":")
+		}
+		if unknown := f.UnknownKeys(); len(unknown) > 0 && out.Format() != internal.FormatJSON {
+			if !forceFlag {
+				return internal.Errorf(internal.CodeValidationFailed, "%s has unknown keys %s that %s would reject (use --force to drop them)",
+					f.Path, strings.Join(unknown, ", "), out.Format())
+			}
+			out.DropUnknownKeys()
+		}
+		if _, err := out.Candidate(); err != nil {
+			return err
+		}
+		if err := f.Convert(out); err != nil {
+			return err
+		}
+		return renderConfigFile(cmd, r, out, "converted "+f.Path+" to")
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a config file with every key set to its default",
+	Long: "Write a config file with every key set to its default. An existing file is only replaced with --force.\n" +
+		"Profiles are added with `config set --profile NAME`.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		name, err := internal.ConfigFileName(configFormatFlag)
+		if err != nil {
+			return err
+		}
+		existing, err := internal.FindConfigFile(configDir())
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(existing); err == nil && !forceFlag {
+			return internal.Errorf(internal.CodeAlreadyExists, "%s already exists (use --force to overwrite)", existing)
+		}
+		f := &internal.ConfigFile{Path: filepath.Join(configDir(), name)}
+		if err := f.Replace(nil); err != nil {
+			return err
+		}
+		def := internal.DefaultAppConfig()
+		for _, key := range internal.ConfigKeys() {
+			if v := def.Value(key); v != "" {
+				if err := f.Set(key, v); err != nil {
+					return err
+				}
+			}
+		}
+		if err := f.Save(); err != nil {
+			return err
+		}
+		if existing != f.Path {
+			if err := os.Remove(existing); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		return renderConfigFile(cmd, r, f, "created")
+	},
+}
+
+// editConfigKey applies change to the target config file, checks the result and saves it.
+func editConfigKey(cmd *cobra.Command, key string, change func(*internal.ConfigFile) (string, error)) error {
+	r, err := newRenderer(cmd)
+	if err != nil {
+		return err
+	}
+	f, err := openConfigTarget()
+	if err != nil {
+		return err
+	}
+	prev, _, err := f.Get(key)
+	if err != nil {
+		return err
+	}
+	status, err := change(f)
+	if err != nil {
+		return err
+	}
+	if status != "unchanged" {
+		if err := checkConfigFile(cmd, f); err != nil {
+			return err
+		}
+		if err := f.Save(); err != nil {
+			return err
+		}
+	}
+	value, _, _ := f.Get(key)
+	res := internal.ConfigChange{Key: key, Status: status, Value: value, Previous: prev, File: f.Path, Profile: f.Profile}
+	if !r.IsHuman() {
+		return internal.Render(r, internal.ConfigChangeView(res))
+	}
+	if quietFlag {
+		return nil
+	}
+	fm := internal.NewFormatter(cmd.OutOrStdout())
+	switch status {
+	case "set":
+		fm.Success("set %s = %s in %s", key, value, configFileLabel(f))
+	case "unset":
+		fm.Success("unset %s in %s (was %s)", key, configFileLabel(f), prev)
+	default:
+		fm.Info("%s is not set in %s", key, configFileLabel(f))
+	}
+	if appConfig == nil {
+		return nil
+	}
+	for _, v := range appConfig.Values {
+		if v.Key == key && (v.Origin == internal.OriginEnv || v.Origin == internal.OriginFlag || v.Origin == internal.OriginProject) {
+			internal.NewFormatter(cmd.ErrOrStderr()).Warn("%s is overridden by %s", key, internal.ConfigOrigin(v))
+		}
+	}
+	return nil
+}
+
+// checkConfigFile validates the config f produces on its own. Errors refuse the write unless --force;
+// warnings are printed.
+func checkConfigFile(cmd *cobra.Command, f *internal.ConfigFile) error {
+	cfg, err := f.Candidate()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, issue := range cfg.Validate() {
+		if issue.Severity == internal.SeverityError {
+			errs = append(errs, issue.Key+": "+issue.Message)
+		} else if !quietFlag {
+			internal.NewFormatter(cmd.ErrOrStderr()).Warn("%s: %s", issue.Key, issue.Message)
+		}
+	}
+	if len(errs) > 0 && !forceFlag {
+		return internal.Errorf(internal.CodeValidationFailed, "invalid config (use --force to write it anyway): %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// configDir is the directory of the config file set, unset, edit, init and convert write.
+func configDir() string {
+	if configSystem {
+		return internal.SystemConfigDir()
+	}
+	return internal.XDGConfigDir()
+}
+
+// configProfile is the profile section set, unset and edit work on.
+func configProfile() string {
+	if profileFlag != "" {
+		return profileFlag
+	}
+	return os.Getenv(internal.EnvPrefix + "PROFILE")
+}
+
+// openConfigTarget opens the config file set, unset, edit and convert write.
+func openConfigTarget() (*internal.ConfigFile, error) {
+	path, err := internal.FindConfigFile(configDir())
+	if err != nil {
+		return nil, err
+	}
+	return internal.OpenConfigFile(path, configProfile())
+}
+
+func configLayer() string {
+	if configSystem {
+		return internal.OriginSystem
+	}
+	return internal.OriginUser
+}
+
+func configFileLabel(f *internal.ConfigFile) string {
+	if f.Profile != "" {
+		return f.Path + " (profile " + f.Profile + ")"
+	}
+	return f.Path
+}
+
+func renderConfigFile(cmd *cobra.Command, r *internal.Renderer, f *internal.ConfigFile, verb string) error {
+	if !r.IsHuman() {
+		return internal.Render(r, configFileView(f))
+	}
+	if !quietFlag {
+		internal.NewFormatter(cmd.OutOrStdout()).Success("%s %s", verb, configFileLabel(f))
+	}
+	return nil
+}
+
+func configFileView(f *internal.ConfigFile) internal.View[internal.ConfigFileInfo] {
+	return internal.ConfigFileView(internal.ConfigFileInfo{Path: f.Path, Format: f.Format(), Layer: configLayer(), Exists: f.Exists, Profile: f.Profile})
+}
+
+func printConfigIssues(cmd *cobra.Command, issues []internal.ConfigIssue) {
+	f := internal.NewFormatter(cmd.OutOrStdout())
+	for _, i := range issues {
+		line := i.Key + ": " + i.Message
+		if i.Origin != "" {
+			line += " [" + strings.TrimSpace(i.Origin+" "+i.Source) + "]"
+		}
+		if i.Severity == internal.SeverityError {
+			f.Error("%s", line)
+		} else {
+			f.Warn("%s", line)
+		}
+	}
+}
+
+// runEditor opens path in $VISUAL, $EDITOR or a platform default and waits for it to exit.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	fields := strings.Fields(editor)
+	c := exec.Command(fields[0], append(fields[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return internal.Errorf(internal.CodeNotFound, "editor %q not found; set $VISUAL or $EDITOR", fields[0])
+		}
+		return internal.Errorf(internal.CodeError, "editor %s: %v", fields[0], err)
+	}
+	return nil
+}
+
+func init() {
+	configShowCmd.Flags().BoolVar(&configShowOrigin, "origin", false, "show where each value came from (default, system, user, project, env or flag)")
+	configConvertCmd.Flags().StringVar(&configConvertTo, "to", "", "target format: json, yaml or toml")
+	_ = configConvertCmd.MarkFlagRequired("to")
+	configInitCmd.Flags().StringVar(&configFormatFlag, "format", internal.FormatJSON, "file format: json, yaml or toml")
+	configValidateCmd.Flags().BoolVar(&configStrict, "strict", false, "fail on warnings too")
+	configCmd.PersistentFlags().BoolVar(&configSystem, "system", false, "write the system config ("+internal.SystemConfigPath()+") instead of the user config")
+	configCmd.AddCommand(configShowCmd, configGetCmd, configSetCmd, configUnsetCmd, configEditCmd, configValidateCmd, configPathCmd, configInitCmd, configConvertCmd)
+	rootCmd.AddCommand(configCmd)
+}
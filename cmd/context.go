@@ -0,0 +1,217 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage named mappings directories (one per puma-dev instance)",
+	Long: "Manage named mappings directories, for running several puma-dev instances (e.g. one per Ruby version)\n" +
+		"side by side. Contexts are declared under \"contexts\" in the user config; the \"context\" key, --context\n" +
+		"or PUMADEVCTL_CONTEXT selects the one commands work on, and --all-contexts makes list, validate and\n" +
+		"cleanup cover every context. Port allocation avoids the blocks of every context's entries, since all\n" +
+		"instances share the host's ports.",
+	// like config, context must run with a broken config so it can repair an unknown context
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupOutput(cmd); err != nil {
+			return err
+		}
+		configErr = loadConfig(cmd)
+		return nil
+	},
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the declared contexts, marking the current one",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		if configErr != nil {
+			return configErr
+		}
+		contexts := appConfig.ContextList()
+		if len(contexts) == 0 && r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Info("no contexts declared; add one with `pumadevctl context add NAME DIR`")
+			}
+			return nil
+		}
+		return internal.Render(r, internal.ContextView(contexts))
+	},
+}
+
+var contextAddCmd = &cobra.Command{
+	Use:     "add <name> <dir>",
+	Short:   "Declare a context in the user config",
+	Long:    "Declare a context in the user config, or point an existing one at another directory.\nThe directory must exist unless --force is given.",
+	Example: "  pumadevctl context add ruby2 ~/.puma-dev-ruby2\n  pumadevctl --context ruby2 list",
+	Args:    cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		name := args[0]
+		if err := internal.ValidateContextName(name); err != nil {
+			return err
+		}
+		dir, err := filepath.Abs(args[1])
+		if err != nil {
+			return internal.WithCode(internal.CodeUsage, err)
+		}
+		if fi, err := os.Stat(dir); (err != nil || !fi.IsDir()) && !forceFlag {
+			return internal.Errorf(internal.CodeNotFound, "directory %s does not exist (use --force to add it anyway)", dir)
+		}
+		path, err := internal.FindConfigFile(internal.XDGConfigDir())
+		if err != nil {
+			return err
+		}
+		f, err := internal.OpenConfigFile(path, "")
+		if err != nil {
+			return err
+		}
+		prev, err := f.SetContext(name, dir)
+		if err != nil {
+			return err
+		}
+		status := "set"
+		if prev == dir {
+			status = "unchanged"
+		} else if err := f.Save(); err != nil {
+			return err
+		}
+		res := internal.ConfigChange{Key: "contexts." + name + ".dir", Status: status, Value: dir, Previous: prev, File: f.Path}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.ConfigChangeView(res))
+		}
+		if !quietFlag {
+			internal.NewFormatter(cmd.OutOrStdout()).Success("context %s → %s in %s", name, dir, f.Path)
+		}
+		return nil
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Make a context the current one (sets the \"context\" config key)",
+	Long: "Make a context the current one by setting the \"context\" key in the user config (in the profile's\n" +
+		"section with --profile). `pumadevctl config unset context` goes back to the plain dir key.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeContext,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if appConfig == nil {
+			return configErr
+		}
+		if _, ok := appConfig.Contexts[args[0]]; !ok {
+			return internal.Errorf(internal.CodeNotFound, "unknown context %q: add it with `pumadevctl context add %s DIR`", args[0], args[0])
+		}
+		return editConfigKey(cmd, "context", func(f *internal.ConfigFile) (string, error) {
+			return "set", f.Set("context", args[0])
+		})
+	},
+}
+
+// loadScope loads the entries commands work on: the mappings dir's, or with all those of every declared
+// context, each recording its context. dirs maps the entries' contexts to their directories.
+func loadScope(all bool) (entries []internal.Entry, dirs map[string]string, err error) {
+	if !all {
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries, err := internal.LoadEntries(dir)
+		return entries, map[string]string{"": dir}, err
+	}
+	contexts := appConfig.ContextList()
+	if len(contexts) == 0 {
+		return nil, nil, internal.Errorf(internal.CodeConfig, "--all-contexts: no contexts declared; add one with `pumadevctl context add NAME DIR`")
+	}
+	dirs = map[string]string{}
+	for _, c := range contexts {
+		dirs[c.Name] = c.Dir
+	}
+	entries, err = internal.LoadContextEntries(contexts)
+	return entries, dirs, err
+}
+
+// contextConflicts finds the entries whose port blocks overlap those of another context. Outside
+// --all-contexts the other contexts are loaded just for the check and the entries count as the current one.
+func contextConflicts(entries []internal.Entry, dirs map[string]string) []internal.PortConflict {
+	dir, single := dirs[""]
+	if !single {
+		return internal.ContextConflicts(entries, portBlockSize, "")
+	}
+	current := "current"
+	var others []internal.ContextInfo
+	for _, c := range appConfig.ContextList() {
+		if filepath.Clean(c.Dir) == dir {
+			current = c.Name
+		} else if c.Exists {
+			others = append(others, c)
+		}
+	}
+	if len(others) == 0 {
+		return nil
+	}
+	all, err := internal.LoadContextEntries(others)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		e.Context = current
+		all = append(all, e)
+	}
+	return internal.ContextConflicts(all, portBlockSize, current)
+}
+
+// printContextConflicts reports conflicts as warnings, on stderr in machine output modes.
+func printContextConflicts(cmd *cobra.Command, conflicts []internal.PortConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	f := internal.NewFormatter(promptWriter(cmd))
+	f.Subheader("Port conflicts between contexts")
+	for _, c := range conflicts {
+		f.Warn("%s", c.Message)
+	}
+}
+
+// lockDirs locks every directory of dirs, in a fixed order, releasing them all on error or when the returned
+// func is called.
+func lockDirs(dirs map[string]string) (func(), error) {
+	sorted := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+	var releases []func()
+	release := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, dir := range sorted {
+		r, err := internal.LockDir(dir)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		releases = append(releases, r)
+	}
+	return release, nil
+}
+
+func init() {
+	contextCmd.AddCommand(contextListCmd, contextAddCmd, contextUseCmd)
+	rootCmd.AddCommand(contextCmd)
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
-	"fmt"
 	"strconv"
 
 	"github.com/rolling-space/pumadevctl/internal"
@@ -13,65 +11,133 @@ var createLinkTarget string
 var createAuto bool
 
 var createCmd = &cobra.Command{
-	Use:   "create <domain> [mapping]",
-	Short: "Create a new entry (mapping file or symlink)",
-	Args:  cobra.RangeArgs(1, 2),
+	Use:               "create <domain> [mapping]",
+	Short:             "Create a new entry (mapping file or symlink)",
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeCreate,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
 		dir, err := internal.ResolveDir(dirFlag)
 		if err != nil {
 			return err
 		}
-		domain := args[0]
+		domain, err := internal.NormalizeDomain(args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		release, err := internal.LockDir(dir)
+		if err != nil {
+			return err
+		}
+		defer release()
 		// If --link is set, create symlink and ignore mapping args
 		if createLinkTarget != "" {
-			if err := internal.CreateSymlink(dir, domain, createLinkTarget, forceFlag); err != nil {
+			res := internal.EntryChange{Domain: domain, Status: "created", Type: "symlink", LinkTarget: createLinkTarget}
+			err := withHooks(cmd, internal.EventCreate, dir, []internal.EntryChange{res}, func() error {
+				return internal.CreateSymlink(dir, domain, createLinkTarget, forceFlag)
+			})
+			if err != nil {
 				return err
 			}
-			if !quietFlag && !jsonFlag {
-				internal.NewFormatter(cmd.OutOrStdout()).Success("created symlink: %s → %s", domain, createLinkTarget)
-			}
-			if jsonFlag {
-				out := map[string]string{"domain": domain, "link_target": createLinkTarget, "type": "symlink"}
-				b, _ := json.MarshalIndent(out, "", "  ")
-				fmt.Fprintln(cmd.OutOrStdout(), string(b))
+			if r.IsHuman() {
+				if !quietFlag {
+					internal.NewFormatter(cmd.OutOrStdout()).Success("created symlink: %s → %s", domain, createLinkTarget)
+				}
+				return nil
 			}
-			return nil
+			return internal.Render(r, internal.EntryChangeView(res))
 		}
-		mapping := ""
+		var mapping string
 		if len(args) == 2 {
 			mapping = args[1]
 			if _, err := internal.ParseMapping(mapping); err != nil {
+				return internal.WithCode(internal.CodeUsage, err)
+			}
+			change := internal.EntryChange{Domain: domain, Status: "created", Type: "file", Mapping: mapping}
+			err := withHooks(cmd, internal.EventCreate, dir, []internal.EntryChange{change}, func() error {
+				if err := internal.WriteEntry(dir, domain, mapping, forceFlag); err != nil {
+					return err
+				}
+				return recordLease(cmd, dir, domain, mapping)
+			})
+			if err != nil {
 				return err
 			}
 		} else {
 			// auto port if not provided or --auto: allocate first available block within configured range
-			entries, err := internal.LoadEntries(dir)
-			if err != nil {
+			if mapping, err = createAutoEntry(cmd, internal.EventCreate, dir, domain, forceFlag); err != nil {
 				return err
 			}
-			p, err := internal.FindNextAvailablePortBlock(entries, portMinFlag, portMaxFlag, portBlockSize)
-			if err != nil {
-				return err
+		}
+		res := internal.EntryChange{Domain: domain, Status: "created", Type: "file", Mapping: mapping}
+		if r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("created: %s → %s", domain, mapping)
 			}
-			mapping = strconv.Itoa(p)
+			return nil
 		}
-		if err := internal.WriteEntry(dir, domain, mapping, forceFlag); err != nil {
+		return internal.Render(r, internal.EntryChangeView(res))
+	},
+}
+
+// createAutoEntry allocates and leases a port block for domain in one ledger update, then writes the entry
+// and records the configured port roles right away so they never shift. The event's hooks run once the
+// block is known; the lease is rolled back when the entry is not written. The caller holds the directory
+// lock.
+func createAutoEntry(cmd *cobra.Command, event, dir, domain string, overwrite bool) (string, error) {
+	roles, err := internal.ParsePortRoles(portRolesFlag, portBlockSize)
+	if err != nil {
+		return "", err
+	}
+	entries, err := internal.LoadEntries(dir)
+	if err != nil {
+		return "", err
+	}
+	alloc, err := newAllocator(dir)
+	if err != nil {
+		return "", err
+	}
+	lease, undo, err := leaseBlock(cmd, alloc, domain, func() (int, error) {
+		return allocate(cmd, alloc, domain, entries)
+	})
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(lease.Base), writeLeasedEntry(cmd, event, dir, domain, lease, undo, overwrite, roles)
+}
+
+// writeLeasedEntry writes domain's entry for the block just leased to it, through the event's hooks, and
+// records roles when there are any. undo rolls the lease back when the entry is not written.
+func writeLeasedEntry(cmd *cobra.Command, event, dir, domain string, lease internal.Lease, undo func(), overwrite bool, roles []internal.PortRole) error {
+	mapping := strconv.Itoa(lease.Base)
+	change := internal.EntryChange{Domain: domain, Status: "created", Type: "file", Mapping: mapping}
+	if event == internal.EventUpdate {
+		change.Status = "updated"
+	}
+	written := false
+	err := withHooks(cmd, event, dir, []internal.EntryChange{change}, func() error {
+		if err := internal.WriteEntry(dir, domain, mapping, overwrite); err != nil {
 			return err
 		}
-		if !quietFlag && !jsonFlag {
-			internal.NewFormatter(cmd.OutOrStdout()).Success("created: %s → %s", domain, mapping)
-		}
-		if jsonFlag {
-			out := map[string]string{"domain": domain, "mapping": mapping, "type": "file"}
-			b, _ := json.MarshalIndent(out, "", "  ")
-			fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		written = true
+		if roles == nil {
+			return nil
 		}
-		return nil
-	},
+		_, err := internal.AssignDomainPorts(dir, domain, portBlockSize, roles, false)
+		return err
+	})
+	if err != nil && !written {
+		undo()
+	}
+	return err
 }
 
 func init() {
 	createCmd.Flags().StringVar(&createLinkTarget, "link", "", "create a symlink entry pointing to this path instead of a port mapping")
+	_ = createCmd.RegisterFlagCompletionFunc("link", completeDirs)
 	createCmd.Flags().BoolVar(&createAuto, "auto", true, "auto-pick a free port when mapping is omitted")
 	rootCmd.AddCommand(createCmd)
 }
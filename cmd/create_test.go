@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+// leaseOf returns the ledger lease of domain in dir.
+func leaseOf(t *testing.T, dir, domain string) (internal.Lease, bool) {
+	t.Helper()
+	l, err := internal.LoadLedger(internal.LedgerPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l.Lookup(dir, domain)
+}
+
+func TestCreate_Leases(t *testing.T) {
+	root := isolateCLI(t)
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	for _, d := range []string{a, b} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := runCLI(t, "create", "--dir", a, "app", "-o", "json"); err != nil {
+		t.Fatal(err)
+	}
+	first, ok := leaseOf(t, a, "app")
+	if !ok {
+		t.Fatal("create should lease the allocated block")
+	}
+	if _, err := runCLI(t, "create", "--dir", b, "web", "-o", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if second, ok := leaseOf(t, b, "web"); !ok || second.Base == first.Base {
+		t.Errorf("another dir got %+v, overlapping %+v", second, first)
+	}
+
+	if _, err := runCLI(t, "create", "--dir", b, "remote", "10.0.0.5:36100", "-o", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if ls, ok := leaseOf(t, b, "remote"); ok {
+		t.Errorf("a mapping to another host takes no local ports, got %+v", ls)
+	}
+
+	if _, err := runCLI(t, "create", "--dir", b, "clash", strconv.Itoa(first.Base), "-o", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if ls, ok := leaseOf(t, b, "clash"); ok {
+		t.Errorf("a block overlapping app's lease must not be leased again, got %+v", ls)
+	}
+}
+
+func TestCreate_PreHookRollsBackLease(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	root := isolateCLI(t)
+	dir := filepath.Join(root, "puma-dev")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	hook := filepath.Join(root, "deny")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(root, "config", "pumadevctl", "config.json")
+	if err := os.MkdirAll(filepath.Dir(config), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte(`{"hooks":{"pre_create":["`+hook+`"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runCLI(t, "create", "--dir", dir, "app", "-o", "json")
+	if internal.CodeOf(err) != internal.CodeHookFailed {
+		t.Fatalf("expected the failing pre_create hook to abort, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "app")); !os.IsNotExist(err) {
+		t.Errorf("entry written although the pre hook failed: %v", err)
+	}
+	if ls, ok := leaseOf(t, dir, "app"); ok {
+		t.Errorf("lease kept although no entry was written: %+v", ls)
+	}
+}
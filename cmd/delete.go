@@ -1,47 +1,57 @@
 package cmd
 
 import (
-	"bufio"
-	"encoding/json"
-	"fmt"
-	"os"
-	"strings"
-
 	"github.com/rolling-space/pumadevctl/internal"
 	"github.com/spf13/cobra"
 )
 
 var deleteCmd = &cobra.Command{
-	Use:   "delete <domain>",
-	Short: "Delete an entry (file or symlink)",
-	Args:  cobra.ExactArgs(1),
+	Use:               "delete <domain>",
+	Short:             "Delete an entry (file or symlink)",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
 		dir, err := internal.ResolveDir(dirFlag)
 		if err != nil {
 			return err
 		}
-		domain := args[0]
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
 		if !forceFlag {
-			fmt.Fprintf(cmd.OutOrStdout(), "Delete %s? [y/N]: ", domain)
-			rdr := bufio.NewReader(os.Stdin)
-			line, _ := rdr.ReadString('\n')
-			if strings.ToLower(strings.TrimSpace(line)) != "y" {
-				internal.NewFormatter(cmd.OutOrStdout()).Warn("aborted")
+			if !confirm(cmd, "Delete "+domain+"?") {
+				internal.NewFormatter(promptWriter(cmd)).Warn("aborted")
 				return nil
 			}
 		}
-		if err := internal.DeleteEntry(dir, domain); err != nil {
+		release, err := internal.LockDir(dir)
+		if err != nil {
 			return err
 		}
-		if !quietFlag && !jsonFlag {
-			internal.NewFormatter(cmd.OutOrStdout()).Success("deleted: %s", domain)
+		defer release()
+		e, err := internal.ReadEntry(dir, domain)
+		if err != nil {
+			return err
 		}
-		if jsonFlag {
-			out := map[string]string{"domain": domain, "status": "deleted"}
-			b, _ := json.MarshalIndent(out, "", "  ")
-			fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		err = withHooks(cmd, internal.EventDelete, dir, []internal.EntryChange{internal.NewEntryChange(*e, "deleted")}, func() error {
+			return internal.DeleteEntry(dir, domain)
+		})
+		if err != nil {
+			return err
+		}
+		res := internal.EntryChange{Domain: domain, Status: "deleted"}
+		if r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("deleted: %s", domain)
+			}
+			return nil
 		}
-		return nil
+		return internal.Render(r, internal.EntryChangeView(res))
 	},
 }
 
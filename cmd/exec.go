@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	execNoCreate    bool
+	execWaitReady   bool
+	execWaitTimeout time.Duration
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <domain> -- <command> [args...]",
+	Short: "Run an app with its port (PORT, role ports, PUMADEV_DOMAIN) in the environment",
+	Long: "Run an app with its port in the environment.\n\n" +
+		"The entry is read, or created with an auto-allocated port block unless --no-create is given. The command\n" +
+		"gets PORT (the entry's port, whatever the port roles), <ROLE>_PORT for the other port roles, PUMADEV_DOMAIN\n" +
+		"and PUMADEV_HOST. Signals sent to pumadevctl are forwarded and its exit status is the command's.\n" +
+		"--wait-ready reports once the app accepts TCP connections on PORT and stops it if it does not within\n" +
+		"--wait-timeout.",
+	Example: "  pumadevctl exec myapp -- bin/rails s   # rails reads PORT\n" +
+		"  pumadevctl exec myapp -- sh -c 'bin/rails s -p \"$PORT\"'   # no shell runs the command, so expand PORT in one\n" +
+		"  pumadevctl exec myapp --wait-ready -- bin/dev",
+	Args:              cobra.MinimumNArgs(2),
+	ValidArgsFunction: completeExec,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		e, ports, err := prepareExec(cmd, dir, domain)
+		if err != nil {
+			return err
+		}
+		m, err := internal.ParseMapping(e.Mapping)
+		if err != nil {
+			return err
+		}
+		c := exec.Command(args[1], args[2:]...)
+		c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+		c.Env = internal.ExecEnv(os.Environ(), internal.AppEnv(domain, tldFlag, m.Port, ports))
+		p, err := internal.StartProcess(c)
+		if err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return internal.WithCode(internal.CodeNotFound, err)
+			}
+			return err
+		}
+		f := internal.NewFormatter(cmd.ErrOrStderr())
+		if execWaitReady {
+			if err := internal.WaitReachable(m.Host, m.Port, execWaitTimeout, p.Done()); err != nil {
+				_ = p.Stop()
+				_, _ = p.Wait()
+				return err
+			}
+			if !quietFlag {
+				f.Success("ready: %s → %s", domain, internal.AppURL(domain, tldFlag, "", false))
+			}
+		}
+		status, err := p.Wait()
+		if err != nil {
+			return err
+		}
+		if status != 0 {
+			return internal.ExitStatus(status)
+		}
+		return nil
+	},
+}
+
+// prepareExec reads domain's entry, creating it when missing, and returns it with its port roles. The
+// directory lock is only held here, not while the app runs.
+func prepareExec(cmd *cobra.Command, dir, domain string) (*internal.Entry, []internal.PortAssignment, error) {
+	roles, err := internal.ParsePortRoles(portRolesFlag, portBlockSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	release, err := internal.LockDir(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	e, err := internal.ReadEntry(dir, domain)
+	if internal.CodeOf(err) == internal.CodeNotFound && !execNoCreate {
+		var mapping string
+		if mapping, err = createAutoEntry(cmd, internal.EventCreate, dir, domain, false); err != nil {
+			return nil, nil, err
+		}
+		if !quietFlag {
+			internal.NewFormatter(cmd.ErrOrStderr()).Success("created: %s → %s", domain, mapping)
+		}
+		e, err = internal.ReadEntry(dir, domain)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.IsSymlink {
+		return nil, nil, internal.Errorf(internal.CodeUsage, "%s is a symlink entry; puma-dev starts it itself", domain)
+	}
+	ports, err := internal.AssignDomainPorts(dir, domain, portBlockSize, roles, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return e, ports, nil
+}
+
+func init() {
+	execCmd.Flags().BoolVar(&execNoCreate, "no-create", false, "fail instead of creating a missing entry")
+	execCmd.Flags().BoolVar(&execWaitReady, "wait-ready", false, "report once the app accepts connections on its port")
+	execCmd.Flags().DurationVar(&execWaitTimeout, "wait-timeout", 60*time.Second, "how long --wait-ready waits before stopping the app")
+	rootCmd.AddCommand(execCmd)
+}
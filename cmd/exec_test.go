@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExec_Env(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	root := isolateCLI(t)
+	dir, out := filepath.Join(root, "puma-dev"), filepath.Join(root, "env")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// no role at +0: the app still gets PORT, the port puma-dev proxies to
+	t.Setenv("PUMADEVCTL_PORT_ROLES", "vite:+1,cable:+2")
+
+	if _, err := runCLI(t, "exec", "--dir", dir, "-q", "myapp", "--", "sh", "-c", "env > "+out); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "myapp"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := strconv.Atoi(string(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{}
+	for _, line := range strings.Split(string(data), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			env[k] = v
+		}
+	}
+	want := map[string]string{
+		"PORT":           strconv.Itoa(base),
+		"VITE_PORT":      strconv.Itoa(base + 1),
+		"CABLE_PORT":     strconv.Itoa(base + 2),
+		"PUMADEV_DOMAIN": "myapp",
+		"PUMADEV_HOST":   "myapp.test",
+	}
+	for k, v := range want {
+		if env[k] != v {
+			t.Errorf("%s = %q, want %q", k, env[k], v)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+// filterFlags are the shared entry-selection flags for commands operating on many entries.
+type filterFlags struct {
+	match       []string
+	typ         string
+	host        string
+	portRange   string
+	tags        []string
+	reachable   bool
+	unreachable bool
+	where       string
+}
+
+func addFilterFlags(c *cobra.Command, ff *filterFlags) {
+	fl := c.Flags()
+	fl.StringSliceVar(&ff.match, "match", nil, "only domains matching this glob, e.g. 'api-*' (repeatable)")
+	fl.StringVar(&ff.typ, "type", "", "only entries of this type: file or symlink")
+	fl.StringVar(&ff.host, "host", "", "only mappings pointing at this host")
+	fl.StringVar(&ff.portRange, "port-range", "", "only mappings with a port in LO-HI, e.g. 36000-36100")
+	fl.StringSliceVar(&ff.tags, "tag", nil, "only domains carrying this tag (repeatable; all must match)")
+	fl.BoolVar(&ff.reachable, "reachable", false, "only reachable entries")
+	fl.BoolVar(&ff.unreachable, "unreachable", false, "only unreachable entries")
+	fl.StringVar(&ff.where, "where", "", "filter expression, e.g. 'port>=36500 && type==file'")
+}
+
+// build turns the parsed flags into a filter; timeoutMs bounds dials when reachability is filtered on.
+func (ff *filterFlags) build(timeoutMs int) (*internal.Filter, error) {
+	opts := internal.FilterOptions{
+		Match:     ff.match,
+		Type:      ff.typ,
+		Host:      ff.host,
+		PortRange: ff.portRange,
+		Tags:      ff.tags,
+		Expr:      ff.where,
+		TimeoutMs: timeoutMs,
+	}
+	if ff.reachable && ff.unreachable {
+		return nil, internal.Errorf(internal.CodeUsage, "--reachable and --unreachable are mutually exclusive")
+	}
+	if ff.reachable || ff.unreachable {
+		r := ff.reachable
+		opts.Reachable = &r
+	}
+	f, err := internal.NewFilter(opts)
+	return f, internal.WithCode(internal.CodeUsage, err)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var noHooksFlag bool
+
+// withHooks runs the pre hooks of event for changes in dir, then apply, then the post hooks. A failing pre
+// hook aborts before apply; a failing post hook is only reported, since the change is already made.
+func withHooks(cmd *cobra.Command, event, dir string, changes []internal.EntryChange, apply func() error) error {
+	if noHooksFlag || appConfig == nil || len(appConfig.Hooks) == 0 {
+		return apply()
+	}
+	h := internal.Hooks{
+		Commands: appConfig.Hooks,
+		Timeout:  time.Duration(appConfig.HookTimeout) * time.Second,
+		Output:   cmd.ErrOrStderr(), // keep stdout for the command's own output
+	}
+	p := internal.HookPayload{Event: event, Phase: internal.HookPre, Dir: dir, TLD: tldFlag, Changes: internal.NewHookChanges(dir, changes...)}
+	if err := h.Run(p); err != nil {
+		return err
+	}
+	if err := apply(); err != nil {
+		return err
+	}
+	p.Phase = internal.HookPost
+	if err := h.Run(p); err != nil {
+		internal.NewFormatter(cmd.ErrOrStderr()).Warn("%v", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noHooksFlag, "no-hooks", false, "skip the pre/post hooks configured for create, update, delete, cleanup and rename")
+}
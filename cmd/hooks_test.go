@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+func TestPortsCompact_PreHookAborts(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	root := isolateCLI(t)
+	dir := filepath.Join(root, "puma-dev")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "myapp"), []byte("36003"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hook := filepath.Join(root, "check")
+	if err := os.WriteFile(hook, []byte("#!/bin/sh\ncat > \"$0.json\"\nexit 1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	config := filepath.Join(root, "config", "pumadevctl", "config.json")
+	if err := os.MkdirAll(filepath.Dir(config), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(config, []byte(`{"hooks":{"pre_update":["`+hook+`"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := runCLI(t, "ports", "compact", "--apply", "--dir", dir, "-o", "json")
+	if internal.CodeOf(err) != internal.CodeHookFailed {
+		t.Fatalf("expected the failing pre_update hook to abort, got %v", err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "myapp")); string(b) != "36003" {
+		t.Errorf("entry rewritten although the pre hook failed: %q", b)
+	}
+	payload, err := os.ReadFile(hook + ".json")
+	if err != nil || !strings.Contains(string(payload), `"domain":"myapp"`) || !strings.Contains(string(payload), `"event":"update"`) {
+		t.Errorf("unexpected hook payload %s (%v)", payload, err)
+	}
+
+	if _, err := runCLI(t, "ports", "compact", "--apply", "--no-hooks", "--dir", dir, "-o", "json"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := os.ReadFile(filepath.Join(dir, "myapp")); string(b) == "36003" {
+		t.Errorf("--no-hooks should apply the compaction")
+	}
+}
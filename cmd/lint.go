@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	lintFix    bool
+	lintStrict bool
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check entries for duplicates, overlapping blocks, bad ports and invalid names",
+	Long: "Check entries for duplicates, overlapping blocks, bad ports and invalid names.\n\n" +
+		"Mappings are normalized before comparing (\"36000\", \"127.0.0.1:36000\" and \"localhost:36000\" are the\n" +
+		"same target). --fix applies only safe rewrites: spelling 127.0.0.1:PORT as PORT and renaming entries\n" +
+		"with uppercase letters or a .test suffix when the new name is free, each through the update or rename\n" +
+		"hooks. Exits with the validation_failed code when errors remain (or any issue, with --strict), so it\n" +
+		"can gate CI.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		if lintFix {
+			release, err := internal.LockDir(dir)
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return err
+		}
+		rep := internal.LintEntries(entries, internal.LintOptions{Min: portMinFlag, Max: portMaxFlag, Block: portBlockSize, TLD: tldFlag})
+		if lintFix {
+			err := internal.FixLint(dir, &rep, func(event string, change internal.EntryChange, apply func() error) error {
+				return withHooks(cmd, event, dir, []internal.EntryChange{change}, apply)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if !r.IsHuman() {
+			if err := internal.Render(r, internal.LintView(rep)); err != nil {
+				return err
+			}
+			return lintError(rep)
+		}
+		f := internal.NewFormatter(cmd.OutOrStdout())
+		for _, is := range rep.Issues {
+			switch {
+			case is.Fixed:
+				f.Success("fixed [%s] %s", is.Rule, is.Fix)
+			case is.Severity == internal.SeverityError:
+				f.Error("✖ [%s] %s", is.Rule, is.Message)
+			default:
+				f.Warn("! [%s] %s", is.Rule, is.Message)
+			}
+			if is.Fix != "" && !is.Fixed && !quietFlag {
+				f.IndentBy(2).Info("fixable: %s", is.Fix)
+			}
+		}
+		if !quietFlag {
+			f.Subheader("Summary")
+			f.KV("entries", len(entries))
+			f.KV("errors", rep.Errors)
+			f.KV("warnings", rep.Warnings)
+			if lintFix {
+				f.KV("fixed", rep.Fixed)
+			} else if n := fixable(rep); n > 0 {
+				f.Info("%d issue(s) can be fixed with --fix", n)
+			}
+		}
+		return lintError(rep)
+	},
+}
+
+// lintError fails the command with CodeValidationFailed when errors (or, with --strict, warnings) remain.
+func lintError(rep internal.LintReport) error {
+	if rep.Errors == 0 && (!lintStrict || rep.Warnings == 0) {
+		return nil
+	}
+	return internal.Errorf(internal.CodeValidationFailed, "lint failed: %d errors, %d warnings", rep.Errors, rep.Warnings)
+}
+
+func fixable(rep internal.LintReport) int {
+	n := 0
+	for _, is := range rep.Issues {
+		if is.Fix != "" && !is.Fixed {
+			n++
+		}
+	}
+	return n
+}
+
+func init() {
+	lintCmd.Flags().BoolVar(&lintFix, "fix", false, "apply safe rewrites (canonical mappings, name case and .test suffix)")
+	lintCmd.Flags().BoolVar(&lintStrict, "strict", false, "fail on warnings as well as errors")
+	rootCmd.AddCommand(lintCmd)
+}
@@ -5,27 +5,54 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var (
+	listFilter      filterFlags
+	listTree        bool
+	listAllContexts bool
+)
+
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List mappings and group duplicates",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dir, err := internal.ResolveDir(dirFlag)
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		filter, err := listFilter.build(300)
 		if err != nil {
 			return err
 		}
-		entries, err := internal.LoadEntries(dir)
+		entries, dirs, err := loadScope(listAllContexts)
 		if err != nil {
 			return err
 		}
-		if jsonFlag {
-			return internal.PrintListJSON(entries)
+		conflicts := contextConflicts(entries, dirs)
+		entries = filter.Apply(entries)
+		if listTree {
+			if !r.IsHuman() {
+				return internal.Errorf(internal.CodeUsage, "--tree is a human-readable view; use -o json and the domain names instead")
+			}
+			internal.PrintDomainTree(cmd.OutOrStdout(), internal.DomainTree(entries), tldFlag)
+			printContextConflicts(cmd, conflicts)
+			return nil
+		}
+		if r.IsHuman() {
+			internal.PrintListFancy(cmd.OutOrStdout(), entries)
+			printContextConflicts(cmd, conflicts)
+			return nil
+		}
+		if err := internal.Render(r, internal.ListView(entries)); err != nil {
+			return err
 		}
-		internal.PrintListFancy(entries)
-		// Also print simple JSON when quiet requested? quiet suppresses extras, so nothing more.
+		printContextConflicts(cmd, conflicts)
 		return nil
 	},
 }
 
 func init() {
+	addFilterFlags(listCmd, &listFilter)
+	listCmd.Flags().BoolVar(&listAllContexts, "all-contexts", false, "list the entries of every context, as CONTEXT/DOMAIN")
+	listCmd.Flags().BoolVar(&listTree, "tree", false, "show the host hierarchy implied by entry names (admin.myapp under myapp)")
 	rootCmd.AddCommand(listCmd)
 }
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	metaOwner   string
+	metaProject string
+	metaClear   bool
+)
+
+var metaCmd = &cobra.Command{
+	Use:               "meta <domain>",
+	Short:             "Show or set metadata (owner, project path) for a domain",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		domain, m, err := editMeta(args[0], func(m *internal.Meta) bool {
+			changed := false
+			if metaClear {
+				*m = internal.Meta{}
+				changed = true
+			}
+			if cmd.Flags().Changed("owner") {
+				m.Owner = metaOwner
+				changed = true
+			}
+			if cmd.Flags().Changed("project") {
+				m.Project = metaProject
+				if metaProject != "" {
+					if abs, err := filepath.Abs(metaProject); err == nil {
+						m.Project = abs
+					}
+				}
+				changed = true
+			}
+			return changed
+		})
+		if err != nil {
+			return err
+		}
+		return printMeta(cmd, domain, m)
+	},
+}
+
+func init() {
+	metaCmd.Flags().StringVar(&metaOwner, "owner", "", "set the owning team or person (empty clears)")
+	metaCmd.Flags().StringVar(&metaProject, "project", "", "set the app's project path (empty clears)")
+	metaCmd.Flags().BoolVar(&metaClear, "clear", false, "remove all metadata for the domain")
+	rootCmd.AddCommand(metaCmd)
+}
+
+// editMeta resolves an existing domain, lets fn modify its metadata, and saves when fn reports a change.
+func editMeta(arg string, fn func(m *internal.Meta) bool) (string, internal.Meta, error) {
+	dir, err := internal.ResolveDir(dirFlag)
+	if err != nil {
+		return "", internal.Meta{}, err
+	}
+	domain, err := internal.LookupDomain(dir, arg, tldFlag)
+	if err != nil {
+		return "", internal.Meta{}, err
+	}
+	release, err := internal.LockDir(dir)
+	if err != nil {
+		return "", internal.Meta{}, err
+	}
+	defer release()
+	if _, err := os.Lstat(filepath.Join(dir, domain)); err != nil {
+		return "", internal.Meta{}, internal.Errorf(internal.CodeNotFound, "entry %s does not exist", domain)
+	}
+	store, err := internal.LoadMeta(dir)
+	if err != nil {
+		return "", internal.Meta{}, err
+	}
+	m, _ := store.Get(domain)
+	if fn(&m) {
+		store.Set(domain, m)
+		if err := store.Save(); err != nil {
+			return domain, m, err
+		}
+	}
+	return domain, m, nil
+}
+
+func printMeta(cmd *cobra.Command, domain string, m internal.Meta) error {
+	r, err := newRenderer(cmd)
+	if err != nil {
+		return err
+	}
+	if !r.IsHuman() {
+		return internal.Render(r, internal.DomainMetaView(internal.DomainMeta{Domain: domain, Meta: m}))
+	}
+	f := internal.NewFormatter(cmd.OutOrStdout())
+	if m.IsZero() {
+		if !quietFlag {
+			f.Info("%s has no metadata", domain)
+		}
+		return nil
+	}
+	f.Header(domain)
+	printMetaKV(f, &m)
+	return nil
+}
+
+func printMetaKV(f *internal.Formatter, m *internal.Meta) {
+	if len(m.Tags) > 0 {
+		f.KV("tags", strings.Join(m.Tags, ", "))
+	}
+	if m.Owner != "" {
+		f.KV("owner", m.Owner)
+	}
+	if m.Project != "" {
+		f.KV("project", m.Project)
+	}
+	if m.Note != "" {
+		f.KV("note", m.Note)
+	}
+	if len(m.Roles) > 0 {
+		names := make([]string, 0, len(m.Roles))
+		for name := range m.Roles {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return m.Roles[names[i]] < m.Roles[names[j]] })
+		roles := make([]string, len(names))
+		for i, name := range names {
+			roles[i] = fmt.Sprintf("%s:+%d", name, m.Roles[name])
+		}
+		f.KV("ports", strings.Join(roles, ", "))
+	}
+}
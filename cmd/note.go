@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var noteClear bool
+
+var noteCmd = &cobra.Command{
+	Use:               "note <domain> [text...]",
+	Short:             "Set (or with --clear, remove) the free-form note on a domain; shows it when no text is given",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		text := strings.TrimSpace(strings.Join(args[1:], " "))
+		domain, m, err := editMeta(args[0], func(m *internal.Meta) bool {
+			if noteClear {
+				m.Note = ""
+				return true
+			}
+			if text == "" {
+				return false
+			}
+			m.Note = text
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return printMeta(cmd, domain, m)
+	},
+}
+
+func init() {
+	noteCmd.Flags().BoolVar(&noteClear, "clear", false, "remove the note")
+	rootCmd.AddCommand(noteCmd)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	openHTTPS bool
+	openPrint bool
+)
+
+// findLauncher picks the browser launcher used by open; replaceable for other platforms or tests.
+var findLauncher = func() (internal.Launcher, error) {
+	return internal.FindLauncher(os.Getenv, exec.LookPath)
+}
+
+var openCmd = &cobra.Command{
+	Use:   "open <domain> [path]",
+	Short: "Open an app in the browser (http://<domain>.<tld>/[path])",
+	Long: "Open an app in the browser (http://<domain>.<tld>/[path]).\n\n" +
+		"<domain> may be a nested host such as admin.myapp, as long as some entry serves it (see resolve).\n" +
+		"The browser is started via $BROWSER, else open (macOS) or xdg-open; when none is available the URL\n" +
+		"is printed instead. --print only prints the URL.",
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		host, err := internal.NormalizeDomain(args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return err
+		}
+		res, err := internal.ResolveHost(entries, host, tldFlag)
+		if err != nil {
+			return err
+		}
+		if res.Entry == nil {
+			return internal.Errorf(internal.CodeNotFound, "no entry serves %s", res.Host)
+		}
+		path := ""
+		if len(args) == 2 {
+			path = args[1]
+		}
+		out := internal.OpenResult{Domain: res.Domain, Host: res.Host, URL: internal.AppURL(host, tldFlag, path, openHTTPS)}
+		if openPrint {
+			fmt.Fprintln(cmd.OutOrStdout(), out.URL)
+			return nil
+		}
+		l, err := findLauncher()
+		if err == nil {
+			out.Launcher = l.Name()
+			err = l.Launch(out.URL)
+		}
+		if err != nil {
+			out.Error = err.Error()
+		} else {
+			out.Launched = true
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.OpenView(out))
+		}
+		if !out.Launched {
+			// fall back to the URL so it can still be clicked or copied
+			internal.NewFormatter(cmd.ErrOrStderr()).Warn("could not open a browser: %s", out.Error)
+			fmt.Fprintln(cmd.OutOrStdout(), out.URL)
+			return nil
+		}
+		if !quietFlag {
+			internal.NewFormatter(cmd.OutOrStdout()).Success("opened %s", out.URL)
+		}
+		return nil
+	},
+}
+
+func init() {
+	openCmd.Flags().BoolVar(&openHTTPS, "https", false, "use https://")
+	openCmd.Flags().BoolVar(&openPrint, "print", false, "print the URL instead of opening it")
+	rootCmd.AddCommand(openCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+// newRenderer binds the -o/--output format (or --json) to the command's stdout.
+func newRenderer(cmd *cobra.Command) (*internal.Renderer, error) {
+	return internal.NewRenderer(outputFlag, cmd.OutOrStdout())
+}
+
+// machineOutput reports whether the selected output is meant for scripts, so chatter must stay off stdout.
+func machineOutput() bool {
+	return outputFlag != internal.OutputTable && outputFlag != internal.OutputWide
+}
+
+// promptWriter is where interactive prompts go: stdout normally, stderr in machine output modes
+// so they never corrupt the document on stdout.
+func promptWriter(cmd *cobra.Command) io.Writer {
+	if machineOutput() {
+		return cmd.ErrOrStderr()
+	}
+	return cmd.OutOrStdout()
+}
+
+// confirm asks a yes/no question on the command's input.
+func confirm(cmd *cobra.Command, question string) bool {
+	fmt.Fprintf(promptWriter(cmd), "%s [y/N]: ", question)
+	rdr := bufio.NewReader(cmd.InOrStdin())
+	line, _ := rdr.ReadString('\n')
+	return strings.ToLower(strings.TrimSpace(line)) == "y"
+}
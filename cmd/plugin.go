@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+// pluginGroup groups plugin subcommands in help.
+const pluginGroup = "plugins"
+
+var pluginCmd = &cobra.Command{
+	Use:   "plugin",
+	Short: "Inspect external pumadevctl-<name> subcommands",
+	Long: "Plugins are executables named pumadevctl-<name> in " + internal.PluginDir() + " or on $PATH;\n" +
+		"`pumadevctl <name> [args]` runs the first one found, like git and kubectl do. Flags before <name> are\n" +
+		"pumadevctl's, everything after it goes to the plugin. Plugins get PUMADEVCTL_DIR (the resolved mappings\n" +
+		"dir), PUMADEVCTL_CONFIG (the user config file) and PUMADEVCTL_OUTPUT (the -o format) in their\n" +
+		"environment. Built-in commands always win over plugins of the same name.",
+}
+
+var pluginListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the plugins found in the plugins dir and on $PATH",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		plugins := findPlugins()
+		if !r.IsHuman() {
+			return internal.Render(r, internal.PluginView(plugins))
+		}
+		if len(plugins) == 0 {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Info("no plugins found in %s or on $PATH", internal.PluginDir())
+			}
+			return nil
+		}
+		if err := internal.Render(r, internal.PluginView(plugins)); err != nil {
+			return err
+		}
+		f := internal.NewFormatter(cmd.ErrOrStderr())
+		for _, p := range plugins {
+			if p.Status == internal.PluginShadowed {
+				f.Warn("%s is shadowed by the built-in %s command and never runs", p.Path, p.Name)
+			}
+			for _, h := range p.Hidden {
+				f.Warn("%s is hidden by %s", h, p.Path)
+			}
+		}
+		return nil
+	},
+}
+
+// findPlugins lists the plugins on the search path, marking those a built-in command shadows.
+func findPlugins() []internal.Plugin {
+	return internal.FindPlugins(internal.PluginSearchPath(), isBuiltinCommand)
+}
+
+func isBuiltinCommand(name string) bool {
+	if name == "help" || name == "completion" { // added by cobra when it executes
+		return true
+	}
+	for _, c := range rootCmd.Commands() {
+		if c.GroupID != pluginGroup && (c.Name() == name || c.HasAlias(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// addPluginCommands registers every runnable plugin as a subcommand, so dispatch, help and completion treat
+// it like a built-in one.
+func addPluginCommands() {
+	for _, p := range findPlugins() {
+		if p.Status != internal.PluginOK {
+			continue
+		}
+		if !rootCmd.ContainsGroup(pluginGroup) {
+			rootCmd.AddGroup(&cobra.Group{ID: pluginGroup, Title: "Plugin Commands:"})
+		}
+		rootCmd.AddCommand(pluginCommand(p))
+	}
+}
+
+func pluginCommand(p internal.Plugin) *cobra.Command {
+	return &cobra.Command{
+		Use:     p.Name,
+		Short:   "Plugin (" + p.Path + ")",
+		GroupID: pluginGroup,
+		// the plugin parses its own flags, --help included; runPlugin parses pumadevctl's
+		DisableFlagParsing: true,
+		PersistentPreRunE:  func(*cobra.Command, []string) error { return nil },
+		ValidArgsFunction: func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+			return nil, cobra.ShellCompDirectiveDefault
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPlugin(p)
+		},
+	}
+}
+
+// runPlugin applies the pumadevctl flags given before the plugin name, then runs the plugin with the rest of
+// the command line and exits with its status.
+func runPlugin(p internal.Plugin) error {
+	before, args := splitPluginArgs(os.Args[1:])
+	if err := rootCmd.ParseFlags(before); err != nil {
+		return internal.WithCode(internal.CodeUsage, err)
+	}
+	if err := prepareCommand(rootCmd); err != nil {
+		return err
+	}
+	c := exec.Command(p.Path, args...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	c.Env = internal.ExecEnv(os.Environ(), internal.PluginEnv(appConfig.Dir, internal.ConfigPath(), outputFlag))
+	proc, err := internal.StartProcess(c)
+	if err != nil {
+		return internal.Errorf(internal.CodeError, "plugin %s: %v", p.Name, err)
+	}
+	status, err := proc.Wait()
+	if err != nil {
+		return err
+	}
+	if status != 0 {
+		return internal.ExitStatus(status)
+	}
+	return nil
+}
+
+// splitPluginArgs splits a command line at the plugin name, the first argument that is neither a pumadevctl
+// flag nor a flag's value: the flags before it and the plugin's arguments after it. "--" ends pumadevctl's
+// flags, so the argument after it is the plugin name.
+func splitPluginArgs(args []string) (before, after []string) {
+	flags := rootCmd.PersistentFlags()
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			if i+1 < len(args) {
+				return args[:i], args[i+2:]
+			}
+			return args[:i], nil
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			return args[:i], args[i+1:]
+		}
+		if strings.Contains(a, "=") {
+			continue
+		}
+		takesValue := false
+		if name, ok := strings.CutPrefix(a, "--"); ok {
+			f := flags.Lookup(name)
+			takesValue = f != nil && f.NoOptDefVal == ""
+		} else if len(a) == 2 {
+			f := flags.ShorthandLookup(a[1:])
+			takesValue = f != nil && f.NoOptDefVal == ""
+		}
+		if takesValue {
+			i++
+		}
+	}
+	return args, nil
+}
+
+func init() {
+	pluginCmd.AddCommand(pluginListCmd)
+	rootCmd.AddCommand(pluginCmd)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+func TestSplitPluginArgs(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          []string
+		before, after []string
+	}{
+		{"plugin only", []string{"deploy", "--fast"}, []string{}, []string{"--fast"}},
+		{"flag with value", []string{"--dir", "/tmp/x", "deploy", "a"}, []string{"--dir", "/tmp/x"}, []string{"a"}},
+		{"flag=value", []string{"--dir=/tmp/x", "deploy"}, []string{"--dir=/tmp/x"}, []string{}},
+		{"shorthand with value", []string{"-o", "json", "deploy", "-o", "yaml"}, []string{"-o", "json"}, []string{"-o", "yaml"}},
+		{"bool flags", []string{"--quiet", "-f", "deploy", "x"}, []string{"--quiet", "-f"}, []string{"x"}},
+		{"unknown flag", []string{"--nope", "deploy"}, []string{"--nope"}, []string{}},
+		{"double dash before name", []string{"-q", "--", "deploy", "x"}, []string{"-q"}, []string{"x"}},
+		{"double dash after name", []string{"deploy", "--", "--dir", "y"}, []string{}, []string{"--", "--dir", "y"}},
+		{"no plugin name", []string{"--dir", "/tmp/x"}, []string{"--dir", "/tmp/x"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, after := splitPluginArgs(tt.args)
+			if !reflect.DeepEqual(before, tt.before) || !reflect.DeepEqual(after, tt.after) {
+				t.Errorf("splitPluginArgs(%q) = %q, %q; want %q, %q", tt.args, before, after, tt.before, tt.after)
+			}
+		})
+	}
+}
+
+func TestRunPlugin_EnvAndStatus(t *testing.T) {
+	root := isolateCLI(t)
+	bin, dir, out := filepath.Join(root, "bin"), filepath.Join(root, "puma-dev"), filepath.Join(root, "out")
+	for _, d := range []string{bin, dir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	script := "#!/bin/sh\n" +
+		"printf '%s\\n' \"$PUMADEVCTL_DIR\" \"$PUMADEVCTL_CONFIG\" \"$PUMADEVCTL_OUTPUT\" \"$*\" > \"$1\"\n" +
+		"exit 3\n"
+	if err := os.WriteFile(filepath.Join(bin, "pumadevctl-probe"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	addPluginCommands()
+	t.Cleanup(func() {
+		for _, c := range rootCmd.Commands() {
+			if c.GroupID == pluginGroup {
+				rootCmd.RemoveCommand(c)
+			}
+		}
+	})
+
+	// runPlugin splits the real command line, so os.Args must match what cobra is given
+	args := []string{"--dir", dir, "-o", "json", "probe", out, "--fast"}
+	prev := os.Args
+	os.Args = append([]string{"pumadevctl"}, args...)
+	t.Cleanup(func() { os.Args = prev })
+
+	_, err := runCLI(t, args...)
+	var status internal.ExitStatus
+	if !errors.As(err, &status) || status != 3 {
+		t.Fatalf("expected the plugin's exit status 3, got %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{dir, internal.ConfigPath(), "json", out + " --fast"}
+	if got := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("plugin saw %q, want %q", got, want)
+	}
+}
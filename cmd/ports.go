@@ -0,0 +1,338 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	portsExportEnv   bool
+	portsReset       bool
+	portsReservePort int
+	portsGCDays      int
+	portsGCDryRun    bool
+)
+
+var portsCmd = &cobra.Command{
+	Use:   "ports <domain>",
+	Short: "Show the named ports (web, vite, cable, ...) reserved inside a domain's port block",
+	Long: "Show the named ports reserved inside a domain's port block.\n\n" +
+		"Roles come from --roles or port_roles in the config (default \"" + internal.DefaultPortRoles + "\"), e.g. \"web:+0,vite:+1,cable:+2\".\n" +
+		"The first time a role is seen for a domain its offset is recorded in the metadata sidecar, so later config\n" +
+		"changes never move an existing reservation; --reset re-applies the configured layout.",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		roles, err := internal.ParsePortRoles(portRolesFlag, portBlockSize)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		release, err := internal.LockDir(dir)
+		if err != nil {
+			return err
+		}
+		defer release()
+		ports, err := internal.AssignDomainPorts(dir, domain, portBlockSize, roles, portsReset)
+		if err != nil {
+			return err
+		}
+		if portsExportEnv {
+			for _, p := range ports {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s=%d\n", p.Env, p.Port)
+			}
+			return nil
+		}
+		return internal.Render(r, internal.PortAssignmentView(ports))
+	},
+}
+
+var portsReserveCmd = &cobra.Command{
+	Use:               "reserve <domain>",
+	Short:             "Lease a port block for a domain in the ledger (its entry's block, --port, or the next free one)",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		release, err := internal.LockDir(dir)
+		if err != nil {
+			return err
+		}
+		defer release()
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return err
+		}
+		alloc, err := newAllocator(dir)
+		if err != nil {
+			return err
+		}
+		lease, _, err := leaseBlock(cmd, alloc, domain, func() (int, error) {
+			return reserveBase(cmd, alloc, dir, domain, entries)
+		})
+		if err != nil {
+			return err
+		}
+		if r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("reserved: %s", lease)
+			}
+			return nil
+		}
+		return internal.Render(r, internal.LeaseView([]internal.Lease{lease}, true))
+	},
+}
+
+// reserveBase picks the block ports reserve leases: --port, the entry's block, or the next free one.
+func reserveBase(cmd *cobra.Command, alloc *internal.Allocator, dir, domain string, entries []internal.Entry) (int, error) {
+	if cmd.Flags().Changed("port") {
+		return portsReservePort, alloc.Check(domain, portsReservePort, entries)
+	}
+	e, err := internal.ReadEntry(dir, domain)
+	if err == nil {
+		return internal.EntryBasePort(e)
+	}
+	if internal.CodeOf(err) != internal.CodeNotFound {
+		return 0, err
+	}
+	return allocate(cmd, alloc, domain, entries)
+}
+
+var portsReleaseCmd = &cobra.Command{
+	Use:               "release <domain>",
+	Short:             "Drop a domain's lease from the ledger so its block can be handed out again",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		var lease internal.Lease
+		var ok bool
+		err = internal.UpdateLedger(internal.LedgerPath(), func(l *internal.Ledger) bool {
+			lease, ok = l.Release(dir, domain)
+			return ok
+		})
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return internal.Errorf(internal.CodeNotFound, "no lease for %s", domain)
+		}
+		if r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("released: %s", lease)
+			}
+			return nil
+		}
+		return internal.Render(r, internal.LeaseView([]internal.Lease{lease}, true))
+	},
+}
+
+var portsGCCmd = &cobra.Command{
+	Use:   "gc",
+	Short: "Remove leases whose entry no longer exists",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		var cutoff time.Time
+		if portsGCDays > 0 {
+			cutoff = time.Now().AddDate(0, 0, -portsGCDays)
+		}
+		exists := func(l internal.Lease) bool {
+			_, err := os.Lstat(filepath.Join(l.Dir, l.Domain))
+			return err == nil
+		}
+		var removed []internal.Lease
+		err = internal.UpdateLedger(internal.LedgerPath(), func(l *internal.Ledger) bool {
+			removed = l.GC(cutoff, exists)
+			return len(removed) > 0 && !portsGCDryRun
+		})
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.LeaseView(removed, false))
+		}
+		f := internal.NewFormatter(cmd.OutOrStdout())
+		if len(removed) == 0 {
+			if !quietFlag {
+				f.Info("nothing to collect")
+			}
+			return nil
+		}
+		for _, l := range removed {
+			f.Bullet(l.String())
+		}
+		if portsGCDryRun {
+			f.Warn("--dry-run set; ledger unchanged.")
+		} else if !quietFlag {
+			f.Success("released %d lease(s)", len(removed))
+		}
+		return nil
+	},
+}
+
+var portsLeasesCmd = &cobra.Command{
+	Use:   "leases",
+	Short: "List the port leases recorded in the ledger",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		l, err := internal.LoadLedger(internal.LedgerPath())
+		if err != nil {
+			return err
+		}
+		return internal.Render(r, internal.LeaseView(l.Leases, false))
+	},
+}
+
+func init() {
+	portsCmd.Flags().StringVar(&portRolesFlag, "roles", internal.DefaultPortRoles, "port roles as name:+offset pairs (default from port_roles in config)")
+	portsCmd.Flags().BoolVar(&portsExportEnv, "export-env", false, "print PORT=... VITE_PORT=... lines for sourcing in a shell")
+	portsCmd.Flags().BoolVar(&portsReset, "reset", false, "forget recorded offsets and re-apply the configured roles")
+	portsReserveCmd.Flags().IntVar(&portsReservePort, "port", 0, "lease the block starting at this port")
+	portsGCCmd.Flags().IntVar(&portsGCDays, "days", 30, "only remove orphaned leases not refreshed for this many days (0 = all orphans)")
+	portsGCCmd.Flags().BoolVar(&portsGCDryRun, "dry-run", false, "show what would be removed without changing the ledger")
+	portsCmd.AddCommand(portsReserveCmd, portsReleaseCmd, portsGCCmd, portsLeasesCmd)
+	rootCmd.AddCommand(portsCmd)
+}
+
+// newAllocator returns an allocator for dir over the configured range that honours the ledger.
+func newAllocator(dir string) (*internal.Allocator, error) {
+	l, err := internal.LoadLedger(internal.LedgerPath())
+	if err != nil {
+		return nil, err
+	}
+	alloc := &internal.Allocator{
+		Min: portMinFlag, Max: portMaxFlag, Block: portBlockSize,
+		Dir: dir, Ledger: l, Prober: internal.ListenProber{},
+	}
+	if appConfig != nil {
+		// apps of other puma-dev instances run on this host too
+		alloc.Reserved = internal.ContextReservations(appConfig.ContextList(), dir, portBlockSize)
+	}
+	return alloc, nil
+}
+
+// allocate runs the allocator and, with --verbose, explains on stderr why earlier blocks were skipped.
+func allocate(cmd *cobra.Command, alloc *internal.Allocator, domain string, entries []internal.Entry) (int, error) {
+	base, err := alloc.Allocate(domain, entries)
+	if verboseFlag {
+		f := internal.NewFormatter(cmd.ErrOrStderr())
+		for _, s := range alloc.Skipped {
+			f.Info("skipped %d-%d: %s", s.Base, s.End, s.Reason)
+		}
+	}
+	return base, err
+}
+
+// leaseBlock leases the block pick chooses to domain. pick runs under the ledger lock, with alloc deciding on
+// the ledger as it is then, so runs in other mappings dirs or contexts cannot lease the same block. undo
+// restores the domain's previous lease, for when its entry is not written after all.
+func leaseBlock(cmd *cobra.Command, alloc *internal.Allocator, domain string, pick func() (int, error)) (lease internal.Lease, undo func(), err error) {
+	var prev internal.Lease
+	var had bool
+	var pickErr error
+	err = internal.UpdateLedger(internal.LedgerPath(), func(l *internal.Ledger) bool {
+		alloc.Ledger = l
+		var base int
+		if base, pickErr = pick(); pickErr != nil {
+			return false
+		}
+		prev, had = l.Lookup(alloc.Dir, domain)
+		lease = l.Reserve(alloc.Dir, domain, base, alloc.Block, time.Now())
+		return true
+	})
+	if err == nil {
+		err = pickErr
+	}
+	if err != nil {
+		return internal.Lease{}, nil, err
+	}
+	undo = func() {
+		err := internal.UpdateLedger(internal.LedgerPath(), func(l *internal.Ledger) bool {
+			if had {
+				l.Put(prev)
+			} else {
+				l.Release(alloc.Dir, domain)
+			}
+			return true
+		})
+		if err != nil {
+			internal.NewFormatter(cmd.ErrOrStderr()).Warn("could not roll back the lease of %s: %v", domain, err)
+		}
+	}
+	return lease, undo, nil
+}
+
+// recordLease leases the block starting at an explicitly given mapping's port to domain. Mappings to other
+// hosts take no local ports and are not leased, nor is a block overlapping another domain's lease or entry:
+// that is only reported, since the user chose the port.
+func recordLease(cmd *cobra.Command, dir, domain, mapping string) error {
+	m, err := internal.ParseMapping(mapping)
+	if err != nil {
+		return err
+	}
+	if !m.IsLoopback() {
+		return nil
+	}
+	entries, err := internal.LoadEntries(dir)
+	if err != nil {
+		return err
+	}
+	alloc, err := newAllocator(dir)
+	if err != nil {
+		return err
+	}
+	_, _, err = leaseBlock(cmd, alloc, domain, func() (int, error) {
+		return m.Port, alloc.Check(domain, m.Port, entries)
+	})
+	if internal.CodeOf(err) == internal.CodeAlreadyExists {
+		internal.NewFormatter(cmd.ErrOrStderr()).Warn("%s not leased: %v", mapping, err)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	compactPlan  bool
+	compactApply bool
+)
+
+var portsCompactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Re-align scattered port blocks to block boundaries, moving as few entries as possible",
+	Long: "Re-align scattered port blocks to block boundaries, moving as few entries as possible.\n\n" +
+		"Aligned entries keep their block; each misaligned one moves to the nearest free aligned block.\n" +
+		"Without --apply only the plan is shown. With --apply the entries are rewritten and the report lists\n" +
+		"which apps (project path from `meta --project`) need their PORT/role variables updated. The update hooks\n" +
+		"run once for all moved entries.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		if compactApply {
+			release, err := internal.LockDir(dir)
+			if err != nil {
+				return err
+			}
+			defer release()
+		}
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return err
+		}
+		alloc, err := newAllocator(dir)
+		if err != nil {
+			return err
+		}
+		plan, err := internal.PlanCompaction(entries, alloc)
+		if err != nil {
+			return err
+		}
+		switch {
+		case compactApply && len(plan.Moves) == 0:
+			plan.Applied = true // nothing to rewrite, so no hooks either
+		case compactApply:
+			err := withHooks(cmd, internal.EventUpdate, dir, plan.Changes(), func() error {
+				if err := internal.ApplyCompaction(dir, &plan); err != nil {
+					return err
+				}
+				for _, mv := range plan.Moves {
+					if err := recordLease(cmd, dir, mv.Domain, mv.NewMapping); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.CompactView(plan))
+		}
+		f := internal.NewFormatter(cmd.OutOrStdout())
+		if len(plan.Moves) == 0 {
+			if !quietFlag {
+				f.Info("all %d entries are aligned; nothing to move", plan.Unchanged)
+			}
+			return nil
+		}
+		f.Header("Moves")
+		for _, mv := range plan.Moves {
+			f.Bullet(fmt.Sprintf("%s: %s → %s", mv.Domain, mv.Mapping, mv.NewMapping))
+		}
+		if !quietFlag {
+			f.KV("unchanged", plan.Unchanged)
+		}
+		if !plan.Applied {
+			f.Warn("plan only; run with --apply to rewrite %d entries", len(plan.Moves))
+			return nil
+		}
+		f.Success("moved %d entries", len(plan.Moves))
+		f.Header("App configs to update")
+		for _, mv := range plan.Moves {
+			changes := make([]string, len(mv.Env))
+			for i, c := range mv.Env {
+				changes[i] = fmt.Sprintf("%s %d→%d", c.Name, c.From, c.To)
+			}
+			where := mv.Project
+			if where == "" {
+				where = "(no project recorded)"
+			}
+			f.Bullet(fmt.Sprintf("%s %s: %s", mv.Domain, where, strings.Join(changes, ", ")))
+		}
+		return nil
+	},
+}
+
+func init() {
+	portsCompactCmd.Flags().BoolVar(&compactPlan, "plan", false, "only compute and show the new layout (default)")
+	portsCompactCmd.Flags().BoolVar(&compactApply, "apply", false, "rewrite entries to the new layout")
+	portsCompactCmd.MarkFlagsMutuallyExclusive("plan", "apply")
+	portsCmd.AddCommand(portsCompactCmd)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var (
+	portsMapPerRow  int
+	portsMapNoProbe bool
+)
+
+var portsMapCmd = &cobra.Command{
+	Use:   "map",
+	Short: "Draw the port range as a grid of blocks: free, owned, leased, misaligned/overlapping or foreign",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return err
+		}
+		alloc, err := newAllocator(dir)
+		if err != nil {
+			return err
+		}
+		if portsMapNoProbe {
+			alloc.Prober = nil
+		}
+		pm, err := internal.BuildPortMap(entries, alloc)
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			return internal.Render(r, internal.PortMapView(pm))
+		}
+		out := cmd.OutOrStdout()
+		internal.PrintPortMap(out, pm, portsMapPerRow)
+		if quietFlag {
+			return nil
+		}
+		f := internal.NewFormatter(out)
+		s := pm.Stats
+		fmt.Fprintln(out)
+		f.KV("range", fmt.Sprintf("%d-%d (%d blocks of %d)", pm.Min, pm.Max, s.Blocks, pm.BlockSize))
+		f.KV("utilization", fmt.Sprintf("%.0f%% (%d owned, %d leased, %d conflict, %d foreign)", s.Utilization*100, s.Owned, s.Leased, s.Conflict, s.Foreign))
+		if s.LargestFreeRun > 0 {
+			f.KV("largest free", fmt.Sprintf("%d blocks from %d", s.LargestFreeRun, s.LargestFreeAt))
+		} else {
+			f.KV("largest free", "none")
+		}
+		for _, b := range pm.Blocks {
+			if b.State == internal.BlockConflict || b.State == internal.BlockForeign {
+				f.Warn("%d-%d %s: %s %v", b.Base, b.End, b.State, b.Detail, b.Domains)
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	portsMapCmd.Flags().IntVar(&portsMapPerRow, "per-row", 10, "blocks per grid row")
+	portsMapCmd.Flags().BoolVar(&portsMapNoProbe, "no-probe", false, "do not bind-probe free blocks for foreign listeners")
+	portsCmd.AddCommand(portsMapCmd)
+}
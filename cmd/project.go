@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Create the entries declared in the project's .pumadev.yml",
+	Long: "Create the entries declared in the project's .pumadev.yml (searched from the working directory up).\n\n" +
+		"Port entries are allocated like `create`: the preferred port when it is free, else the next free block;\n" +
+		"extra domains share the first domain's port. Entries that exist but differ are reported as drift and\n" +
+		"only rewritten with --force; while the first domain drifts the others are not written either. Exits\n" +
+		"with the validation_failed code when drift is left.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProject(cmd, projectUp, true)
+	},
+}
+
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Delete the entries declared in the project's .pumadev.yml",
+	Long: "Delete the entries declared in the project's .pumadev.yml. Entries that drifted from the file are left\n" +
+		"alone unless --force is given. Port leases are kept, so a later `up` gets the same block back.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProject(cmd, projectDown, false)
+	},
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Compare the project's .pumadev.yml with the mappings dir and show drift",
+	Long: "Compare the project's .pumadev.yml with the mappings dir and show drift.\n" +
+		"Exits with the validation_failed code when an entry is missing or differs.",
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runProject(cmd, nil, true)
+	},
+}
+
+// runProject loads the project file and the mappings dir, lets apply change entries (under the directory
+// lock) and prints the resulting report. With checkDrift it fails when entries are left missing or drifted.
+func runProject(cmd *cobra.Command, apply func(*cobra.Command, *internal.ProjectConfig, string, *internal.ProjectReport) error, checkDrift bool) error {
+	r, err := newRenderer(cmd)
+	if err != nil {
+		return err
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	pc, err := internal.FindProjectConfig(wd)
+	if err != nil {
+		return err
+	}
+	if pc == nil {
+		return internal.Errorf(internal.CodeNotFound, "no %s in %s or its parents", internal.ProjectFileNames[0], wd)
+	}
+	dir, err := internal.ResolveDir(dirFlag)
+	if err != nil {
+		return err
+	}
+	if apply != nil {
+		release, err := internal.LockDir(dir)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+	entries, err := internal.LoadEntries(dir)
+	if err != nil {
+		return err
+	}
+	rep := internal.ProjectStatus(pc, dir, entries)
+	if apply != nil {
+		if err := apply(cmd, pc, dir, &rep); err != nil {
+			return err
+		}
+	}
+	if !r.IsHuman() {
+		if err := internal.Render(r, internal.ProjectView(rep)); err != nil {
+			return err
+		}
+	} else {
+		printProjectReport(cmd, rep)
+	}
+	if checkDrift && rep.Drifted() > 0 {
+		hint := "run `pumadevctl up`"
+		if apply != nil {
+			hint = "fix them or rerun with --force"
+		}
+		return internal.Errorf(internal.CodeValidationFailed, "%d of %d entries missing or drifted; %s", rep.Drifted(), len(rep.Entries), hint)
+	}
+	return nil
+}
+
+func projectUp(cmd *cobra.Command, pc *internal.ProjectConfig, dir string, rep *internal.ProjectReport) error {
+	for i := range rep.Entries {
+		pe := &rep.Entries[i]
+		overwrite := pe.State == internal.StateDrift
+		if pe.State == internal.StateOK || overwrite && !forceFlag {
+			continue
+		}
+		event, status := internal.EventCreate, "created"
+		if overwrite {
+			event, status = internal.EventUpdate, "updated"
+		}
+		var err error
+		switch {
+		case pc.Mode == internal.ModeLink:
+			change := internal.EntryChange{Domain: pe.Domain, Status: status, Type: "symlink", LinkTarget: pc.Link}
+			err = withHooks(cmd, event, dir, []internal.EntryChange{change}, func() error {
+				return internal.CreateSymlink(dir, pe.Domain, pc.Link, overwrite)
+			})
+			pe.Have = pc.Link
+		case i == 0:
+			pe.Have, err = upPrimary(cmd, pc, dir, pe.Domain, event, overwrite)
+		default:
+			// aliases share the port the primary actually has, never the preferred or "auto" one
+			base, aliasErr := internal.AliasPort(dir, rep.Entries[0])
+			if aliasErr != nil {
+				pe.State, pe.Detail = internal.StateDrift, "not written: "+aliasErr.Error()
+				continue
+			}
+			change := internal.EntryChange{Domain: pe.Domain, Status: status, Type: "file", Mapping: base}
+			err = withHooks(cmd, event, dir, []internal.EntryChange{change}, func() error {
+				return internal.WriteEntry(dir, pe.Domain, base, overwrite)
+			})
+			pe.Have = base
+		}
+		if err != nil {
+			return err
+		}
+		pe.State, pe.Detail = internal.StateCreated, ""
+		if overwrite {
+			pe.State = internal.StateUpdated
+		}
+	}
+	if pc.Mode == internal.ModePort && rep.Entries[0].State != internal.StateDrift {
+		// record roles of an existing block too, so roles added to the file get their offsets
+		roles, err := internal.ParsePortRoles(portRolesFlag, portBlockSize)
+		if err != nil {
+			return err
+		}
+		if _, err := internal.AssignDomainPorts(dir, pc.Domains[0], portBlockSize, roles, false); err != nil {
+			return err
+		}
+	}
+	return internal.RecordProject(dir, pc.Domains, pc.Root)
+}
+
+// upPrimary creates the domain owning the project's port block: on the preferred port when it is free,
+// otherwise on the next free block.
+func upPrimary(cmd *cobra.Command, pc *internal.ProjectConfig, dir, domain, event string, overwrite bool) (string, error) {
+	if pc.Port != 0 {
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return "", err
+		}
+		alloc, err := newAllocator(dir)
+		if err != nil {
+			return "", err
+		}
+		lease, undo, err := leaseBlock(cmd, alloc, domain, func() (int, error) {
+			return pc.Port, alloc.Check(domain, pc.Port, entries)
+		})
+		if err == nil {
+			return strconv.Itoa(lease.Base), writeLeasedEntry(cmd, event, dir, domain, lease, undo, overwrite, nil)
+		}
+		if internal.CodeOf(err) != internal.CodeAlreadyExists {
+			return "", err
+		}
+		if !quietFlag {
+			internal.NewFormatter(cmd.ErrOrStderr()).Warn("preferred port %d unavailable (%v); allocating another block", pc.Port, err)
+		}
+	}
+	return createAutoEntry(cmd, event, dir, domain, overwrite)
+}
+
+func projectDown(cmd *cobra.Command, pc *internal.ProjectConfig, dir string, rep *internal.ProjectReport) error {
+	// aliases first, so the domain owning the block goes last
+	for i := len(rep.Entries) - 1; i >= 0; i-- {
+		pe := &rep.Entries[i]
+		switch {
+		case pe.State == internal.StateMissing:
+			continue
+		case pe.State == internal.StateDrift && !forceFlag:
+			pe.State = internal.StateSkipped
+			continue
+		}
+		e, err := internal.ReadEntry(dir, pe.Domain)
+		if err != nil {
+			return err
+		}
+		err = withHooks(cmd, internal.EventDelete, dir, []internal.EntryChange{internal.NewEntryChange(*e, "deleted")}, func() error {
+			return internal.DeleteEntry(dir, pe.Domain)
+		})
+		if err != nil {
+			return err
+		}
+		pe.State = internal.StateRemoved
+	}
+	return nil
+}
+
+func printProjectReport(cmd *cobra.Command, rep internal.ProjectReport) {
+	f := internal.NewFormatter(cmd.OutOrStdout())
+	if !quietFlag {
+		f.KV("project", rep.Project)
+		f.KV("dir", rep.Dir)
+	}
+	for _, e := range rep.Entries {
+		switch e.State {
+		case internal.StateOK, internal.StateCreated, internal.StateUpdated, internal.StateRemoved:
+			f.Success("%-8s %s → %s", e.State, e.Domain, firstNonEmpty(e.Have, e.Want))
+		case internal.StateMissing:
+			f.Warn("%-8s %s (want %s)", e.State, e.Domain, e.Want)
+		default:
+			f.Error("%-8s %s: %s (want %s)", e.State, e.Domain, e.Detail, e.Want)
+		}
+	}
+}
+
+func firstNonEmpty(s ...string) string {
+	for _, v := range s {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func init() {
+	rootCmd.AddCommand(upCmd)
+	rootCmd.AddCommand(downCmd)
+	rootCmd.AddCommand(statusCmd)
+}
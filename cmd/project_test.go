@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+func TestUp_DriftedPrimary(t *testing.T) {
+	root := isolateCLI(t)
+	dir, project := filepath.Join(root, "puma-dev"), filepath.Join(root, "myapp")
+	for _, d := range []string{dir, project} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(project, ".pumadev.yml"), []byte("domains: [myapp, admin.myapp]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(project, filepath.Join(dir, "myapp")); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, project)
+
+	out, err := runCLI(t, "up", "--dir", dir, "-o", "json")
+	if internal.CodeOf(err) != internal.CodeValidationFailed {
+		t.Fatalf("expected up to fail while the primary drifts, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "admin.myapp")); !os.IsNotExist(err) {
+		t.Errorf("alias written although the primary is a symlink: %v", err)
+	}
+	var rep internal.ProjectReport
+	if err := json.Unmarshal([]byte(out), &rep); err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	if len(rep.Entries) != 2 || rep.Entries[0].State != internal.StateDrift || rep.Entries[1].State != internal.StateDrift {
+		t.Errorf("expected both entries drifted, got %+v", rep.Entries)
+	}
+
+	// a port entry off the preferred port: aliases wait for it, then follow its real port
+	if err := os.Remove(filepath.Join(dir, "myapp")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "myapp"), []byte("36010"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, ".pumadev.yml"), []byte("domains: [myapp, admin.myapp]\nport: 36500\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCLI(t, "up", "--dir", dir, "-o", "json"); internal.CodeOf(err) != internal.CodeValidationFailed {
+		t.Fatalf("expected up to fail while the primary is off its port, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "admin.myapp")); !os.IsNotExist(err) {
+		t.Errorf("alias written with the preferred port while the primary is elsewhere: %v", err)
+	}
+	if _, err := runCLI(t, "up", "--dir", dir, "--force", "-o", "json"); err != nil {
+		t.Fatal(err)
+	}
+	primary, _ := os.ReadFile(filepath.Join(dir, "myapp"))
+	alias, _ := os.ReadFile(filepath.Join(dir, "admin.myapp"))
+	if len(alias) == 0 || string(alias) != string(primary) {
+		t.Errorf("alias has %q, want the primary's port %q", alias, primary)
+	}
+}
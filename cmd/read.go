@@ -1,36 +1,54 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/rolling-space/pumadevctl/internal"
 	"github.com/spf13/cobra"
 )
 
 var readCmd = &cobra.Command{
-	Use:   "read <domain>",
-	Short: "Read a single mapping or symlink",
-	Args:  cobra.ExactArgs(1),
+	Use:               "read <domain>",
+	Short:             "Read a single mapping or symlink",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeDomain,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
 		dir, err := internal.ResolveDir(dirFlag)
 		if err != nil {
 			return err
 		}
-		e, err := internal.ReadEntry(dir, args[0])
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		e, err := internal.ReadEntry(dir, domain)
 		if err != nil {
 			return err
 		}
-		if jsonFlag {
-			enc := json.NewEncoder(cmd.OutOrStdout())
-			enc.SetIndent("", "  ")
-			return enc.Encode(e)
+		if !r.IsHuman() {
+			return internal.Render(r, internal.EntryView(*e))
 		}
 		f := internal.NewFormatter(cmd.OutOrStdout())
 		if e.IsSymlink {
 			f.Info("%s → %s (symlink)", e.Domain, e.LinkTarget)
-			return nil
+		} else {
+			f.Info("%s → %s", e.Domain, e.Mapping)
+		}
+		ff := f.IndentBy(2)
+		ff.KV("hosts", fmt.Sprintf("%s.%s, *.%s.%s", e.Domain, tldFlag, e.Domain, tldFlag))
+		if entries, err := internal.LoadEntries(dir); err == nil {
+			if subs := internal.Subdomains(entries, e.Domain); len(subs) > 0 {
+				ff.KV("except", strings.Join(subs, ", ")+" (own entries)")
+			}
+		}
+		if e.Meta != nil {
+			printMetaKV(ff, e.Meta)
 		}
-		f.Info("%s → %s", e.Domain, e.Mapping)
 		return nil
 	},
 }
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var renameCmd = &cobra.Command{
+	Use:               "rename <domain> <new-domain>",
+	Short:             "Rename an entry, keeping its mapping and metadata",
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		from, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		to, err := internal.NormalizeDomain(args[1], tldFlag)
+		if err != nil {
+			return err
+		}
+		release, err := internal.LockDir(dir)
+		if err != nil {
+			return err
+		}
+		defer release()
+		e, err := internal.ReadEntry(dir, from)
+		if err != nil {
+			return err
+		}
+		res := internal.NewEntryChange(*e, "renamed")
+		res.Domain, res.PreviousDomain = to, from
+		err = withHooks(cmd, internal.EventRename, dir, []internal.EntryChange{res}, func() error {
+			if err := internal.RenameEntry(dir, from, to, forceFlag); err != nil {
+				return err
+			}
+			return internal.UpdateLedger(internal.LedgerPath(), func(l *internal.Ledger) bool {
+				return l.Rename(dir, from, to)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		if r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("renamed: %s → %s", from, to)
+			}
+			return nil
+		}
+		return internal.Render(r, internal.EntryChangeView(res))
+	},
+}
+
+func init() { rootCmd.AddCommand(renameCmd) }
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var resolveCmd = &cobra.Command{
+	Use:   "resolve <hostname>",
+	Short: "Show which entry puma-dev would serve a hostname from, and why",
+	Long: "Show which entry puma-dev would serve a hostname from, and why.\n\n" +
+		"puma-dev drops the TLD and tries the name, then each parent: admin.myapp.test is served by an\n" +
+		"admin.myapp entry if there is one, else by myapp, else by the \"default\" entry, else it answers 404.\n" +
+		"Exits with the not_found code when no entry would serve the host.",
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
+		dir, err := internal.ResolveDir(dirFlag)
+		if err != nil {
+			return err
+		}
+		entries, err := internal.LoadEntries(dir)
+		if err != nil {
+			return err
+		}
+		res, err := internal.ResolveHost(entries, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		if !r.IsHuman() {
+			if err := internal.Render(r, internal.ResolutionView(*res)); err != nil {
+				return err
+			}
+			return resolveError(res)
+		}
+		f := internal.NewFormatter(cmd.OutOrStdout())
+		if res.Entry != nil {
+			target := res.Entry.Mapping
+			if res.Entry.IsSymlink {
+				target = res.Entry.LinkTarget + " (symlink)"
+			}
+			f.Success("%s → %s → %s", res.Host, res.Domain, target)
+		} else {
+			f.Error("%s → no entry (404)", res.Host)
+		}
+		if quietFlag {
+			return resolveError(res)
+		}
+		f.KV("match", res.Match)
+		f.KV("why", res.Reason)
+		f.Subheader("Lookup")
+		for _, s := range res.Steps {
+			if s.Found {
+				f.Bullet("✔ " + s.Name)
+			} else {
+				f.Bullet("✖ " + s.Name)
+			}
+		}
+		return resolveError(res)
+	},
+}
+
+// resolveError fails the command with CodeNotFound when no entry would serve the host.
+func resolveError(res *internal.Resolution) error {
+	if res.Entry != nil {
+		return nil
+	}
+	return internal.Errorf(internal.CodeNotFound, "no entry serves %s", res.Host)
+}
+
+func init() { rootCmd.AddCommand(resolveCmd) }
@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/rolling-space/pumadevctl/internal"
@@ -15,21 +18,113 @@ var (
 	dirFlag       string
 	forceFlag     bool
 	jsonFlag      bool
+	outputFlag    string
 	quietFlag     bool
+	verboseFlag   bool
 	portMinFlag   int
 	portMaxFlag   int
 	portBlockSize int
+	portRolesFlag string
+	tldFlag       string
+	profileFlag   string
+	contextFlag   string
 )
 
+// appConfig is the effective configuration (all layers and flags), set before every command runs.
+var appConfig *internal.ResolvedConfig
+
+// configFlags maps config keys to the flags overriding them.
+var configFlags = []struct{ key, flag string }{
+	{"dir", "dir"},
+	{"port_min", "port-min"},
+	{"port_max", "port-max"},
+	{"port_block_size", "port-block-size"},
+	{"port_roles", "roles"},
+	{"tld", "tld"},
+	{"context", "context"},
+}
+
 var rootCmd = &cobra.Command{
 	Use:   "pumadevctl",
 	Short: "Manage puma-dev mappings (~/.puma-dev) with CRUD, list, validate, cleanup",
+	Long: `Manage puma-dev mappings (~/.puma-dev) with CRUD, list, validate, cleanup.
+
+Exit codes:
+  0  success
+  1  unexpected error
+  2  usage error (bad arguments or flags)
+  3  not found (entry, CA or file)
+  4  already exists (use --force to overwrite)
+  5  validation failed (validate found unreachable or unhealthy entries)
+  6  mappings directory locked by another pumadevctl process
+  7  no free port block in the configured range
+  8  configuration error
+  9  hook failed (a failing pre hook aborts the change)
+
+exec exits with the status of the command it runs.
+
+With --json or another machine output format, errors are printed to stderr as
+{"error":{"code":"...","exit_code":N,"message":"..."}}.`,
+	SilenceErrors: true,
+	SilenceUsage:  true,
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return internal.WithCode(internal.CodeUsage, err)
+	})
+	addPluginCommands()
+	addCompletionInstall()
+	usageArgs(rootCmd)
+	cmd, err := rootCmd.ExecuteC()
+	if err == nil {
+		return
+	}
+	var status internal.ExitStatus
+	if errors.As(err, &status) {
+		os.Exit(int(status))
+	}
+	if internal.CodeOf(err) == internal.CodeError && isUsageError(err) {
+		err = internal.WithCode(internal.CodeUsage, err)
+	}
+	reportError(cmd, err)
+	os.Exit(internal.CodeOf(err).ExitCode())
+}
+
+// usageArgs tags positional argument errors of every command as usage errors.
+func usageArgs(cmd *cobra.Command) {
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(c *cobra.Command, a []string) error {
+			return internal.WithCode(internal.CodeUsage, args(c, a))
+		}
+	}
+	for _, c := range cmd.Commands() {
+		usageArgs(c)
+	}
+}
+
+// isUsageError recognises the argument errors cobra returns without going through Args or the flag error func.
+func isUsageError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "unknown command") || strings.HasPrefix(msg, "required flag") ||
+		strings.HasPrefix(msg, "if any flags in the group")
+}
+
+// reportError prints err to the command's stderr: as an {"error":{...}} document in machine output modes,
+// else as text followed by a usage hint for usage errors.
+func reportError(cmd *cobra.Command, err error) {
+	if cmd == nil {
+		cmd = rootCmd
+	}
+	w := cmd.ErrOrStderr()
+	if jsonFlag || machineOutput() {
+		enc := json.NewEncoder(w)
+		_ = enc.Encode(internal.NewErrorDocument(err))
+		return
+	}
+	fmt.Fprintln(w, "Error:", err)
+	if internal.CodeOf(err) == internal.CodeUsage {
+		fmt.Fprintf(w, "Run '%s --help' for usage.\n", cmd.CommandPath())
 	}
 }
 
@@ -43,35 +138,84 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVarP(&dirFlag, "dir", "d", defaultDir, "directory for puma-dev entries")
 	rootCmd.PersistentFlags().BoolVarP(&forceFlag, "force", "f", false, "force operation without interactive confirmations")
-	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "output JSON when supported")
+	rootCmd.PersistentFlags().BoolVar(&jsonFlag, "json", false, "output JSON (shorthand for -o json)")
+	rootCmd.PersistentFlags().StringVarP(&outputFlag, "output", "o", internal.OutputTable, "output format: "+strings.Join(internal.OutputFormats, "|")+"TEXT")
 	rootCmd.PersistentFlags().BoolVarP(&quietFlag, "quiet", "q", false, "suppress non-essential output")
+	rootCmd.PersistentFlags().BoolVar(&verboseFlag, "verbose", false, "explain decisions (e.g. why port blocks were skipped) on stderr")
 
 	// Port allocation controls
 	rootCmd.PersistentFlags().IntVar(&portMinFlag, "port-min", 36000, "minimum port for auto allocation (inclusive)")
 	rootCmd.PersistentFlags().IntVar(&portMaxFlag, "port-max", 37000, "maximum port for auto allocation (inclusive)")
 	rootCmd.PersistentFlags().IntVar(&portBlockSize, "port-block-size", 10, "number of consecutive ports reserved per domain")
+	rootCmd.PersistentFlags().StringVar(&tldFlag, "tld", internal.DefaultTLD, "top-level domain puma-dev serves apps under")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "config profile to apply (default $PUMADEVCTL_PROFILE)")
+	rootCmd.PersistentFlags().StringVar(&contextFlag, "context", "", "named mappings directory from config to work on (see \"pumadevctl context list\")")
+	_ = rootCmd.RegisterFlagCompletionFunc("dir", completeDirs)
+	_ = rootCmd.RegisterFlagCompletionFunc("output", completeOutput)
+	_ = rootCmd.RegisterFlagCompletionFunc("profile", completeProfile)
+	_ = rootCmd.RegisterFlagCompletionFunc("context", completeContextFlag)
 
-	// Load config from XDG and use as defaults unless flags were provided.
+	// Resolve the config before every command and refuse to run with semantically invalid values.
 	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
-		cfg, err := internal.LoadAppConfig()
-		if err != nil {
-			return err
-		}
-		// If the flag was not set by the user, apply config values.
-		if f := cmd.Flags().Lookup("dir"); f != nil && !f.Changed {
-			dirFlag = cfg.Dir
+		return prepareCommand(cmd)
+	}
+}
+
+// prepareCommand checks the output format and resolves the config, failing on semantically invalid values.
+func prepareCommand(cmd *cobra.Command) error {
+	if err := setupOutput(cmd); err != nil {
+		return err
+	}
+	if err := loadConfig(cmd); err != nil {
+		return err
+	}
+	for _, issue := range appConfig.Validate() {
+		if issue.Severity != internal.SeverityError {
+			continue
 		}
-		if f := cmd.Flags().Lookup("port-min"); f != nil && !f.Changed && cfg.PortMin != 0 {
-			portMinFlag = cfg.PortMin
+		code := internal.CodeConfig
+		if issue.Origin == internal.OriginFlag {
+			code = internal.CodeUsage
 		}
-		if f := cmd.Flags().Lookup("port-max"); f != nil && !f.Changed && cfg.PortMax != 0 {
-			portMaxFlag = cfg.PortMax
+		from := ""
+		if issue.Origin != "" {
+			from = " (from " + strings.TrimSpace(issue.Origin+" "+issue.Source) + ")"
 		}
-		if f := cmd.Flags().Lookup("port-block-size"); f != nil && !f.Changed && cfg.PortBlockSize != 0 {
-			portBlockSize = cfg.PortBlockSize
+		return internal.Errorf(code, "invalid %s: %s%s; see `pumadevctl config validate`", issue.Key, issue.Message, from)
+	}
+	_ = runtime.GOOS // keep import used in case future OS-specific defaults are needed
+	_ = time.Second  // keep import used for potential timeouts in future flags
+	return nil
+}
+
+// setupOutput applies --json and checks the output format.
+func setupOutput(cmd *cobra.Command) error {
+	if jsonFlag && !cmd.Flags().Changed("output") {
+		outputFlag = internal.OutputJSON
+	}
+	if _, err := internal.NewRenderer(outputFlag, nil); err != nil {
+		return internal.WithCode(internal.CodeUsage, err)
+	}
+	return nil
+}
+
+// loadConfig resolves the config layers (defaults, system, user, project, env), applies explicitly set flags
+// and the selected context on top and copies the result into the flag variables.
+func loadConfig(cmd *cobra.Command) error {
+	cfg, err := internal.ResolveConfig(profileFlag)
+	if err != nil {
+		return err
+	}
+	for _, b := range configFlags {
+		if f := cmd.Flags().Lookup(b.flag); f != nil && f.Changed {
+			if err := cfg.Set(b.key, f.Value.String(), internal.OriginFlag, "--"+b.flag); err != nil {
+				return internal.WithCode(internal.CodeUsage, err)
+			}
 		}
-		_ = runtime.GOOS // keep import used in case future OS-specific defaults are needed
-		_ = time.Second  // keep import used for potential timeouts in future flags
-		return nil
 	}
+	_ = cfg.ApplyContext() // an unknown context is reported by Validate
+	appConfig = cfg
+	dirFlag, tldFlag, portRolesFlag = cfg.Dir, cfg.TLD, cfg.PortRoles
+	portMinFlag, portMaxFlag, portBlockSize = cfg.PortMin, cfg.PortMax, cfg.PortBlockSize
+	return nil
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+func TestVersionShorthand(t *testing.T) {
+	isolateCLI(t)
+	out, err := runCLI(t, "-v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out) != strings.TrimSpace(rootCmd.Version) {
+		t.Errorf("-v printed %q, want the version", out)
+	}
+}
+
+func TestFlagUsageHasNoValueNames(t *testing.T) {
+	// pflag turns a backticked word in a usage string into the flag's value name
+	isolateCLI(t)
+	out, err := runCLI(t, "--help")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "--context string") {
+		t.Errorf("expected --context to take a string in help:\n%s", out)
+	}
+}
+
+func TestReportError_JSON(t *testing.T) {
+	root := isolateCLI(t)
+	_, err := runCLI(t, "read", "nope", "--dir", root, "-o", "json")
+	if internal.CodeOf(err) != internal.CodeNotFound {
+		t.Fatalf("expected not_found, got %v", err)
+	}
+	var buf bytes.Buffer
+	rootCmd.SetErr(&buf)
+	reportError(rootCmd, err)
+	var doc internal.ErrorDocument
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("%v: %q", err, buf.String())
+	}
+	if doc.Error.Code != internal.CodeNotFound || doc.Error.ExitCode != 3 {
+		t.Errorf("unexpected error document %+v", doc)
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var schemaItem bool
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema [command]",
+	Short: "Print the JSON Schema (draft 2020-12) of a command's -o json output",
+	Long: "Print the JSON Schema (draft 2020-12) of a command's -o json output.\n\n" +
+		"Documents carry \"schema_version\" and \"kind\"; schema_version only changes on breaking changes. " +
+		"Without arguments, lists the commands that have a schema. With --item, prints the schema of one -o jsonl record.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			f := internal.NewFormatter(cmd.OutOrStdout())
+			for _, s := range internal.OutputSchemas() {
+				f.KV(s.Command, s.Kind)
+			}
+			return nil
+		}
+		s, err := internal.LookupOutputSchema(strings.Join(args, " "))
+		if err != nil {
+			return err
+		}
+		doc := s.DocumentSchema()
+		if schemaItem {
+			doc = s.ItemSchema()
+		}
+		enc := json.NewEncoder(cmd.OutOrStdout())
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	},
+}
+
+func init() {
+	schemaCmd.Flags().BoolVar(&schemaItem, "item", false, "print the schema of a single -o jsonl record instead of the document")
+	rootCmd.AddCommand(schemaCmd)
+}
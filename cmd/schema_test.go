@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/rolling-space/pumadevctl/internal"
+)
+
+// TestCommandOutputsMatchSchemas runs commands through the CLI and checks what they print, not hand-built
+// views, against the published schemas.
+func TestCommandOutputsMatchSchemas(t *testing.T) {
+	root := isolateCLI(t)
+	dir := filepath.Join(root, "puma-dev")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	port := strconv.Itoa(ln.Addr().(*net.TCPAddr).Port)
+	if err := internal.WriteEntry(dir, "api", port, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := internal.CreateSymlink(dir, "docs", root, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runCLI(t, "tag", "--dir", dir, "api", "payments"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"list"},
+		{"read", "api"},
+		{"read", "docs"},
+		{"validate"},
+	} {
+		s, err := internal.LookupOutputSchema(args[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := runCLI(t, append(args, "--dir", dir, "-o", "json")...)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if err := s.CheckDocument([]byte(out)); err != nil {
+			t.Errorf("%v -o json: %v\n%s", args, err, out)
+		}
+		out, err = runCLI(t, append(args, "--dir", dir, "-o", "jsonl")...)
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			if err := s.CheckItem([]byte(line)); err != nil {
+				t.Errorf("%v -o jsonl: %v\n%s", args, err, line)
+			}
+		}
+	}
+}
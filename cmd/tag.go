@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"github.com/rolling-space/pumadevctl/internal"
+	"github.com/spf13/cobra"
+)
+
+var tagRemove bool
+
+var tagCmd = &cobra.Command{
+	Use:               "tag <domain> [tag...]",
+	Short:             "Add (or with --remove, remove) tags on a domain; lists tags when none are given",
+	Args:              cobra.MinimumNArgs(1),
+	ValidArgsFunction: completeDomain,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tags := args[1:]
+		domain, m, err := editMeta(args[0], func(m *internal.Meta) bool {
+			if len(tags) == 0 {
+				return false
+			}
+			if tagRemove {
+				m.RemoveTags(tags...)
+			} else {
+				m.AddTags(tags...)
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+		return printMeta(cmd, domain, m)
+	},
+}
+
+func init() {
+	tagCmd.Flags().BoolVar(&tagRemove, "remove", false, "remove the given tags instead of adding them")
+	rootCmd.AddCommand(tagCmd)
+}
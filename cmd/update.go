@@ -1,9 +1,6 @@
 package cmd
 
 import (
-	"encoding/json"
-	"fmt"
-
 	"github.com/rolling-space/pumadevctl/internal"
 	"github.com/spf13/cobra"
 )
@@ -11,52 +8,73 @@ import (
 var updateLinkTarget string
 
 var updateCmd = &cobra.Command{
-	Use:   "update <domain> <mapping>",
-	Short: "Update an existing entry (file content) or use --link to repoint a symlink",
-	Args:  cobra.RangeArgs(1, 2),
+	Use:               "update <domain> <mapping>",
+	Short:             "Update an existing entry (file content) or use --link to repoint a symlink",
+	Args:              cobra.RangeArgs(1, 2),
+	ValidArgsFunction: completeDomain,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		r, err := newRenderer(cmd)
+		if err != nil {
+			return err
+		}
 		dir, err := internal.ResolveDir(dirFlag)
 		if err != nil {
 			return err
 		}
-		domain := args[0]
+		domain, err := internal.LookupDomain(dir, args[0], tldFlag)
+		if err != nil {
+			return err
+		}
+		release, err := internal.LockDir(dir)
+		if err != nil {
+			return err
+		}
+		defer release()
 		if updateLinkTarget != "" {
-			if err := internal.UpdateSymlink(dir, domain, updateLinkTarget); err != nil {
+			res := internal.EntryChange{Domain: domain, Status: "updated", Type: "symlink", LinkTarget: updateLinkTarget}
+			err := withHooks(cmd, internal.EventUpdate, dir, []internal.EntryChange{res}, func() error {
+				return internal.UpdateSymlink(dir, domain, updateLinkTarget)
+			})
+			if err != nil {
 				return err
 			}
-			if !quietFlag && !jsonFlag {
-				internal.NewFormatter(cmd.OutOrStdout()).Success("updated symlink: %s → %s", domain, updateLinkTarget)
+			if r.IsHuman() {
+				if !quietFlag {
+					internal.NewFormatter(cmd.OutOrStdout()).Success("updated symlink: %s → %s", domain, updateLinkTarget)
+				}
+				return nil
 			}
-			if jsonFlag {
-				out := map[string]string{"domain": domain, "link_target": updateLinkTarget, "type": "symlink"}
-				b, _ := json.MarshalIndent(out, "", "  ")
-				fmt.Fprintln(cmd.OutOrStdout(), string(b))
-			}
-			return nil
+			return internal.Render(r, internal.EntryChangeView(res))
 		}
 		if len(args) < 2 {
-			return fmt.Errorf("mapping required unless --link is set")
+			return internal.Errorf(internal.CodeUsage, "mapping required unless --link is set")
 		}
 		mapping := args[1]
 		if _, err := internal.ParseMapping(mapping); err != nil {
-			return err
+			return internal.WithCode(internal.CodeUsage, err)
 		}
-		if err := internal.UpdateEntry(dir, domain, mapping); err != nil {
+		res := internal.EntryChange{Domain: domain, Status: "updated", Type: "file", Mapping: mapping}
+		err = withHooks(cmd, internal.EventUpdate, dir, []internal.EntryChange{res}, func() error {
+			if err := internal.UpdateEntry(dir, domain, mapping); err != nil {
+				return err
+			}
+			return recordLease(cmd, dir, domain, mapping)
+		})
+		if err != nil {
 			return err
 		}
-		if !quietFlag && !jsonFlag {
-			internal.NewFormatter(cmd.OutOrStdout()).Success("updated: %s → %s", domain, mapping)
-		}
-		if jsonFlag {
-			out := map[string]string{"domain": domain, "mapping": mapping, "type": "file"}
-			b, _ := json.MarshalIndent(out, "", "  ")
-			fmt.Fprintln(cmd.OutOrStdout(), string(b))
+		if r.IsHuman() {
+			if !quietFlag {
+				internal.NewFormatter(cmd.OutOrStdout()).Success("updated: %s → %s", domain, mapping)
+			}
+			return nil
 		}
-		return nil
+		return internal.Render(r, internal.EntryChangeView(res))
 	},
 }
 
 func init() {
 	updateCmd.Flags().StringVar(&updateLinkTarget, "link", "", "repoint an existing symlink to this path")
+	_ = updateCmd.RegisterFlagCompletionFunc("link", completeDirs)
 	rootCmd.AddCommand(updateCmd)
 }
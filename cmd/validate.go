@@ -1,59 +1,155 @@
 package cmd
 
 import (
-	"encoding/json"
+	"strings"
+	"time"
 
 	"github.com/rolling-space/pumadevctl/internal"
 	"github.com/spf13/cobra"
 )
 
-var timeoutMs int
+var (
+	timeoutMs          int
+	validateTLS        bool
+	validateTLSCA      string
+	validateExpiryDays int
+	validateFilter     filterFlags
+	validateAll        bool
+)
 
 var validateCmd = &cobra.Command{
 	Use:   "validate",
 	Short: "Validate reachability of mappings (TCP dial)",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		dir, err := internal.ResolveDir(dirFlag)
+		r, err := newRenderer(cmd)
 		if err != nil {
 			return err
 		}
-		entries, err := internal.LoadEntries(dir)
+		filter, err := validateFilter.build(timeoutMs)
 		if err != nil {
 			return err
 		}
-		results := internal.ValidateEntries(entries, timeoutMs)
-		if jsonFlag {
-			enc := json.NewEncoder(cmd.OutOrStdout())
-			enc.SetIndent("", "  ")
-			return enc.Encode(results)
+		entries, dirs, err := loadScope(validateAll)
+		if err != nil {
+			return err
+		}
+		conflicts := contextConflicts(entries, dirs)
+		opts := internal.ValidateOptions{
+			TimeoutMs:       timeoutMs,
+			TLS:             validateTLS,
+			TLD:             tldFlag,
+			ExpiryThreshold: time.Duration(validateExpiryDays) * 24 * time.Hour,
+		}
+		if validateTLSCA != "" {
+			pool, err := internal.LoadCertPool(validateTLSCA)
+			if err != nil {
+				return err
+			}
+			opts.RootCAs = pool
+		}
+		var results []internal.ValidationResult
+		if filter.NeedsReachability() {
+			results = filter.ApplyResults(internal.ValidateEntriesWith(entries, opts))
+		} else {
+			results = internal.ValidateEntriesWith(filter.Apply(entries), opts)
+		}
+		if !r.IsHuman() {
+			if err := internal.Render(r, internal.ValidationView(results)); err != nil {
+				return err
+			}
+			printContextConflicts(cmd, conflicts)
+			return validationError(results, conflicts)
 		}
 		// pretty print
 		f := internal.NewFormatter(cmd.OutOrStdout())
 		ok := 0
 		bad := 0
+		tlsIssues := 0
 		for _, r := range results {
 			if r.IsSymlink {
-				f.Info("%s (symlink) → %s", r.Domain, r.LinkTarget)
+				f.Info("%s (symlink) → %s", r.QualifiedDomain(), r.LinkTarget)
 				continue
 			}
 			if r.Reachable {
-				f.Success("✔ %s → %s", r.Domain, r.Mapping)
+				f.Success("✔ %s → %s", r.QualifiedDomain(), r.Mapping)
 				ok++
+				if r.TLS != nil && !printTLSResult(f.IndentBy(2), r.TLS) {
+					tlsIssues++
+				}
 			} else {
-				f.Error("✖ %s → %s  (%s)", r.Domain, r.Mapping, r.Reason)
+				f.Error("✖ %s → %s  (%s)", r.QualifiedDomain(), r.Mapping, r.Reason)
 				bad++
 			}
 		}
+		printContextConflicts(cmd, conflicts)
 		if !quietFlag {
 			f.Subheader("Summary")
 			f.KV("reachable", ok)
 			f.KV("unreachable", bad)
+			if validateTLS {
+				f.KV("tls issues", tlsIssues)
+			}
+			if len(conflicts) > 0 {
+				f.KV("port conflicts", len(conflicts))
+			}
 		}
-		return nil
+		return validationError(results, conflicts)
 	},
 }
 
+// validationError fails the command with CodeValidationFailed when any entry is unreachable, has TLS issues
+// or shares ports with an entry of another context.
+func validationError(results []internal.ValidationResult, conflicts []internal.PortConflict) error {
+	bad, tlsIssues := internal.ValidationFailures(results)
+	switch {
+	case bad == 0 && tlsIssues == 0 && len(conflicts) == 0:
+		return nil
+	case len(conflicts) > 0:
+		return internal.Errorf(internal.CodeValidationFailed, "validation failed: %d unreachable, %d with TLS issues, %d port conflicts between contexts", bad, tlsIssues, len(conflicts))
+	}
+	return internal.Errorf(internal.CodeValidationFailed, "validation failed: %d unreachable, %d with TLS issues", bad, tlsIssues)
+}
+
+// printTLSResult prints certificate details and reports whether the certificate looks healthy. A port
+// without TLS is not an issue.
+func printTLSResult(f *internal.Formatter, t *internal.TLSResult) bool {
+	if t.Status == internal.TLSNone {
+		f.Info("tls: none (the port speaks plain HTTP)")
+		return true
+	}
+	if t.Error != "" {
+		f.Error("tls: handshake failed (%s)", t.Error)
+		return false
+	}
+	f.KV("subject", t.Subject)
+	f.KV("sans", strings.Join(t.DNSNames, ", "))
+	if t.NotAfter != nil {
+		f.KV("expires", t.NotAfter.Format(time.RFC3339))
+	}
+	healthy := true
+	if t.Verified {
+		f.KV("chain", "verified")
+	} else {
+		f.Error("chain: %s", t.VerifyError)
+		healthy = false
+	}
+	if !t.HostnameOK {
+		f.Warn("hostname: certificate does not cover this domain")
+		healthy = false
+	}
+	if t.ExpiringSoon {
+		f.Warn("expires within %d days", validateExpiryDays)
+		healthy = false
+	}
+	return healthy
+}
+
 func init() {
 	validateCmd.Flags().IntVar(&timeoutMs, "timeout", 500, "TCP dial timeout in milliseconds")
+	validateCmd.Flags().BoolVar(&validateTLS, "tls", false, "perform a TLS handshake and report certificate details")
+	validateCmd.Flags().StringVar(&validateTLSCA, "tls-ca", "", "PEM file with CA certificates to verify against (default: system roots)")
+	validateCmd.Flags().IntVar(&validateExpiryDays, "expiry-warn-days", 14, "flag certificates expiring within this many days")
+	validateCmd.Flags().BoolVar(&validateAll, "all-contexts", false, "validate the entries of every context")
+	addFilterFlags(validateCmd, &validateFilter)
 	rootCmd.AddCommand(validateCmd)
 }
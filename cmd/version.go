@@ -12,7 +12,7 @@ func init() {
 		Use:   "version",
 		Short: "Show detailed version information",
 		Run: func(cmd *cobra.Command, args []string) {
-			fmt.Println(internal.VersionLong())
+			fmt.Fprintln(cmd.OutOrStdout(), internal.VersionLong())
 		},
 	}
 	rootCmd.AddCommand(cmd)
@@ -1,6 +1,10 @@
 package internal
 
-import "testing"
+import (
+	"fmt"
+	"net"
+	"testing"
+)
 
 func TestFindNextAvailablePortBlock_Basic(t *testing.T) {
 	entries := []Entry{
@@ -40,3 +44,49 @@ func TestFindNextAvailablePortBlock_Overlaps(t *testing.T) {
 		t.Fatalf("expected 36030, got %d", base)
 	}
 }
+
+type busyPorts map[int]bool
+
+func (b busyPorts) Probe(port int) error {
+	if b[port] {
+		return fmt.Errorf("port %d in use on 127.0.0.1", port)
+	}
+	return nil
+}
+
+func TestAllocator_ProbesWholeBlock(t *testing.T) {
+	a := &Allocator{Min: 36000, Max: 36049, Block: 10, Prober: busyPorts{36009: true, 36013: true}}
+	entries := []Entry{{Domain: "b.test", Mapping: "36020"}}
+	base, err := a.Allocate("new.test", entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if base != 36030 {
+		t.Fatalf("expected 36030, got %d", base)
+	}
+	want := []string{"port 36009 in use on 127.0.0.1", "port 36013 in use on 127.0.0.1", "overlaps b.test's entry 36020-36029"}
+	if len(a.Skipped) != len(want) {
+		t.Fatalf("expected %d skipped blocks, got %#v", len(want), a.Skipped)
+	}
+	for i, s := range a.Skipped {
+		if s.Base != 36000+10*i || s.Reason != want[i] {
+			t.Errorf("skipped[%d] = %#v, want base %d reason %q", i, s, 36000+10*i, want[i])
+		}
+	}
+}
+
+func TestListenProber(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("cannot listen on loopback:", err)
+	}
+	defer ln.Close()
+	port := ln.Addr().(*net.TCPAddr).Port
+	if err := (ListenProber{Hosts: []string{"127.0.0.1"}}).Probe(port); err == nil {
+		t.Fatalf("expected port %d to probe busy", port)
+	}
+	ln.Close()
+	if err := (ListenProber{Hosts: []string{"127.0.0.1"}}).Probe(port); err != nil {
+		t.Fatalf("expected port %d to probe free after close: %v", port, err)
+	}
+}
@@ -0,0 +1,331 @@
+package internal
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultTLD is the top-level domain puma-dev serves apps under.
+const DefaultTLD = "test"
+
+const (
+	caCertFile = "cert.pem"
+	caKeyFile  = "key.pem"
+)
+
+// CA is a loaded certificate authority able to sign leaf certificates.
+type CA struct {
+	Dir  string
+	Cert *x509.Certificate
+	Key  crypto.Signer
+}
+
+// CertInfo describes a certificate on disk.
+type CertInfo struct {
+	Domain    string    `json:"domain,omitempty"`
+	Subject   string    `json:"subject"`
+	DNSNames  []string  `json:"dns_names,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	CertPath  string    `json:"cert_path"`
+	KeyPath   string    `json:"key_path,omitempty"`
+	Source    string    `json:"source,omitempty"` // "generated" or the directory a CA was reused from
+}
+
+// CADir returns where pumadevctl keeps its local CA.
+func CADir() string { return filepath.Join(XDGDataDir(), "ca") }
+
+// CertsDir returns where issued leaf certificates are written.
+func CertsDir() string { return filepath.Join(XDGDataDir(), "certs") }
+
+// DefaultPumaDevCADir returns the directory where puma-dev stores its own CA on this OS.
+func DefaultPumaDevCADir() string {
+	home, _ := os.UserHomeDir()
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(home, "Library", "Application Support", "io.puma.dev")
+	}
+	return filepath.Join(home, ".puma-dev-ssl")
+}
+
+// FindPumaDevCA returns the first directory among candidates holding a puma-dev CA (cert.pem + key.pem).
+// Empty candidates are skipped; ok is false when none is found.
+func FindPumaDevCA(candidates ...string) (string, bool) {
+	for _, dir := range candidates {
+		if dir == "" {
+			continue
+		}
+		if fileExists(filepath.Join(dir, caCertFile)) && fileExists(filepath.Join(dir, caKeyFile)) {
+			return dir, true
+		}
+	}
+	return "", false
+}
+
+// InitCA creates a CA in dir. When reuseFrom is non-empty, the CA found there is copied instead of generating one.
+// An existing CA in dir is only replaced when overwrite is true.
+func InitCA(dir, reuseFrom string, overwrite bool) (*CertInfo, error) {
+	certPath := filepath.Join(dir, caCertFile)
+	keyPath := filepath.Join(dir, caKeyFile)
+	if !overwrite && fileExists(certPath) {
+		return nil, Errorf(CodeAlreadyExists, "CA already exists in %s", dir)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	if reuseFrom != "" {
+		src, err := LoadCA(reuseFrom)
+		if err != nil {
+			return nil, fmt.Errorf("reuse puma-dev CA: %w", err)
+		}
+		if err := copyFile(filepath.Join(reuseFrom, caCertFile), certPath, 0644); err != nil {
+			return nil, err
+		}
+		if err := copyFile(filepath.Join(reuseFrom, caKeyFile), keyPath, 0600); err != nil {
+			return nil, err
+		}
+		info := certInfo(src.Cert, certPath, keyPath)
+		info.Source = reuseFrom
+		return &info, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	host, _ := os.Hostname()
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "pumadevctl local CA", OrganizationalUnit: []string{host}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyPair(certPath, keyPath, der, key); err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	info := certInfo(cert, certPath, keyPath)
+	info.Source = "generated"
+	return &info, nil
+}
+
+// LoadCA reads cert.pem and key.pem from dir.
+func LoadCA(dir string) (*CA, error) {
+	certPEM, err := os.ReadFile(filepath.Join(dir, caCertFile))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, Errorf(CodeNotFound, "no CA found in %s (run `pumadevctl cert ca init`)", dir)
+		}
+		return nil, err
+	}
+	keyPEM, err := os.ReadFile(filepath.Join(dir, caKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	cert, err := parseCertPEM(certPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Join(dir, caCertFile), err)
+	}
+	if !cert.IsCA {
+		return nil, fmt.Errorf("certificate in %s is not a CA", dir)
+	}
+	key, err := parseKeyPEM(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Join(dir, caKeyFile), err)
+	}
+	return &CA{Dir: dir, Cert: cert, Key: key}, nil
+}
+
+// Issue signs a leaf certificate for <domain>.<tld> and *.<domain>.<tld> and writes it to outDir
+// as <domain>.pem and <domain>-key.pem.
+func (ca *CA) Issue(domain, tld, outDir string, validity time.Duration) (*CertInfo, error) {
+	if domain == "" {
+		return nil, errors.New("domain is required")
+	}
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	if err := os.MkdirAll(outDir, 0700); err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	host := domain + "." + tld
+	now := time.Now()
+	notAfter := now.Add(validity)
+	if notAfter.After(ca.Cert.NotAfter) {
+		notAfter = ca.Cert.NotAfter
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host, "*." + host},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, key.Public(), ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	certPath := filepath.Join(outDir, domain+".pem")
+	keyPath := filepath.Join(outDir, domain+"-key.pem")
+	if err := writeKeyPair(certPath, keyPath, der, key); err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	info := certInfo(cert, certPath, keyPath)
+	info.Domain = domain
+	return &info, nil
+}
+
+// ListCerts returns the leaf certificates issued into dir, sorted by domain.
+func ListCerts(dir string) ([]CertInfo, error) {
+	items, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var out []CertInfo
+	for _, de := range items {
+		name := de.Name()
+		if de.IsDir() || !strings.HasSuffix(name, ".pem") || strings.HasSuffix(name, "-key.pem") {
+			continue
+		}
+		full := filepath.Join(dir, name)
+		b, err := os.ReadFile(full)
+		if err != nil {
+			continue
+		}
+		cert, err := parseCertPEM(b)
+		if err != nil {
+			continue
+		}
+		domain := strings.TrimSuffix(name, ".pem")
+		keyPath := filepath.Join(dir, domain+"-key.pem")
+		if !fileExists(keyPath) {
+			keyPath = ""
+		}
+		info := certInfo(cert, full, keyPath)
+		info.Domain = domain
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Domain < out[j].Domain })
+	return out, nil
+}
+
+func certInfo(c *x509.Certificate, certPath, keyPath string) CertInfo {
+	return CertInfo{
+		Subject:   c.Subject.String(),
+		DNSNames:  c.DNSNames,
+		NotBefore: c.NotBefore.UTC(),
+		NotAfter:  c.NotAfter.UTC(),
+		CertPath:  certPath,
+		KeyPath:   keyPath,
+	}
+}
+
+func parseCertPEM(b []byte) (*x509.Certificate, error) {
+	block, _ := pem.Decode(b)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, errors.New("no PEM certificate found")
+	}
+	return x509.ParseCertificate(block.Bytes)
+}
+
+// parseKeyPEM accepts PKCS#1 RSA (as written by puma-dev), SEC 1 EC and PKCS#8 keys.
+func parseKeyPEM(b []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM key found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	k, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key := k.(type) {
+	case *rsa.PrivateKey:
+		return key, nil
+	case *ecdsa.PrivateKey:
+		return key, nil
+	case crypto.Signer:
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %T", k)
+}
+
+func writeKeyPair(certPath, keyPath string, der []byte, key *ecdsa.PrivateKey) error {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+}
+
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, perm)
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInitCAAndIssue(t *testing.T) {
+	caDir := filepath.Join(t.TempDir(), "ca")
+	if _, err := InitCA(caDir, "", false); err != nil {
+		t.Fatalf("init CA: %v", err)
+	}
+	if _, err := InitCA(caDir, "", false); err == nil {
+		t.Fatalf("expected error when CA already exists")
+	}
+	ca, err := LoadCA(caDir)
+	if err != nil {
+		t.Fatalf("load CA: %v", err)
+	}
+	outDir := t.TempDir()
+	info, err := ca.Issue("myapp", "test", outDir, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	want := []string{"myapp.test", "*.myapp.test"}
+	if len(info.DNSNames) != 2 || info.DNSNames[0] != want[0] || info.DNSNames[1] != want[1] {
+		t.Fatalf("unexpected SANs: %v", info.DNSNames)
+	}
+
+	b, err := os.ReadFile(info.CertPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := parseCertPEM(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	for _, host := range []string{"myapp.test", "admin.myapp.test"} {
+		if _, err := leaf.Verify(x509.VerifyOptions{Roots: roots, DNSName: host}); err != nil {
+			t.Fatalf("verify %s: %v", host, err)
+		}
+	}
+
+	certs, err := ListCerts(outDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 || certs[0].Domain != "myapp" || certs[0].KeyPath == "" {
+		t.Fatalf("unexpected list: %#v", certs)
+	}
+}
+
+func TestInitCA_ReusePumaDevRSA(t *testing.T) {
+	// puma-dev writes an RSA CA as cert.pem + PKCS#1 key.pem
+	src := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Puma-dev CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = os.WriteFile(filepath.Join(src, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644)
+	_ = os.WriteFile(filepath.Join(src, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+
+	found, ok := FindPumaDevCA("", filepath.Join(src, "missing"), src)
+	if !ok || found != src {
+		t.Fatalf("expected to find CA in %s, got %q", src, found)
+	}
+	caDir := t.TempDir()
+	info, err := InitCA(caDir, found, false)
+	if err != nil {
+		t.Fatalf("init CA: %v", err)
+	}
+	if info.Source != src || info.Subject != "CN=Puma-dev CA" {
+		t.Fatalf("unexpected CA info: %#v", info)
+	}
+	ca, err := LoadCA(caDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ca.Issue("api", "test", t.TempDir(), time.Hour); err != nil {
+		t.Fatalf("issue with reused CA: %v", err)
+	}
+}
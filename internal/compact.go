@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"net"
+	"sort"
+	"strconv"
+)
+
+// PortMove relocates a domain's block to an aligned base during compaction.
+type PortMove struct {
+	Domain     string      `json:"domain"`
+	From       int         `json:"from"`
+	To         int         `json:"to"`
+	Mapping    string      `json:"mapping"`
+	NewMapping string      `json:"new_mapping"`
+	Project    string      `json:"project,omitempty"` // app whose config references the old ports
+	Env        []EnvChange `json:"env"`
+}
+
+// EnvChange is a port variable an app's config has to change after a move.
+type EnvChange struct {
+	Name string `json:"name"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// CompactPlan is the machine-readable result of ports compact.
+type CompactPlan struct {
+	Applied   bool       `json:"applied"`
+	Unchanged int        `json:"unchanged"` // aligned entries that keep their block
+	Moves     []PortMove `json:"moves"`
+}
+
+// PlanCompaction computes a block-aligned layout for the file entries inside [a.Min, a.Max] that moves as few
+// entries as possible: aligned blocks stay put and only misaligned ones move, each to the free aligned block
+// nearest its current port. Domains sharing a port move together. Entries outside the range are left alone;
+// their blocks, blocks leased to other domains and, with a.Prober, blocks where something else is listening
+// are never used as targets.
+func PlanCompaction(entries []Entry, a *Allocator) (CompactPlan, error) {
+	plan := CompactPlan{Moves: []PortMove{}}
+	if err := checkBlockParams(a.Min, a.Max, a.Block); err != nil {
+		return plan, err
+	}
+	// group domains by port: aliases of one app share a block
+	byPort := map[int][]Entry{}
+	var ports []int
+	var fixed []Reservation // blocks that are not moved but must not be moved into
+	for _, e := range entries {
+		if e.IsSymlink {
+			continue
+		}
+		m, err := ParseMapping(e.Mapping)
+		if err != nil {
+			continue
+		}
+		if m.Port < a.Min || m.Port+a.Block-1 > a.Max {
+			fixed = append(fixed, Reservation{Domain: e.Domain, Start: m.Port, End: m.Port + a.Block - 1, Source: "entry"})
+			continue
+		}
+		if _, ok := byPort[m.Port]; !ok {
+			ports = append(ports, m.Port)
+		}
+		byPort[m.Port] = append(byPort[m.Port], e)
+	}
+	sort.Ints(ports)
+
+	taken := map[int]bool{}
+	var misaligned []int
+	for _, p := range ports {
+		if (p-a.Min)%a.Block == 0 {
+			taken[p] = true
+			plan.Unchanged += len(byPort[p])
+			continue
+		}
+		misaligned = append(misaligned, p)
+	}
+	fixed = append(fixed, a.foreignLeases(entries)...)
+	// Ports inside current entry blocks belong to apps being moved, not to foreign processes, so they are not probed.
+	known := EntryReservations(entries, a.Block)
+	free := func(base int) bool {
+		end := base + a.Block - 1
+		if taken[base] || overlapping(fixed, base, end) != nil {
+			return false
+		}
+		for p := base; p <= end; p++ {
+			if overlapping(known, p, p) == nil && a.probe(p, p) != nil {
+				return false
+			}
+		}
+		return true
+	}
+	for _, p := range misaligned {
+		to, ok := nearestAligned(p, a, free)
+		if !ok {
+			return plan, Errorf(CodeNoFreePort, "no free aligned block for %s (port %d) in %d-%d", byPort[p][0].Domain, p, a.Min, a.Max)
+		}
+		taken[to] = true
+		for _, e := range byPort[p] {
+			plan.Moves = append(plan.Moves, newPortMove(e, p, to))
+		}
+	}
+	return plan, nil
+}
+
+// nearestAligned returns the aligned base closest to port (lower wins ties) accepted by free.
+func nearestAligned(port int, a *Allocator, free func(int) bool) (int, bool) {
+	var bases []int
+	for base := a.Min; base+a.Block-1 <= a.Max; base += a.Block {
+		bases = append(bases, base)
+	}
+	sort.SliceStable(bases, func(i, j int) bool { return absInt(bases[i]-port) < absInt(bases[j]-port) })
+	for _, b := range bases {
+		if free(b) {
+			return b, true
+		}
+	}
+	return 0, false
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func newPortMove(e Entry, from, to int) PortMove {
+	mv := PortMove{Domain: e.Domain, From: from, To: to, Mapping: e.Mapping, NewMapping: strconv.Itoa(to)}
+	if m, err := ParseMapping(e.Mapping); err == nil && m.Raw != strconv.Itoa(m.Port) {
+		mv.NewMapping = net.JoinHostPort(m.Host, strconv.Itoa(to))
+	}
+	roles := map[string]int{"web": 0}
+	if e.Meta != nil {
+		mv.Project = e.Meta.Project
+		if len(e.Meta.Roles) > 0 {
+			roles = e.Meta.Roles
+		}
+	}
+	for name, off := range roles {
+		mv.Env = append(mv.Env, EnvChange{Name: RoleEnvVar(name, off), From: from + off, To: to + off})
+	}
+	sort.Slice(mv.Env, func(i, j int) bool { return mv.Env[i].From < mv.Env[j].From })
+	return mv
+}
+
+// Changes describes the rewrites of plan as entry changes, for hooks.
+func (p CompactPlan) Changes() []EntryChange {
+	out := make([]EntryChange, len(p.Moves))
+	for i, mv := range p.Moves {
+		out[i] = EntryChange{Domain: mv.Domain, Status: "updated", Type: "file", Mapping: mv.NewMapping}
+	}
+	return out
+}
+
+// ApplyCompaction rewrites the entries of plan to their new mappings and marks it applied.
+func ApplyCompaction(dir string, plan *CompactPlan) error {
+	for _, mv := range plan.Moves {
+		if err := UpdateEntry(dir, mv.Domain, mv.NewMapping); err != nil {
+			return err
+		}
+	}
+	plan.Applied = true
+	return nil
+}
@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlanCompaction(t *testing.T) {
+	dir := t.TempDir()
+	entries := []Entry{
+		{Domain: "a.test", Mapping: "36005", Meta: &Meta{Project: "/src/a", Roles: map[string]int{"web": 0, "vite": 1}}},
+		{Domain: "b.test", Mapping: "36020"},
+		{Domain: "c.test", Mapping: "127.0.0.1:36033"},
+		{Domain: "c-alias.test", Mapping: "36033"},
+		{Domain: "docs.test", IsSymlink: true},
+	}
+	ledger := &Ledger{}
+	ledger.Reserve(dir, "gone.test", 36030, 10, time.Now())
+	a := &Allocator{Min: 36000, Max: 36099, Block: 10, Dir: dir, Ledger: ledger}
+
+	plan, err := PlanCompaction(entries, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Unchanged != 1 || len(plan.Moves) != 3 {
+		t.Fatalf("expected b to stay and 3 moves, got %#v", plan)
+	}
+	mv := plan.Moves[0]
+	if mv.Domain != "a.test" || mv.To != 36000 || mv.NewMapping != "36000" || mv.Project != "/src/a" {
+		t.Fatalf("unexpected move for a: %#v", mv)
+	}
+	if len(mv.Env) != 2 || mv.Env[1] != (EnvChange{Name: "VITE_PORT", From: 36006, To: 36001}) {
+		t.Fatalf("unexpected env changes: %#v", mv.Env)
+	}
+	// 36030 is leased to a deleted domain, so c and its alias move together to 36040.
+	for _, mv := range plan.Moves[1:] {
+		if mv.To != 36040 {
+			t.Fatalf("expected %s to move to 36040, got %#v", mv.Domain, mv)
+		}
+	}
+	if plan.Moves[1].NewMapping != "127.0.0.1:36040" || plan.Moves[2].NewMapping != "36040" {
+		t.Fatalf("mapping style not preserved: %#v", plan.Moves[1:])
+	}
+
+	a.Prober = busyPorts{36007: true}
+	plan, _ = PlanCompaction(entries[:1], a)
+	if plan.Moves[0].To != 36000 {
+		t.Fatalf("ports of the app being moved must not count as foreign, got %#v", plan.Moves[0])
+	}
+	a.Prober = busyPorts{36002: true}
+	plan, _ = PlanCompaction(entries[:1], a)
+	if plan.Moves[0].To != 36010 {
+		t.Fatalf("expected a busy block to be skipped, got %#v", plan.Moves[0])
+	}
+}
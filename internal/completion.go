@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CompletionShells lists the shells `completion install` supports.
+var CompletionShells = []string{"bash", "zsh", "fish"}
+
+// CompletionInstall is the result of installing a completion script.
+type CompletionInstall struct {
+	Shell string `json:"shell"`
+	Path  string `json:"path"`
+	Hint  string `json:"hint,omitempty"` // shell setup still needed for the script to load
+}
+
+// DetectShell returns the base name of $SHELL, e.g. zsh, or "" if it is unset.
+func DetectShell() string {
+	if sh := os.Getenv("SHELL"); sh != "" {
+		return filepath.Base(sh)
+	}
+	return ""
+}
+
+// CompletionPath returns where shell loads pumadevctl's completion script from without further setup
+// (except for zsh, see CompletionHint):
+//
+//	bash  $BASH_COMPLETION_USER_DIR/completions/pumadevctl (default ~/.local/share/bash-completion)
+//	zsh   $XDG_DATA_HOME/zsh/site-functions/_pumadevctl (default ~/.local/share)
+//	fish  $XDG_CONFIG_HOME/fish/completions/pumadevctl.fish (default ~/.config)
+func CompletionPath(shell string) (string, error) {
+	home, _ := os.UserHomeDir()
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	switch shell {
+	case "bash":
+		dir := os.Getenv("BASH_COMPLETION_USER_DIR")
+		if dir == "" {
+			dir = filepath.Join(dataHome, "bash-completion")
+		}
+		return filepath.Join(dir, "completions", "pumadevctl"), nil
+	case "zsh":
+		return filepath.Join(dataHome, "zsh", "site-functions", "_pumadevctl"), nil
+	case "fish":
+		configHome := os.Getenv("XDG_CONFIG_HOME")
+		if configHome == "" {
+			configHome = filepath.Join(home, ".config")
+		}
+		return filepath.Join(configHome, "fish", "completions", "pumadevctl.fish"), nil
+	}
+	return "", Errorf(CodeUsage, "unsupported shell %q (supported: %s)", shell, strings.Join(CompletionShells, ", "))
+}
+
+// CompletionHint returns the setup shell still needs to load the script at path, or "" if it loads it
+// already. zsh only searches $fpath, which has no per-user directory by default.
+func CompletionHint(shell, path string) string {
+	if shell != "zsh" {
+		return ""
+	}
+	dir := filepath.Dir(path)
+	for _, d := range filepath.SplitList(os.Getenv("FPATH")) {
+		if filepath.Clean(d) == dir {
+			return ""
+		}
+	}
+	return "add `fpath=(" + dir + " $fpath)` before `compinit` in ~/.zshrc"
+}
+
+// InstallCompletion writes script to path, creating its directory and replacing a previous version.
+func InstallCompletion(path string, script []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, script, 0o644)
+}
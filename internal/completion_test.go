@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompletionPath(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "config"))
+	t.Setenv("BASH_COMPLETION_USER_DIR", "")
+	t.Setenv("FPATH", "")
+	for shell, want := range map[string]string{
+		"bash": filepath.Join(root, "data", "bash-completion", "completions", "pumadevctl"),
+		"zsh":  filepath.Join(root, "data", "zsh", "site-functions", "_pumadevctl"),
+		"fish": filepath.Join(root, "config", "fish", "completions", "pumadevctl.fish"),
+	} {
+		got, err := CompletionPath(shell)
+		if err != nil || got != want {
+			t.Errorf("CompletionPath(%s) = %q, %v; want %q", shell, got, err, want)
+		}
+	}
+	if _, err := CompletionPath("tcsh"); CodeOf(err) != CodeUsage {
+		t.Errorf("expected a usage error for an unsupported shell, got %v", err)
+	}
+
+	zsh, _ := CompletionPath("zsh")
+	if CompletionHint("zsh", zsh) == "" || CompletionHint("fish", zsh) != "" {
+		t.Errorf("expected a hint for zsh only")
+	}
+	t.Setenv("FPATH", "/usr/share/zsh/functions"+string(os.PathListSeparator)+filepath.Dir(zsh))
+	if hint := CompletionHint("zsh", zsh); hint != "" {
+		t.Errorf("no hint expected once the dir is in $FPATH, got %q", hint)
+	}
+
+	if err := InstallCompletion(zsh, []byte("#compdef pumadevctl\n")); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(zsh); err != nil || string(b) != "#compdef pumadevctl\n" {
+		t.Errorf("script not written: %q, %v", b, err)
+	}
+}
+
+func TestProfileNames(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "user"))
+	prev := systemConfigDir
+	systemConfigDir = func() string { return filepath.Join(root, "system") }
+	t.Cleanup(func() { systemConfigDir = prev })
+
+	for dir, body := range map[string]string{
+		filepath.Join(root, "system"):             `{"profiles":{"work":{"port_min":40000},"ci":{}}}`,
+		filepath.Join(root, "user", "pumadevctl"): `{"profiles":{"work":{"dir":"~/work"},"home":{}}}`,
+	} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := ProfileNames(), []string{"ci", "home", "work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ProfileNames() = %v, want %v", got, want)
+	}
+}
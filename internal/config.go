@@ -1,33 +1,58 @@
 package internal
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
 )
 
 // AppConfig holds user-configurable settings loaded from XDG config.
 // Currently only the mappings directory and port allocation defaults are supported.
-// JSON example (~/.config/pumadevctl/config.json):
+// JSON example (~/.config/pumadevctl/config.json, same format as /etc/pumadevctl/config.json):
 // {
 //   "dir": "/Users/alice/.puma-dev",
 //   "port_min": 36000,
 //   "port_max": 37000,
-//   "port_block_size": 10
+//   "port_block_size": 10,
+//   "port_roles": "web:+0,vite:+1,sidekiq-web:+2,cable:+3",
+//   "tld": "test",
+//   "puma_dev_ca_dir": "/Users/alice/Library/Application Support/io.puma.dev",
+//   "profiles": {"work": {"dir": "/Users/alice/work/.puma-dev", "port_min": 40000, "port_max": 41000}},
+//   "contexts": {"ruby2": {"dir": "~/.puma-dev-ruby2"}, "ruby3": {"dir": "~/.puma-dev"}},
+//   "context": "ruby3",
+//   "hooks": {"post_create": ["~/bin/regen-nginx"], "pre_delete": ["~/bin/check-unused --strict"]},
+//   "hook_timeout": 10
 // }
+// The same keys can be written as config.yml/config.yaml or config.toml instead; only one config file may
+// exist per directory.
 // All fields are optional; sensible defaults are applied.
 // If XDG variable is not set, falls back to ~/.config.
 // The config file itself is optional.
 //
-// This package avoids introducing extra dependencies by using JSON.
+// JSON is the default format; config convert migrates between formats.
 
 type AppConfig struct {
-	Dir           string `json:"dir"`
-	PortMin       int    `json:"port_min"`
-	PortMax       int    `json:"port_max"`
-	PortBlockSize int    `json:"port_block_size"`
+	Dir           string `json:"dir" yaml:"dir" toml:"dir"`
+	PortMin       int    `json:"port_min" yaml:"port_min" toml:"port_min"`
+	PortMax       int    `json:"port_max" yaml:"port_max" toml:"port_max"`
+	PortBlockSize int    `json:"port_block_size" yaml:"port_block_size" toml:"port_block_size"`
+	PortRoles     string `json:"port_roles,omitempty" yaml:"port_roles,omitempty" toml:"port_roles,omitempty"`
+	TLD           string `json:"tld,omitempty" yaml:"tld,omitempty" toml:"tld,omitempty"`
+	PumaDevCADir  string `json:"puma_dev_ca_dir,omitempty" yaml:"puma_dev_ca_dir,omitempty" toml:"puma_dev_ca_dir,omitempty"`
+	Context       string `json:"context,omitempty" yaml:"context,omitempty" toml:"context,omitempty"`
+	HookTimeout   int    `json:"hook_timeout,omitempty" yaml:"hook_timeout,omitempty" toml:"hook_timeout,omitempty"`
 }
 
 // DefaultAppConfig returns built-in defaults matching previous behavior.
@@ -38,6 +63,9 @@ func DefaultAppConfig() AppConfig {
 		PortMin:       36000,
 		PortMax:       37000,
 		PortBlockSize: 10,
+		PortRoles:     DefaultPortRoles,
+		TLD:           DefaultTLD,
+		HookTimeout:   DefaultHookTimeout,
 	}
 }
 
@@ -50,38 +78,339 @@ func XDGConfigDir() string {
 	return filepath.Join(home, ".config", "pumadevctl")
 }
 
-// ConfigPath returns the path to pumadevctl's JSON config file.
-func ConfigPath() string { return filepath.Join(XDGConfigDir(), "config.json") }
+// XDGDataDir returns the directory for pumadevctl's persistent data (e.g. the local CA), respecting XDG.
+func XDGDataDir() string {
+	if xdg := os.Getenv("XDG_DATA_HOME"); xdg != "" {
+		return filepath.Join(xdg, "pumadevctl")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "share", "pumadevctl")
+}
 
-// LoadAppConfig reads the JSON config file if present and merges it with defaults.
-func LoadAppConfig() (AppConfig, error) {
-	cfg := DefaultAppConfig()
-	path := ConfigPath()
+// XDGStateDir returns the directory for pumadevctl's state (e.g. the port ledger), respecting XDG.
+func XDGStateDir() string {
+	if xdg := os.Getenv("XDG_STATE_HOME"); xdg != "" {
+		return filepath.Join(xdg, "pumadevctl")
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".local", "state", "pumadevctl")
+}
+
+// ConfigFileNames are the config file names looked for in a config directory. At most one may exist.
+var ConfigFileNames = []string{"config.json", "config.yml", "config.yaml", "config.toml"}
+
+// Config file formats, chosen by extension.
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+	FormatTOML = "toml"
+)
+
+// FindConfigFile returns the config file in dir, or config.json when there is none. Several config files
+// in one directory are a config error rather than being merged or picked silently.
+func FindConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range ConfigFileNames {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return filepath.Join(dir, ConfigFileNames[0]), nil
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	}
+	return filepath.Join(dir, found[0]), Errorf(CodeConfig, "several config files in %s (%s): keep one", dir, strings.Join(found, ", "))
+}
+
+// ConfigPath returns the path to pumadevctl's user config file (see FindConfigFile).
+func ConfigPath() string {
+	path, _ := FindConfigFile(XDGConfigDir())
+	return path
+}
+
+// systemConfigDir holds the machine-wide config file, read before the user's.
+var systemConfigDir = func() string {
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("ProgramData"), "pumadevctl")
+	}
+	return "/etc/pumadevctl"
+}
+
+// SystemConfigDir returns the directory of the machine-wide config file.
+func SystemConfigDir() string { return systemConfigDir() }
+
+// SystemConfigPath returns the path to the machine-wide config file.
+func SystemConfigPath() string {
+	path, _ := FindConfigFile(SystemConfigDir())
+	return path
+}
+
+// ConfigFileName returns the config file name for a format: config.json, config.yaml or config.toml.
+func ConfigFileName(format string) (string, error) {
+	switch format {
+	case FormatJSON, FormatYAML, FormatTOML:
+		return "config." + format, nil
+	case "yml":
+		return "config.yml", nil
+	}
+	return "", Errorf(CodeUsage, "unknown config format %q (want %s, %s or %s)", format, FormatJSON, FormatYAML, FormatTOML)
+}
+
+// configFormat returns the format of a config file from its extension.
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return FormatYAML
+	case ".toml":
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// Config layers, lowest precedence first.
+const (
+	OriginDefault = "default"
+	OriginSystem  = "system"
+	OriginUser    = "user"
+	OriginProject = "project"
+	OriginEnv     = "env"
+	OriginFlag    = "flag"
+)
+
+// EnvPrefix prefixes the environment variables overriding config keys (PUMADEVCTL_DIR, PUMADEVCTL_PORT_MIN, ...).
+// PUMADEVCTL_PROFILE selects a profile when --profile is not given.
+const EnvPrefix = "PUMADEVCTL_"
+
+// configFile is a config file: the AppConfig keys plus named profiles overriding them, named contexts and
+// hooks.
+type configFile struct {
+	AppConfig `yaml:",inline"`
+	Profiles  map[string]AppConfig `json:"profiles,omitempty" yaml:"profiles,omitempty" toml:"profiles,omitempty"`
+	Contexts  map[string]Context   `json:"contexts,omitempty" yaml:"contexts,omitempty" toml:"contexts,omitempty"`
+	Hooks     map[string][]string  `json:"hooks,omitempty" yaml:"hooks,omitempty" toml:"hooks,omitempty"`
+}
+
+// ConfigValue is one effective config key and where its value came from.
+type ConfigValue struct {
+	Key     string `json:"key"`
+	Value   string `json:"value"`
+	Origin  string `json:"origin"`            // default, system, user, project, env, flag or context
+	Source  string `json:"source,omitempty"`  // file, variable or flag that set it
+	Profile string `json:"profile,omitempty"` // profile section the value came from
+}
+
+// ResolvedConfig is the effective config together with the origin of every key.
+type ResolvedConfig struct {
+	AppConfig
+	Profile  string
+	Values   []ConfigValue       // one per key, in ConfigKeys order
+	Contexts map[string]Context  // declared contexts, the user config's replacing the system config's by name
+	Hooks    map[string][]string // hook commands by key (pre_create, ...), the user config's replacing the system config's
+}
+
+// ConfigKeys returns the config keys (the JSON names of AppConfig's fields) in field order.
+func ConfigKeys() []string {
+	t := reflect.TypeOf(AppConfig{})
+	keys := make([]string, t.NumField())
+	for i := range keys {
+		keys[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return keys
+}
+
+// ConfigEnvVar returns the environment variable overriding key, e.g. PUMADEVCTL_PORT_MIN for port_min.
+func ConfigEnvVar(key string) string { return EnvPrefix + strings.ToUpper(key) }
+
+// ResolveConfig layers defaults, the system config, the user config, the project file (.pumadev.yml) of the
+// working directory and PUMADEVCTL_* variables, later layers winning. A profile ("" means $PUMADEVCTL_PROFILE)
+// is applied on top of each config file defining it and must be defined by at least one. Flags are applied
+// by the caller with Set, followed by ApplyContext.
+func ResolveConfig(profile string) (*ResolvedConfig, error) {
+	if profile == "" {
+		profile = os.Getenv(EnvPrefix + "PROFILE")
+	}
+	rc := &ResolvedConfig{Profile: profile, Contexts: map[string]Context{}, Hooks: map[string][]string{}}
+	for _, key := range ConfigKeys() {
+		rc.Values = append(rc.Values, ConfigValue{Key: key, Origin: OriginDefault})
+	}
+	rc.merge(DefaultAppConfig(), ConfigValue{Origin: OriginDefault})
+	found := profile == ""
+	for _, layer := range []struct{ origin, dir string }{{OriginSystem, SystemConfigDir()}, {OriginUser, XDGConfigDir()}} {
+		path, err := FindConfigFile(layer.dir)
+		if err != nil {
+			return nil, err
+		}
+		f, err := loadConfigFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			continue
+		}
+		rc.merge(f.AppConfig, ConfigValue{Origin: layer.origin, Source: path})
+		for name, c := range f.Contexts {
+			rc.Contexts[name] = c
+		}
+		for key, commands := range f.Hooks {
+			rc.Hooks[key] = commands
+		}
+		if p, ok := f.Profiles[profile]; ok && profile != "" {
+			rc.merge(p, ConfigValue{Origin: layer.origin, Source: path, Profile: profile})
+			found = true
+		}
+	}
+	if !found {
+		return nil, Errorf(CodeConfig, "unknown profile %q: define it under \"profiles\" in %s", profile, ConfigPath())
+	}
+	pc, err := loadProjectLayer()
+	if err != nil {
+		return nil, err
+	}
+	if pc != nil {
+		rc.merge(pc.layer(), ConfigValue{Origin: OriginProject, Source: pc.Path})
+	}
+	for _, key := range ConfigKeys() {
+		if v := os.Getenv(ConfigEnvVar(key)); v != "" {
+			if err := rc.Set(key, v, OriginEnv, ConfigEnvVar(key)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rc, nil
+}
+
+// ProfileNames returns the profiles defined in the system and user config files, sorted. Unreadable files
+// are skipped.
+func ProfileNames() []string {
+	seen := map[string]bool{}
+	var names []string
+	for _, dir := range []string{SystemConfigDir(), XDGConfigDir()} {
+		path, err := FindConfigFile(dir)
+		if err != nil {
+			continue
+		}
+		f, err := loadConfigFile(path)
+		if err != nil || f == nil {
+			continue
+		}
+		for name := range f.Profiles {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// merge copies layer's non-zero fields into rc, recording origin for each.
+func (rc *ResolvedConfig) merge(layer AppConfig, origin ConfigValue) {
+	src := reflect.ValueOf(layer)
+	for i := 0; i < src.NumField(); i++ {
+		if !src.Field(i).IsZero() {
+			rc.setField(i, src.Field(i), origin)
+		}
+	}
+}
+
+// Set overrides key with value, parsed as the key's type, recording origin and source for it.
+func (rc *ResolvedConfig) Set(key, value, origin, source string) error {
+	for i, k := range ConfigKeys() {
+		if k != key {
+			continue
+		}
+		v, err := parseConfigValue(reflect.TypeOf(rc.AppConfig).Field(i).Type, key, value)
+		if err != nil {
+			return Errorf(CodeConfig, "%s: %v", source, err)
+		}
+		rc.setField(i, v, ConfigValue{Origin: origin, Source: source})
+		return nil
+	}
+	return Errorf(CodeConfig, "unknown config key %q (known: %s)", key, strings.Join(ConfigKeys(), ", "))
+}
+
+func (rc *ResolvedConfig) setField(i int, v reflect.Value, origin ConfigValue) {
+	field := reflect.ValueOf(&rc.AppConfig).Elem().Field(i)
+	field.Set(v)
+	origin.Key = ConfigKeys()[i]
+	if origin.Key == "tld" {
+		rc.TLD = strings.TrimPrefix(rc.TLD, ".")
+	}
+	origin.Value = fmt.Sprint(field.Interface())
+	rc.Values[i] = origin
+}
+
+// parseConfigValue converts a string (from the environment, a flag or `config set`) to a key's type.
+func parseConfigValue(t reflect.Type, key, s string) (reflect.Value, error) {
+	switch t.Kind() {
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s must be an integer, got %q", key, s)
+		}
+		return reflect.ValueOf(n), nil
+	default:
+		return reflect.ValueOf(s), nil
+	}
+}
+
+// loadConfigFile parses a config file; it returns nil, nil when the file does not exist.
+func loadConfigFile(path string) (*configFile, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return cfg, nil
+			return nil, nil
 		}
-		return cfg, err
-	}
-	var fileCfg AppConfig
-	if err := json.Unmarshal(b, &fileCfg); err != nil {
-		return cfg, fmt.Errorf("parse %s: %w", path, err)
+		return nil, WithCode(CodeConfig, err)
 	}
-	// Merge: only override when non-zero values are provided.
-	if fileCfg.Dir != "" {
-		cfg.Dir = fileCfg.Dir
+	return decodeConfigFile(path, b)
+}
+
+// decodeConfigFile parses b in path's format. YAML and TOML files reject unknown keys; JSON files ignore them
+// (config validate reports them) as they always have, so existing files keep loading.
+func decodeConfigFile(path string, b []byte) (*configFile, error) {
+	var f configFile
+	var err error
+	switch configFormat(path) {
+	case FormatYAML:
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if err = dec.Decode(&f); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case FormatTOML:
+		var md toml.MetaData
+		if md, err = toml.Decode(string(b), &f); err == nil && len(md.Undecoded()) > 0 {
+			var keys []string
+			for _, k := range md.Undecoded() {
+				keys = append(keys, k.String())
+			}
+			err = fmt.Errorf("unknown keys: %s", strings.Join(keys, ", "))
+		}
+	default:
+		if len(bytes.TrimSpace(b)) > 0 {
+			err = json.Unmarshal(b, &f)
+		}
 	}
-	if fileCfg.PortMin != 0 {
-		cfg.PortMin = fileCfg.PortMin
+	if err != nil {
+		return nil, Errorf(CodeConfig, "parse %s: %w", path, err)
 	}
-	if fileCfg.PortMax != 0 {
-		cfg.PortMax = fileCfg.PortMax
+	return &f, nil
+}
+
+// LoadAppConfig returns the effective config without flags: see ResolveConfig.
+func LoadAppConfig() (AppConfig, error) {
+	rc, err := ResolveConfig("")
+	if err != nil {
+		return DefaultAppConfig(), err
 	}
-	if fileCfg.PortBlockSize != 0 {
-		cfg.PortBlockSize = fileCfg.PortBlockSize
+	if err := rc.ApplyContext(); err != nil {
+		return DefaultAppConfig(), err
 	}
-	return cfg, nil
+	return rc.AppConfig, nil
 }
 
 // ResolveDir validates the directory coming from flag or config.
@@ -98,18 +427,18 @@ func ResolveDir(dirFlag string) (string, error) {
 		dir = cfg.Dir
 	}
 	if dir == "" {
-		return "", fmt.Errorf("no directory specified")
+		return "", Errorf(CodeConfig, "no directory specified")
 	}
 	abs, err := filepath.Abs(dir)
 	if err != nil {
-		return "", err
+		return "", WithCode(CodeConfig, err)
 	}
 	fi, err := os.Stat(abs)
 	if err != nil {
-		return "", fmt.Errorf("directory %s does not exist", abs)
+		return "", Errorf(CodeConfig, "directory %s does not exist", abs)
 	}
 	if !fi.IsDir() {
-		return "", fmt.Errorf("path %s is not a directory", abs)
+		return "", Errorf(CodeConfig, "path %s is not a directory", abs)
 	}
 	return abs, nil
 }
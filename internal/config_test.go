@@ -0,0 +1,257 @@
+package internal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveConfig_Layers(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "user"))
+	t.Setenv("PUMADEVCTL_PROFILE", "")
+	for _, k := range ConfigKeys() {
+		t.Setenv(ConfigEnvVar(k), "")
+	}
+	system := filepath.Join(root, "system", "config.json")
+	prev := systemConfigDir
+	systemConfigDir = func() string { return filepath.Dir(system) }
+	t.Cleanup(func() { systemConfigDir = prev })
+
+	write := func(path, body string) {
+		t.Helper()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(system, `{"port_min": 30000, "port_max": 31000, "tld": "localhost"}`)
+	write(ConfigPath(), `{"port_min": 36100, "profiles": {"work": {"port_max": 41000, "port_block_size": 20}}}`)
+	t.Setenv("PUMADEVCTL_PORT_BLOCK_SIZE", "5")
+
+	rc, err := ResolveConfig("work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origins := map[string]string{}
+	for _, v := range rc.Values {
+		origins[v.Key] = v.Origin + "/" + v.Profile
+	}
+	if rc.PortMin != 36100 || origins["port_min"] != "user/" {
+		t.Errorf("port_min = %d from %s, want the user file's", rc.PortMin, origins["port_min"])
+	}
+	if rc.PortMax != 41000 || origins["port_max"] != "user/work" {
+		t.Errorf("port_max = %d from %s, want the work profile's", rc.PortMax, origins["port_max"])
+	}
+	if rc.PortBlockSize != 5 || origins["port_block_size"] != "env/" {
+		t.Errorf("port_block_size = %d from %s, want the environment's", rc.PortBlockSize, origins["port_block_size"])
+	}
+	if rc.TLD != "localhost" || origins["tld"] != "system/" || origins["puma_dev_ca_dir"] != "default/" {
+		t.Errorf("unexpected origins %v", origins)
+	}
+
+	if err := rc.Set("port_min", "36500", OriginFlag, "--port-min"); err != nil || rc.PortMin != 36500 {
+		t.Fatalf("Set: %v, port_min %d", err, rc.PortMin)
+	}
+	if err := rc.Set("port_min", "lots", OriginFlag, "--port-min"); CodeOf(err) != CodeConfig {
+		t.Fatalf("expected a config error for a non-integer, got %v", err)
+	}
+	if _, err := ResolveConfig("home"); CodeOf(err) != CodeConfig {
+		t.Fatalf("expected a config error for an unknown profile, got %v", err)
+	}
+}
+
+func TestAppConfigValidate(t *testing.T) {
+	base := DefaultAppConfig()
+	base.Dir = t.TempDir()
+	if issues := base.Validate(); len(issues) != 0 {
+		t.Fatalf("defaults should be valid, got %v", issues)
+	}
+	tests := []struct {
+		name string
+		edit func(*AppConfig)
+		key  string
+	}{
+		{"inverted range", func(c *AppConfig) { c.PortMin, c.PortMax = 37000, 36000 }, "port_max"},
+		{"port out of range", func(c *AppConfig) { c.PortMax = 70000 }, "port_max"},
+		{"negative block", func(c *AppConfig) { c.PortBlockSize = -1 }, "port_block_size"},
+		{"block wider than range", func(c *AppConfig) { c.PortMin, c.PortMax = 36000, 36004 }, "port_block_size"},
+		{"role outside block", func(c *AppConfig) { c.PortRoles = "web:+0,vite:+10" }, "port_roles"},
+		{"dotted tld", func(c *AppConfig) { c.TLD = "dev.test" }, "tld"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base
+			tt.edit(&c)
+			issues := c.Validate()
+			if len(issues) != 1 || issues[0].Key != tt.key || issues[0].Severity != SeverityError {
+				t.Fatalf("expected one error on %s, got %v", tt.key, issues)
+			}
+		})
+	}
+}
+
+func TestConfigFile_PreservesUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"port_min": 36000, "x_custom": {"a": [1, 2]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := OpenConfigFile(path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("port_max", "38000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("port_max", "many"); CodeOf(err) != CodeUsage {
+		t.Fatalf("expected a usage error for a non-integer, got %v", err)
+	}
+	if err := f.Set("prot_max", "1"); CodeOf(err) != CodeUsage {
+		t.Fatalf("expected a usage error for an unknown key, got %v", err)
+	}
+	if removed, err := f.Unset("port_min"); err != nil || !removed {
+		t.Fatalf("Unset: %v, %v", removed, err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = OpenConfigFile(path, "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("tld", ".localhost"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	b, _ := os.ReadFile(path)
+	want := `{
+  "port_max": 38000,
+  "profiles": {
+    "work": {
+      "tld": "localhost"
+    }
+  },
+  "x_custom": {
+    "a": [
+      1,
+      2
+    ]
+  }
+}
+`
+	if string(b) != want {
+		t.Fatalf("got\n%s\nwant\n%s", b, want)
+	}
+	if got := f.UnknownKeys(); len(got) != 1 || got[0] != "x_custom" {
+		t.Fatalf("unknown keys = %v", got)
+	}
+}
+
+func TestConfigFormats(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code ErrorCode
+	}{
+		{"config.yml", "port_min: 40000\nprofiles:\n  work:\n    tld: localhost\n", ""},
+		{"config.toml", "port_min = 40000\n[profiles.work]\ntld = \"localhost\"\n", ""},
+		{"config.json", `{"port_min": 40000, "x_extra": true, "profiles": {"work": {"tld": "localhost"}}}`, ""},
+		{"config.yaml", "port_min: 40000\nprot_max: 1\n", CodeConfig},
+		{"config.toml", "port_min = 40000\n[profiles.work]\ntdl = \"localhost\"\n", CodeConfig},
+		{"config.yml", "port_min: many\n", CodeConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := decodeConfigFile(tt.name, []byte(tt.body))
+			if tt.code != "" {
+				if CodeOf(err) != tt.code {
+					t.Fatalf("expected %s, got %v", tt.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if f.PortMin != 40000 || f.Profiles["work"].TLD != "localhost" {
+				t.Fatalf("decoded %+v", f)
+			}
+		})
+	}
+
+	dir := t.TempDir()
+	if path, err := FindConfigFile(dir); err != nil || filepath.Base(path) != "config.json" {
+		t.Fatalf("empty dir: %s, %v", path, err)
+	}
+	for _, name := range []string{"config.toml", "config.yml"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := FindConfigFile(dir); CodeOf(err) != CodeConfig {
+		t.Fatalf("expected a config error for two config files, got %v", err)
+	}
+}
+
+func TestConfigFile_As(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenConfigFile(filepath.Join(dir, "config.json"), "work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Replace([]byte(`{"port_min": 36000, "dir": "/srv/puma"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("port_max", "41000"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"config.yaml", "config.toml"} {
+		out := f.As(filepath.Join(dir, name))
+		cfg, err := out.Candidate()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if cfg.PortMin != 36000 || cfg.PortMax != 41000 || cfg.Dir != "/srv/puma" {
+			t.Fatalf("%s: got %+v", name, cfg)
+		}
+	}
+}
+
+func TestConfigFile_Convert(t *testing.T) {
+	dir := t.TempDir()
+	f, err := OpenConfigFile(filepath.Join(dir, "config.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("port_min", "40000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the old file cannot be removed: the new one goes again, leaving a single config file
+	prev := removeFile
+	removeFile = func(string) error { return os.ErrPermission }
+	t.Cleanup(func() { removeFile = prev })
+	out := f.As(filepath.Join(dir, "config.yaml"))
+	if err := f.Convert(out); !errors.Is(err, os.ErrPermission) {
+		t.Fatalf("expected the removal error, got %v", err)
+	}
+	if path, err := FindConfigFile(dir); err != nil || path != f.Path {
+		t.Fatalf("expected only %s to be left, got %q (%v)", f.Path, path, err)
+	}
+
+	removeFile = prev
+	if err := f.Convert(out); err != nil {
+		t.Fatal(err)
+	}
+	if path, err := FindConfigFile(dir); err != nil || path != out.Path {
+		t.Fatalf("expected only %s to be left, got %q (%v)", out.Path, path, err)
+	}
+}
@@ -0,0 +1,483 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigIssue is a problem with a config value.
+type ConfigIssue struct {
+	Key      string `json:"key"`
+	Severity string `json:"severity"` // error or warning
+	Message  string `json:"message"`
+	Origin   string `json:"origin,omitempty"` // layer the value came from
+	Source   string `json:"source,omitempty"`
+}
+
+// ConfigReport is the machine-readable result of config validate.
+type ConfigReport struct {
+	Profile  string        `json:"profile,omitempty"`
+	Errors   int           `json:"errors"`
+	Warnings int           `json:"warnings"`
+	Issues   []ConfigIssue `json:"issues"`
+}
+
+// NewConfigReport counts the issues by severity.
+func NewConfigReport(profile string, issues []ConfigIssue) ConfigReport {
+	rep := ConfigReport{Profile: profile, Issues: issues}
+	if rep.Issues == nil {
+		rep.Issues = []ConfigIssue{}
+	}
+	for _, i := range rep.Issues {
+		if i.Severity == SeverityError {
+			rep.Errors++
+		} else {
+			rep.Warnings++
+		}
+	}
+	return rep
+}
+
+// Value returns key's value as a string, "" when it is unset (zero) or unknown.
+func (c AppConfig) Value(key string) string {
+	for i, k := range ConfigKeys() {
+		if f := reflect.ValueOf(c).Field(i); k == key && !f.IsZero() {
+			return fmt.Sprint(f.Interface())
+		}
+	}
+	return ""
+}
+
+// Validate checks every field semantically: port ranges, block size against the range, port roles against
+// the block, the TLD as a DNS label and the directories.
+func (c AppConfig) Validate() []ConfigIssue {
+	var issues []ConfigIssue
+	add := func(key, severity, format string, a ...any) {
+		issues = append(issues, ConfigIssue{Key: key, Severity: severity, Message: fmt.Sprintf(format, a...)})
+	}
+	if c.Dir == "" {
+		add("dir", SeverityError, "must not be empty")
+	} else if fi, err := os.Stat(c.Dir); err != nil {
+		add("dir", SeverityWarning, "%s does not exist", c.Dir)
+	} else if !fi.IsDir() {
+		add("dir", SeverityError, "%s is not a directory", c.Dir)
+	}
+	portsOK := true
+	for _, p := range []struct {
+		key  string
+		port int
+	}{{"port_min", c.PortMin}, {"port_max", c.PortMax}} {
+		switch {
+		case p.port < 1 || p.port > 65535:
+			add(p.key, SeverityError, "%d is not a port (1-65535)", p.port)
+			portsOK = false
+		case p.port < 1024:
+			add(p.key, SeverityWarning, "%d is a privileged port; apps need root to bind it", p.port)
+		}
+	}
+	if portsOK && c.PortMin > c.PortMax {
+		add("port_max", SeverityError, "%d is below port_min %d", c.PortMax, c.PortMin)
+		portsOK = false
+	}
+	blockOK := c.PortBlockSize >= 1
+	if !blockOK {
+		add("port_block_size", SeverityError, "must be at least 1, got %d", c.PortBlockSize)
+	} else if portsOK && c.PortMax-c.PortMin+1 < c.PortBlockSize {
+		add("port_block_size", SeverityError, "%d does not fit in port range %d-%d", c.PortBlockSize, c.PortMin, c.PortMax)
+	}
+	if c.PortRoles != "" && blockOK {
+		if _, err := ParsePortRoles(c.PortRoles, c.PortBlockSize); err != nil {
+			add("port_roles", SeverityError, "%v", err)
+		}
+	}
+	if c.TLD == "" {
+		add("tld", SeverityError, "must not be empty")
+	} else if err := ValidateDomain(c.TLD); err != nil || strings.Contains(c.TLD, ".") {
+		add("tld", SeverityError, "%q is not a single DNS label", c.TLD)
+	}
+	if c.HookTimeout < 1 {
+		add("hook_timeout", SeverityError, "must be at least 1 second, got %d", c.HookTimeout)
+	}
+	if c.PumaDevCADir != "" {
+		if fi, err := os.Stat(c.PumaDevCADir); err != nil || !fi.IsDir() {
+			add("puma_dev_ca_dir", SeverityWarning, "%s is not a directory", c.PumaDevCADir)
+		}
+	}
+	return issues
+}
+
+// Validate checks the effective config and annotates each issue with the layer that set the key.
+func (rc *ResolvedConfig) Validate() []ConfigIssue {
+	issues := append(rc.AppConfig.Validate(), validateHooks(rc.Hooks)...)
+	if _, ok := rc.Contexts[rc.Context]; rc.Context != "" && !ok {
+		issues = append(issues, ConfigIssue{Key: "context", Severity: SeverityError,
+			Message: fmt.Sprintf("%q is not declared under \"contexts\"; add it with `pumadevctl context add`", rc.Context)})
+	}
+	for i := range issues {
+		for _, v := range rc.Values {
+			if v.Key == issues[i].Key {
+				issues[i].Origin, issues[i].Source = v.Origin, v.Source
+			}
+		}
+	}
+	return issues
+}
+
+// ConfigFile is a config file edited in place. Keys pumadevctl does not know are kept as they are, though
+// comments and key order are not. With a Profile, Get, Set and Unset work on that entry of "profiles".
+type ConfigFile struct {
+	Path    string
+	Profile string
+	Exists  bool
+
+	raw map[string]any
+}
+
+// OpenConfigFile reads path for editing; a missing file opens empty.
+func OpenConfigFile(path, profile string) (*ConfigFile, error) {
+	f := &ConfigFile{Path: path, Profile: profile, raw: map[string]any{}}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, WithCode(CodeConfig, err)
+	}
+	f.Exists = true
+	if f.raw, err = decodeConfigMap(path, b); err != nil {
+		return nil, Errorf(CodeConfig, "parse %s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Format is the file's format (json, yaml or toml), from its extension.
+func (f *ConfigFile) Format() string { return configFormat(f.Path) }
+
+// section returns the object Get, Set and Unset work on. create adds a missing profile.
+func (f *ConfigFile) section(create bool) (map[string]any, error) {
+	if f.Profile == "" {
+		return f.raw, nil
+	}
+	parent, key := f.raw, "profiles"
+	for _, name := range []string{"profiles", f.Profile} {
+		s, ok := parent[name].(map[string]any)
+		if !ok {
+			if _, exists := parent[name]; exists {
+				return nil, Errorf(CodeConfig, "%s: %s is not an object", f.Path, key)
+			}
+			s = map[string]any{}
+			if create {
+				parent[name] = s
+			}
+		}
+		parent, key = s, key+"."+f.Profile
+	}
+	return parent, nil
+}
+
+// Get returns the value of key as written in the file.
+func (f *ConfigFile) Get(key string) (string, bool, error) {
+	s, err := f.section(false)
+	if err != nil {
+		return "", false, err
+	}
+	v, ok := s[key]
+	if !ok {
+		return "", false, nil
+	}
+	return fmt.Sprint(v), true, nil
+}
+
+// Set writes key, converting value to the key's type. Unknown keys are usage errors.
+func (f *ConfigFile) Set(key, value string) error {
+	t, err := configKeyType(key)
+	if err != nil {
+		return err
+	}
+	v, err := parseConfigValue(t, key, value)
+	if err != nil {
+		return WithCode(CodeUsage, err)
+	}
+	if key == "tld" {
+		v = reflect.ValueOf(strings.TrimPrefix(v.String(), "."))
+	}
+	s, err := f.section(true)
+	if err != nil {
+		return err
+	}
+	s[key] = v.Interface()
+	return nil
+}
+
+// Unset removes key and reports whether it was set.
+func (f *ConfigFile) Unset(key string) (bool, error) {
+	if _, err := configKeyType(key); err != nil {
+		return false, err
+	}
+	s, err := f.section(false)
+	if err != nil {
+		return false, err
+	}
+	if _, ok := s[key]; !ok {
+		return false, nil
+	}
+	delete(s, key)
+	return true, nil
+}
+
+// SetContext declares (or redeclares) the context name with its directory and returns the directory it had.
+// Contexts are not per profile, so this ignores Profile.
+func (f *ConfigFile) SetContext(name, dir string) (string, error) {
+	contexts, ok := f.raw["contexts"].(map[string]any)
+	if !ok {
+		if _, exists := f.raw["contexts"]; exists {
+			return "", Errorf(CodeConfig, "%s: contexts is not an object", f.Path)
+		}
+		contexts = map[string]any{}
+		f.raw["contexts"] = contexts
+	}
+	var prev string
+	if c, ok := contexts[name].(map[string]any); ok {
+		prev = fmt.Sprint(c["dir"])
+	}
+	contexts[name] = map[string]any{"dir": dir}
+	return prev, nil
+}
+
+// UnknownKeys lists keys (including inside profiles, as profiles.NAME.KEY) that pumadevctl ignores,
+// typically typos.
+func (f *ConfigFile) UnknownKeys() []string {
+	known := map[string]bool{}
+	for _, k := range ConfigKeys() {
+		known[k] = true
+	}
+	var out []string
+	for k := range f.raw {
+		if !known[k] && k != "profiles" && k != "contexts" && k != "hooks" {
+			out = append(out, k)
+		}
+	}
+	profiles, _ := f.raw["profiles"].(map[string]any)
+	for name, p := range profiles {
+		p, _ := p.(map[string]any)
+		for k := range p {
+			if !known[k] {
+				out = append(out, "profiles."+name+"."+k)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// DropUnknownKeys removes the keys UnknownKeys reports.
+func (f *ConfigFile) DropUnknownKeys() {
+	for _, k := range f.UnknownKeys() {
+		parts := strings.SplitN(k, ".", 3)
+		if len(parts) == 3 && parts[0] == "profiles" {
+			if p, ok := f.raw["profiles"].(map[string]any)[parts[1]].(map[string]any); ok {
+				delete(p, parts[2])
+			}
+			continue
+		}
+		delete(f.raw, k)
+	}
+}
+
+// As returns a copy of the file to be written at path, in that path's format.
+func (f *ConfigFile) As(path string) *ConfigFile {
+	return &ConfigFile{Path: path, Profile: f.Profile, raw: f.raw}
+}
+
+// Bytes returns the file content in its format, keys sorted.
+func (f *ConfigFile) Bytes() ([]byte, error) {
+	return encodeConfigMap(f.Path, f.raw)
+}
+
+// Save writes the file atomically, creating its directory.
+func (f *ConfigFile) Save() error {
+	b, err := f.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Path), 0o755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(f.Path, b, 0o644); err != nil {
+		return err
+	}
+	f.Exists = true
+	return nil
+}
+
+// removeFile removes a file; tests replace it to make removal fail.
+var removeFile = os.Remove
+
+// Convert writes out (f in another format, see As) and removes f, so exactly one config file is left: when
+// f cannot be removed, out is removed again, since two config files in a directory fail every command.
+func (f *ConfigFile) Convert(out *ConfigFile) error {
+	if err := out.Save(); err != nil {
+		return err
+	}
+	if err := removeFile(f.Path); err != nil {
+		if rmErr := os.Remove(out.Path); rmErr != nil {
+			return fmt.Errorf("remove %s: %w (and %s could not be removed: %v)", f.Path, err, out.Path, rmErr)
+		}
+		out.Exists = false
+		return err
+	}
+	f.Exists = false
+	return nil
+}
+
+// Candidate returns the config the file would produce on its own: defaults, the system config when f is
+// another file, then f's values and its profile. Environment, project and flags are left out, so the result
+// says whether the file itself is sound. YAML and TOML files with unknown keys fail here as they would on load.
+func (f *ConfigFile) Candidate() (AppConfig, error) {
+	rc := &ResolvedConfig{Values: make([]ConfigValue, len(ConfigKeys()))}
+	rc.merge(DefaultAppConfig(), ConfigValue{})
+	if filepath.Dir(f.Path) != SystemConfigDir() {
+		sys, err := loadConfigFile(SystemConfigPath())
+		if err != nil {
+			return rc.AppConfig, err
+		}
+		if sys != nil {
+			rc.merge(sys.AppConfig, ConfigValue{})
+			if p, ok := sys.Profiles[f.Profile]; ok && f.Profile != "" {
+				rc.merge(p, ConfigValue{})
+			}
+		}
+	}
+	b, err := f.Bytes()
+	if err != nil {
+		return rc.AppConfig, err
+	}
+	own, err := decodeConfigFile(f.Path, b)
+	if err != nil {
+		return rc.AppConfig, err
+	}
+	rc.merge(own.AppConfig, ConfigValue{})
+	if p, ok := own.Profiles[f.Profile]; ok && f.Profile != "" {
+		rc.merge(p, ConfigValue{})
+	}
+	return rc.AppConfig, nil
+}
+
+// Replace sets the file's content to b (e.g. after editing it), which must parse in the file's format with
+// the right types for known keys.
+func (f *ConfigFile) Replace(b []byte) error {
+	raw, err := decodeConfigMap(f.Path, b)
+	if err != nil {
+		return Errorf(CodeValidationFailed, "%s: %v", f.Path, err)
+	}
+	if _, err := decodeConfigFile(f.Path, b); err != nil {
+		return WithCode(CodeValidationFailed, err)
+	}
+	f.raw = raw
+	return nil
+}
+
+// decodeConfigMap parses a config file of any format into nested maps, keeping every key.
+func decodeConfigMap(path string, b []byte) (map[string]any, error) {
+	raw := map[string]any{}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return raw, nil
+	}
+	var err error
+	switch configFormat(path) {
+	case FormatYAML:
+		err = yaml.Unmarshal(b, &raw)
+	case FormatTOML:
+		_, err = toml.Decode(string(b), &raw)
+	default:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		if err = dec.Decode(&raw); err == nil {
+			raw = jsonNumbers(raw).(map[string]any)
+		}
+	}
+	if raw == nil { // a YAML "null" document
+		raw = map[string]any{}
+	}
+	return raw, err
+}
+
+// jsonNumbers turns the json.Numbers of a decoded document into int64 or float64, so they encode as
+// numbers in YAML and TOML too.
+func jsonNumbers(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = jsonNumbers(e)
+		}
+	}
+	return v
+}
+
+func encodeConfigMap(path string, raw map[string]any) ([]byte, error) {
+	switch configFormat(path) {
+	case FormatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		err := enc.Encode(raw)
+		return buf.Bytes(), err
+	case FormatTOML:
+		var buf bytes.Buffer
+		err := toml.NewEncoder(&buf).Encode(raw)
+		return buf.Bytes(), err
+	}
+	b, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func configKeyType(key string) (reflect.Type, error) {
+	for i, k := range ConfigKeys() {
+		if k == key {
+			return reflect.TypeOf(AppConfig{}).Field(i).Type, nil
+		}
+	}
+	return nil, Errorf(CodeUsage, "unknown config key %q (known: %s)", key, strings.Join(ConfigKeys(), ", "))
+}
+
+// ConfigChange is the machine-readable result of config set and unset.
+type ConfigChange struct {
+	Key      string `json:"key"`
+	Status   string `json:"status"` // set, unset or unchanged
+	Value    string `json:"value,omitempty"`
+	Previous string `json:"previous,omitempty"`
+	File     string `json:"file"`
+	Profile  string `json:"profile,omitempty"`
+}
+
+// ConfigFileInfo is the machine-readable result of config path, init, edit and convert.
+type ConfigFileInfo struct {
+	Path    string `json:"path"`
+	Format  string `json:"format"` // json, yaml or toml
+	Layer   string `json:"layer"`  // user or system
+	Exists  bool   `json:"exists"`
+	Profile string `json:"profile,omitempty"`
+}
@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// OriginContext marks the dir taken from the selected context in config show --origin.
+const OriginContext = "context"
+
+// Context is a named mappings directory, for running several puma-dev instances (e.g. one per Ruby version)
+// side by side. Contexts are declared under "contexts" in the config files and selected with the "context"
+// key (--context, PUMADEVCTL_CONTEXT, `context use`).
+type Context struct {
+	Dir string `json:"dir" yaml:"dir" toml:"dir"`
+}
+
+// ContextInfo describes a context for context list and multi-context commands.
+type ContextInfo struct {
+	Name    string `json:"name"`
+	Dir     string `json:"dir"`
+	Current bool   `json:"current"`
+	Exists  bool   `json:"exists"`
+	Entries int    `json:"entries"`
+}
+
+// ApplyContext points dir at the selected context's directory unless --dir, PUMADEVCTL_DIR or the project
+// file gave one: the context ranks with the config file selecting it, below those layers, and a plugin's
+// nested pumadevctl keeps the dir it was handed. It fails with a config error, leaving dir alone, when the
+// context is not declared; Validate reports that too.
+func (rc *ResolvedConfig) ApplyContext() error {
+	if rc.Context == "" {
+		return nil
+	}
+	c, ok := rc.Contexts[rc.Context]
+	if !ok {
+		return Errorf(CodeConfig, "unknown context %q: add it with `pumadevctl context add %s DIR`", rc.Context, rc.Context)
+	}
+	field, _ := reflect.TypeOf(rc.AppConfig).FieldByName("Dir")
+	i := field.Index[0]
+	switch rc.Values[i].Origin {
+	case OriginFlag, OriginEnv, OriginProject:
+		return nil
+	}
+	rc.setField(i, reflect.ValueOf(expandHome(c.Dir)), ConfigValue{Origin: OriginContext, Source: rc.Context})
+	return nil
+}
+
+// ContextList returns the declared contexts sorted by name, with their entry counts.
+func (rc *ResolvedConfig) ContextList() []ContextInfo {
+	out := make([]ContextInfo, 0, len(rc.Contexts))
+	for name, c := range rc.Contexts {
+		info := ContextInfo{Name: name, Dir: expandHome(c.Dir), Current: name == rc.Context}
+		if entries, err := LoadEntries(info.Dir); err == nil {
+			info.Exists, info.Entries = true, len(entries)
+		}
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// ValidateContextName checks a context name: a short identifier, since it is used as a config key.
+func ValidateContextName(name string) error {
+	if name == "" || len(name) > 63 || strings.Trim(name, "abcdefghijklmnopqrstuvwxyz0123456789-_") != "" {
+		return Errorf(CodeUsage, "invalid context name %q: use lowercase letters, digits, - and _", name)
+	}
+	return nil
+}
+
+// LoadContextEntries loads the entries of every context, recording the context on each.
+func LoadContextEntries(contexts []ContextInfo) ([]Entry, error) {
+	var all []Entry
+	for _, c := range contexts {
+		entries, err := LoadEntries(c.Dir)
+		if err != nil {
+			return nil, Errorf(CodeConfig, "context %s: %v", c.Name, err)
+		}
+		for i := range entries {
+			entries[i].Context = c.Name
+		}
+		all = append(all, entries...)
+	}
+	return all, nil
+}
+
+// ContextReservations returns the blocks reserved by entries of contexts other than dir, so allocation in one
+// puma-dev directory avoids ports apps of another instance use.
+func ContextReservations(contexts []ContextInfo, dir string, block int) []Reservation {
+	var out []Reservation
+	for _, c := range contexts {
+		if filepath.Clean(c.Dir) == filepath.Clean(dir) {
+			continue
+		}
+		entries, err := LoadEntries(c.Dir)
+		if err != nil {
+			continue
+		}
+		for _, r := range EntryReservations(entries, block) {
+			r.Source = "entry in context " + c.Name
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// PortConflict is a pair of entries in different contexts whose port blocks overlap.
+type PortConflict struct {
+	Entries []string `json:"entries"` // context/domain
+	Ports   []int    `json:"ports"`   // base ports, in Entries order
+	Message string   `json:"message"`
+}
+
+// ContextConflicts finds entries of different contexts whose blocks of block ports overlap. Both puma-dev
+// instances proxy to apps on the same host, so such apps cannot run at the same time. The same port on
+// different hosts is not a conflict. A non-empty focus keeps only the conflicts involving that context.
+func ContextConflicts(entries []Entry, block int, focus string) []PortConflict {
+	type bound struct {
+		e Entry
+		m *Mapping
+	}
+	var list []bound
+	for _, e := range entries {
+		if e.IsSymlink {
+			continue
+		}
+		if m, err := ParseMapping(e.Mapping); err == nil {
+			list = append(list, bound{e, m})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].m.Port < list[j].m.Port })
+	var out []PortConflict
+	for i, a := range list {
+		for _, b := range list[i+1:] {
+			if b.m.Port-a.m.Port >= block {
+				break
+			}
+			if a.e.Context == b.e.Context || focus != "" && a.e.Context != focus && b.e.Context != focus ||
+				b.m.Port == a.m.Port && NormalizeTarget(a.m) != NormalizeTarget(b.m) {
+				continue
+			}
+			what := fmt.Sprintf("blocks %d-%d and %d-%d overlap", a.m.Port, a.m.Port+block-1, b.m.Port, b.m.Port+block-1)
+			if a.m.Port == b.m.Port {
+				what = fmt.Sprintf("both use port %d", a.m.Port)
+			}
+			ea, eb := a.e.QualifiedDomain(), b.e.QualifiedDomain()
+			out = append(out, PortConflict{Entries: []string{ea, eb}, Ports: []int{a.m.Port, b.m.Port},
+				Message: fmt.Sprintf("%s and %s: %s", ea, eb, what)})
+		}
+	}
+	return out
+}
+
+// expandHome expands a leading ~ to the home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, p[1:])
+	}
+	return p
+}
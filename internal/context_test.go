@@ -0,0 +1,152 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveConfig_Context(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "user"))
+	t.Setenv("PUMADEVCTL_PROFILE", "")
+	for _, k := range ConfigKeys() {
+		t.Setenv(ConfigEnvVar(k), "")
+	}
+	prev := systemConfigDir
+	systemConfigDir = func() string { return filepath.Join(root, "system") }
+	t.Cleanup(func() { systemConfigDir = prev })
+
+	ruby2, ruby3 := filepath.Join(root, "ruby2"), filepath.Join(root, "ruby3")
+	f, err := OpenConfigFile(ConfigPath(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, dir := range map[string]string{"ruby2": ruby2, "ruby3": ruby3} {
+		if _, err := f.SetContext(name, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.Set("context", "ruby3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := f.UnknownKeys(); len(keys) != 0 {
+		t.Errorf("contexts reported as unknown keys: %v", keys)
+	}
+
+	rc, err := ResolveConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.ApplyContext(); err != nil {
+		t.Fatal(err)
+	}
+	if rc.Dir != ruby3 || rc.Values[0].Origin != OriginContext || rc.Values[0].Source != "ruby3" {
+		t.Errorf("dir = %s from %+v, want %s from context ruby3", rc.Dir, rc.Values[0], ruby3)
+	}
+
+	t.Setenv("PUMADEVCTL_DIR", root)
+	rc, err = ResolveConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.ApplyContext(); err != nil || rc.Dir != root || rc.Values[0].Origin != OriginEnv {
+		t.Errorf("PUMADEVCTL_DIR should win over the context, got %s from %+v (%v)", rc.Dir, rc.Values[0], err)
+	}
+	t.Setenv("PUMADEVCTL_DIR", "")
+
+	project := filepath.Join(root, "project")
+	if err := os.MkdirAll(project, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeProjectFile(t, project, "domain: myapp\ndir: mappings\n")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(project); err != nil {
+		t.Fatal(err)
+	}
+	rc, err = ResolveConfig("")
+	_ = os.Chdir(wd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.ApplyContext(); err != nil || rc.Dir != filepath.Join(project, "mappings") || rc.Values[0].Origin != OriginProject {
+		t.Errorf("the project file's dir should win over the context, got %s from %+v (%v)", rc.Dir, rc.Values[0], err)
+	}
+
+	t.Setenv("PUMADEVCTL_CONTEXT", "ruby2")
+	rc, err = ResolveConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.Set("dir", root, OriginFlag, "--dir"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.ApplyContext(); err != nil || rc.Dir != root {
+		t.Errorf("--dir should win over the context, got %s (%v)", rc.Dir, err)
+	}
+
+	if err := rc.Set("context", "ruby4", OriginFlag, "--context"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rc.ApplyContext(); CodeOf(err) != CodeConfig {
+		t.Errorf("expected a config error for an unknown context, got %v", err)
+	}
+	found := false
+	for _, issue := range rc.Validate() {
+		found = found || issue.Key == "context" && issue.Severity == SeverityError && issue.Origin == OriginFlag
+	}
+	if !found {
+		t.Errorf("Validate should report the unknown context from --context")
+	}
+}
+
+func TestContextConflicts(t *testing.T) {
+	entries := []Entry{
+		{Domain: "api", Mapping: "36000", Context: "ruby2"},
+		{Domain: "web", Mapping: "36005", Context: "ruby3"},   // overlaps api's block
+		{Domain: "admin", Mapping: "36100", Context: "ruby2"}, // same block as shop, but in the same context
+		{Domain: "shop", Mapping: "36100", Context: "ruby2"},
+		{Domain: "vm", Mapping: "10.0.0.5:36100", Context: "ruby3"}, // same port on another host
+		{Domain: "docs", Mapping: "36200", Context: "ruby3"},
+		{Domain: "blog", Mapping: "localhost:36200", Context: "ruby2"},
+		{Domain: "site", IsSymlink: true, LinkTarget: "/src/site", Context: "ruby3"},
+	}
+	got := ContextConflicts(entries, 10, "")
+	if len(got) != 2 {
+		t.Fatalf("expected 2 conflicts, got %+v", got)
+	}
+	if got[0].Entries[0] != "ruby2/api" || got[0].Entries[1] != "ruby3/web" {
+		t.Errorf("unexpected first conflict %+v", got[0])
+	}
+	if got[1].Message != "ruby3/docs and ruby2/blog: both use port 36200" && got[1].Message != "ruby2/blog and ruby3/docs: both use port 36200" {
+		t.Errorf("unexpected second conflict %+v", got[1])
+	}
+	if got := ContextConflicts(entries, 10, "ruby4"); len(got) != 0 {
+		t.Errorf("focus on a context without entries should find nothing, got %+v", got)
+	}
+	if got := ContextConflicts(entries, 1, ""); len(got) != 1 {
+		t.Errorf("with 1-port blocks only the shared port 36200 conflicts, got %+v", got)
+	}
+}
+
+func TestContextReservations(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(b, "web"), []byte("36000"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	contexts := []ContextInfo{{Name: "a", Dir: a}, {Name: "b", Dir: b}}
+	alloc := &Allocator{Min: 36000, Max: 36100, Block: 10, Dir: a, Reserved: ContextReservations(contexts, a, 10)}
+	base, err := alloc.Allocate("api", nil)
+	if err != nil || base != 36010 {
+		t.Fatalf("Allocate = %d, %v; want 36010, skipping context b's block", base, err)
+	}
+	if err := alloc.Check("api", 36005, nil); CodeOf(err) != CodeAlreadyExists {
+		t.Errorf("expected the other context's block to be taken, got %v", err)
+	}
+}
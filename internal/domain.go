@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Domain names are puma-dev entry names: one or more DNS labels joined by dots. puma-dev serves the entry
+// "api.myapp" for api.myapp.test and falls back from a nested host to its parent, so "myapp" also answers
+// www.myapp.test. Directories are not entries, which is why nested hosts are spelled with dots (or a hyphen
+// inside a single label, "api-myapp") and never with "/".
+
+const maxDomainLength = 253
+
+// NormalizeDomain turns a user-supplied name into an entry name: surrounding space and a trailing dot are
+// dropped, letters are lowercased and a trailing "."+tld (DefaultTLD when empty) is stripped, so "MyApp",
+// "myapp.test" and "myapp.test." all become "myapp". The result must pass ValidateDomain.
+func NormalizeDomain(name, tld string) (string, error) {
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	d := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	d = strings.TrimSuffix(d, "."+tld)
+	if err := ValidateDomain(d); err != nil {
+		return "", err
+	}
+	return d, nil
+}
+
+// ValidateDomain checks that name is a safe entry name: DNS labels of lowercase letters, digits and inner
+// hyphens, at most 63 bytes each and 253 in total, joined by single dots. Path separators, "." and ".." are
+// rejected, so a name can never escape the mappings directory.
+func ValidateDomain(name string) error {
+	switch {
+	case name == "":
+		return Errorf(CodeUsage, "domain is required")
+	case strings.ContainsAny(name, `/\`):
+		return Errorf(CodeUsage, "domain %q must not contain path separators; nested hosts use dots, e.g. api.myapp", name)
+	case len(name) > maxDomainLength:
+		return Errorf(CodeUsage, "domain %q is longer than %d characters", name, maxDomainLength)
+	}
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return Errorf(CodeUsage, "domain %q has an empty label", name)
+		}
+		if len(label) > 63 {
+			return Errorf(CodeUsage, "domain %q has a label longer than 63 characters", name)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return Errorf(CodeUsage, "domain %q: label %q must not start or end with a hyphen", name, label)
+		}
+		for _, r := range label {
+			if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' {
+				continue
+			}
+			if r == '_' {
+				return Errorf(CodeUsage, "domain %q contains '_', which is not valid in hostnames; use '-'", name)
+			}
+			return Errorf(CodeUsage, "domain %q contains %q; use lowercase letters, digits, hyphens and dots", name, r)
+		}
+	}
+	return nil
+}
+
+// LookupDomain resolves a name given for an existing entry. The normalized name is preferred; an entry whose
+// name does not normalize (say "MyApp", created by hand) can still be addressed verbatim, so lint, rename and
+// delete can repair it. Names with path separators or a leading dot are always rejected.
+func LookupDomain(dir, name, tld string) (string, error) {
+	d, err := NormalizeDomain(name, tld)
+	if err == nil && (d == name || entryExists(dir, d)) {
+		return d, nil
+	}
+	if !strings.HasPrefix(name, ".") && !strings.ContainsAny(name, `/\`) && entryExists(dir, name) {
+		return name, nil
+	}
+	return d, err
+}
+
+func entryExists(dir, domain string) bool {
+	_, err := os.Lstat(filepath.Join(dir, domain))
+	return err == nil
+}
@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalizeDomain(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "myapp", want: "myapp"},
+		{in: "MyApp", want: "myapp"},
+		{in: "  myapp  ", want: "myapp"},
+		{in: "myapp.test", want: "myapp"},
+		{in: "myapp.test.", want: "myapp"},
+		{in: "API.MyApp.TEST", want: "api.myapp"},
+		{in: "api-myapp", want: "api-myapp"},
+		{in: "v2.api.myapp", want: "v2.api.myapp"},
+		{in: "app42", want: "app42"},
+		{in: "", wantErr: true},
+		{in: ".test", wantErr: true},
+		{in: "../etc/passwd", wantErr: true},
+		{in: "..", wantErr: true},
+		{in: ".", wantErr: true},
+		{in: "api/myapp", wantErr: true},
+		{in: `api\myapp`, wantErr: true},
+		{in: "my app", wantErr: true},
+		{in: "my_app", wantErr: true},
+		{in: "-myapp", wantErr: true},
+		{in: "myapp-", wantErr: true},
+		{in: "api..myapp", wantErr: true},
+		{in: ".hidden", wantErr: true},
+		{in: "café", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := NormalizeDomain(tt.in, "")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				if CodeOf(err) != CodeUsage {
+					t.Fatalf("expected a usage error, got %v (%s)", err, CodeOf(err))
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("got %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDomain_Lengths(t *testing.T) {
+	label := func(n int) string {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = 'a'
+		}
+		return string(b)
+	}
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{label(63), true},
+		{label(64), false},
+		{label(63) + "." + label(63) + "." + label(63) + "." + label(61), true}, // 253
+		{label(63) + "." + label(63) + "." + label(63) + "." + label(62), false},
+	}
+	for _, tt := range tests {
+		if err := ValidateDomain(tt.name); (err == nil) != tt.ok {
+			t.Errorf("len %d: got %v, want ok=%v", len(tt.name), err, tt.ok)
+		}
+	}
+}
+
+func TestLookupDomain(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"myapp", "Legacy"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("36000"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "myapp", want: "myapp"},
+		{in: "MYAPP.test", want: "myapp"},
+		{in: "Legacy", want: "Legacy"}, // hand-made entry stays addressable
+		{in: "legacy", want: "legacy"}, // normalized name is preferred, even if missing
+		{in: "newapp", want: "newapp"},
+		{in: "../myapp", wantErr: true},
+		{in: ".pumadevctl", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := LookupDomain(dir, tt.in, "")
+			if (err != nil) != tt.wantErr || got != tt.want && !tt.wantErr {
+				t.Fatalf("got %q, %v; want %q (err %v)", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
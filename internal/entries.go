@@ -15,6 +15,16 @@ type Entry struct {
 	Mapping    string `json:"mapping"` // "" for symlink
 	IsSymlink  bool   `json:"is_symlink"`
 	LinkTarget string `json:"link_target,omitempty"`
+	Meta       *Meta  `json:"meta,omitempty"`
+	Context    string `json:"context,omitempty"` // set when entries of several contexts are listed together
+}
+
+// QualifiedDomain is the domain prefixed with its context ("ruby2/myapp") when the entry has one.
+func (e Entry) QualifiedDomain() string {
+	if e.Context == "" {
+		return e.Domain
+	}
+	return e.Context + "/" + e.Domain
 }
 
 func LoadEntries(dir string) ([]Entry, error) {
@@ -61,14 +71,34 @@ func LoadEntries(dir string) ([]Entry, error) {
 		})
 	}
 	sort.Slice(entries, func(i, j int) bool { return entries[i].Domain < entries[j].Domain })
+	meta, err := LoadMeta(dir)
+	if err != nil {
+		return nil, err
+	}
+	meta.Attach(entries)
 	return entries, nil
 }
 
 func ReadEntry(dir, domain string) (*Entry, error) {
+	e, err := readEntry(dir, domain)
+	if err != nil {
+		return nil, err
+	}
+	meta, err := LoadMeta(dir)
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := meta.Get(domain); ok {
+		e.Meta = &m
+	}
+	return e, nil
+}
+
+func readEntry(dir, domain string) (*Entry, error) {
 	full := filepath.Join(dir, domain)
 	info, err := os.Lstat(full)
 	if err != nil {
-		return nil, err
+		return nil, notFound(domain, err)
 	}
 	if info.Mode()&fs.ModeSymlink != 0 {
 		target, err := os.Readlink(full)
@@ -85,26 +115,26 @@ func ReadEntry(dir, domain string) (*Entry, error) {
 }
 
 func WriteEntry(dir, domain, mapping string, overwrite bool) error {
-	if domain == "" {
-		return errors.New("domain is required")
+	if err := ValidateDomain(domain); err != nil {
+		return err
 	}
 	full := filepath.Join(dir, domain)
 	if !overwrite {
 		if _, err := os.Lstat(full); err == nil {
-			return fmt.Errorf("entry %s already exists", domain)
+			return Errorf(CodeAlreadyExists, "entry %s already exists", domain)
 		}
 	}
 	return os.WriteFile(full, []byte(mapping+""), 0644)
 }
 
 func CreateSymlink(dir, domain, target string, overwrite bool) error {
-	if domain == "" {
-		return errors.New("domain is required")
+	if err := ValidateDomain(domain); err != nil {
+		return err
 	}
 	full := filepath.Join(dir, domain)
 	if !overwrite {
 		if _, err := os.Lstat(full); err == nil {
-			return fmt.Errorf("entry %s already exists", domain)
+			return Errorf(CodeAlreadyExists, "entry %s already exists", domain)
 		}
 	} else {
 		// remove any existing
@@ -115,18 +145,92 @@ func CreateSymlink(dir, domain, target string, overwrite bool) error {
 
 func UpdateEntry(dir, domain, mapping string) error {
 	full := filepath.Join(dir, domain)
+	if _, err := os.Lstat(full); err != nil {
+		return notFound(domain, err)
+	}
 	return os.WriteFile(full, []byte(mapping+""), 0644)
 }
 
 func UpdateSymlink(dir, domain, target string) error {
 	full := filepath.Join(dir, domain)
 	if err := os.Remove(full); err != nil {
-		return err
+		return notFound(domain, err)
 	}
 	return os.Symlink(target, full)
 }
 
+// DeleteEntry removes the entry and any metadata recorded for it.
 func DeleteEntry(dir, domain string) error {
 	full := filepath.Join(dir, domain)
-	return os.Remove(full)
+	if err := os.Remove(full); err != nil {
+		return notFound(domain, err)
+	}
+	err := updateMeta(dir, func(s *MetaStore) bool {
+		if _, ok := s.Get(domain); !ok {
+			return false
+		}
+		s.Delete(domain)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("deleted %s but failed to update metadata: %w", domain, err)
+	}
+	return nil
+}
+
+// lstat is os.Lstat, replaced in tests to mimic a case-insensitive filesystem.
+var lstat = os.Lstat
+
+// RenameEntry moves an entry (file or symlink) to a new domain name, carrying its metadata along.
+func RenameEntry(dir, oldDomain, newDomain string, overwrite bool) error {
+	if err := ValidateDomain(newDomain); err != nil {
+		return err
+	}
+	src := filepath.Join(dir, oldDomain)
+	dst := filepath.Join(dir, newDomain)
+	srcInfo, err := os.Lstat(src)
+	if err != nil {
+		return notFound(oldDomain, err)
+	}
+	// on a case-insensitive filesystem (macOS by default) MyApp → myapp finds the source itself at dst
+	dstInfo, err := lstat(dst)
+	sameFile := err == nil && os.SameFile(srcInfo, dstInfo)
+	if err == nil && !sameFile && !overwrite {
+		return Errorf(CodeAlreadyExists, "entry %s already exists", newDomain)
+	}
+	if sameFile {
+		// a direct rename may leave the old spelling in place, so go through a temporary name
+		tmp := filepath.Join(dir, "."+newDomain+".rename")
+		if err := os.Rename(src, tmp); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, dst); err != nil {
+			_ = os.Rename(tmp, src)
+			return err
+		}
+	} else if err := os.Rename(src, dst); err != nil {
+		return err
+	}
+	err = updateMeta(dir, func(s *MetaStore) bool {
+		_, hadOld := s.Get(oldDomain)
+		_, hadNew := s.Get(newDomain)
+		if !hadOld && !hadNew {
+			return false
+		}
+		s.Delete(newDomain) // an overwritten entry's metadata goes with it
+		s.Rename(oldDomain, newDomain)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("renamed %s but failed to update metadata: %w", oldDomain, err)
+	}
+	return nil
+}
+
+// notFound turns a missing-file error into a coded "entry does not exist" error.
+func notFound(domain string, err error) error {
+	if errors.Is(err, fs.ErrNotExist) {
+		return Errorf(CodeNotFound, "entry %s does not exist", domain)
+	}
+	return err
 }
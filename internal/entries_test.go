@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRenameEntry_CaseOnly(t *testing.T) {
+	dir := t.TempDir()
+	// lint finds such names in dirs edited by hand
+	if err := os.WriteFile(filepath.Join(dir, "MyApp"), []byte("36000"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// look names up like a case-insensitive filesystem does
+	prev := lstat
+	lstat = func(path string) (os.FileInfo, error) {
+		items, err := os.ReadDir(filepath.Dir(path))
+		if err != nil {
+			return nil, err
+		}
+		for _, de := range items {
+			if strings.EqualFold(de.Name(), filepath.Base(path)) {
+				return os.Lstat(filepath.Join(filepath.Dir(path), de.Name()))
+			}
+		}
+		return os.Lstat(path)
+	}
+	t.Cleanup(func() { lstat = prev })
+
+	if err := RenameEntry(dir, "MyApp", "myapp", false); err != nil {
+		t.Fatalf("a case-only rename must not find its own source in the way: %v", err)
+	}
+	entries, err := LoadEntries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Domain != "myapp" || entries[0].Mapping != "36000" {
+		t.Errorf("expected only myapp → 36000, got %+v", entries)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "Other"), []byte("36010"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenameEntry(dir, "Other", "myapp", false); CodeOf(err) != CodeAlreadyExists {
+		t.Errorf("renaming onto another entry should still fail, got %v", err)
+	}
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrorCode classifies failures for scripts; each code has a fixed process exit status.
+type ErrorCode string
+
+const (
+	CodeError            ErrorCode = "error"             // exit 1: anything not covered below
+	CodeUsage            ErrorCode = "usage"             // exit 2: bad arguments or flags
+	CodeNotFound         ErrorCode = "not_found"         // exit 3: entry, CA or file does not exist
+	CodeAlreadyExists    ErrorCode = "already_exists"    // exit 4: entry exists and --force was not given
+	CodeValidationFailed ErrorCode = "validation_failed" // exit 5: validate found unreachable or unhealthy entries
+	CodeLocked           ErrorCode = "locked"            // exit 6: another pumadevctl holds the directory lock
+	CodeNoFreePort       ErrorCode = "no_free_port"      // exit 7: no port block left in the configured range
+	CodeConfig           ErrorCode = "config"            // exit 8: invalid configuration or mappings directory
+	CodeHookFailed       ErrorCode = "hook_failed"       // exit 9: a hook failed; for pre hooks the change was not made
+)
+
+var exitCodes = map[ErrorCode]int{
+	CodeError:            1,
+	CodeUsage:            2,
+	CodeNotFound:         3,
+	CodeAlreadyExists:    4,
+	CodeValidationFailed: 5,
+	CodeLocked:           6,
+	CodeNoFreePort:       7,
+	CodeConfig:           8,
+	CodeHookFailed:       9,
+}
+
+// ExitCode returns the process exit status for code.
+func (c ErrorCode) ExitCode() int {
+	if n, ok := exitCodes[c]; ok {
+		return n
+	}
+	return 1
+}
+
+// Error is an error with a machine-readable code.
+type Error struct {
+	Code    ErrorCode
+	Message string
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Message != "" {
+		return e.Message
+	}
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return string(e.Code)
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// Errorf builds a coded error; %w wraps like fmt.Errorf.
+func Errorf(code ErrorCode, format string, a ...any) error {
+	err := fmt.Errorf(format, a...)
+	return &Error{Code: code, Message: err.Error(), Err: errors.Unwrap(err)}
+}
+
+// WithCode tags err with code unless it is nil or already coded.
+func WithCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *Error
+	if errors.As(err, &ce) {
+		return err
+	}
+	return &Error{Code: code, Message: err.Error(), Err: err}
+}
+
+// ExitStatus is returned when a child process started by pumadevctl (exec, plugins) exits non-zero; the CLI
+// exits with the same status and prints nothing, since the child reported its own failure.
+type ExitStatus int
+
+func (s ExitStatus) Error() string { return fmt.Sprintf("exit status %d", int(s)) }
+
+// CodeOf classifies err: coded errors keep their code, missing/existing files map to
+// not_found/already_exists, everything else is a generic error.
+func CodeOf(err error) ErrorCode {
+	var ce *Error
+	switch {
+	case err == nil:
+		return ""
+	case errors.As(err, &ce):
+		return ce.Code
+	case errors.Is(err, fs.ErrNotExist):
+		return CodeNotFound
+	case errors.Is(err, fs.ErrExist):
+		return CodeAlreadyExists
+	}
+	return CodeError
+}
+
+// ErrorDocument is the JSON shape of an error in machine output modes: {"error":{"code":...,"message":...}}.
+type ErrorDocument struct {
+	Error ErrorBody `json:"error"`
+}
+
+// ErrorBody carries the code, its exit status and the human message.
+type ErrorBody struct {
+	Code     ErrorCode `json:"code"`
+	ExitCode int       `json:"exit_code"`
+	Message  string    `json:"message"`
+}
+
+// NewErrorDocument describes err for machine output.
+func NewErrorDocument(err error) ErrorDocument {
+	code := CodeOf(err)
+	return ErrorDocument{Error: ErrorBody{Code: code, ExitCode: code.ExitCode(), Message: err.Error()}}
+}
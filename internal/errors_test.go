@@ -0,0 +1,56 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCodeOf(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteEntry(dir, "app", "3000", false); err != nil {
+		t.Fatal(err)
+	}
+	_, missing := os.Stat(filepath.Join(dir, "nope"))
+	tests := []struct {
+		name string
+		err  error
+		code ErrorCode
+		exit int
+	}{
+		{"exists", WriteEntry(dir, "app", "3001", false), CodeAlreadyExists, 4},
+		{"read missing", func() error { _, err := ReadEntry(dir, "nope"); return err }(), CodeNotFound, 3},
+		{"update missing", UpdateEntry(dir, "nope", "3000"), CodeNotFound, 3},
+		{"delete missing", DeleteEntry(dir, "nope"), CodeNotFound, 3},
+		{"wrapped", fmt.Errorf("create: %w", Errorf(CodeNoFreePort, "full")), CodeNoFreePort, 7},
+		{"fs error", missing, CodeNotFound, 3},
+		{"plain", errors.New("boom"), CodeError, 1},
+	}
+	for _, tt := range tests {
+		if got := CodeOf(tt.err); got != tt.code || got.ExitCode() != tt.exit {
+			t.Errorf("%s: CodeOf(%v) = %s (exit %d), want %s (exit %d)", tt.name, tt.err, got, got.ExitCode(), tt.code, tt.exit)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nope")); !os.IsNotExist(err) {
+		t.Fatalf("update must not create missing entries")
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dir := t.TempDir()
+	release, err := LockDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LockDir(dir); CodeOf(err) != CodeLocked {
+		t.Fatalf("expected locked error while held, got %v", err)
+	}
+	release()
+	release, err = LockDir(dir)
+	if err != nil {
+		t.Fatalf("expected lock after release, got %v", err)
+	}
+	release()
+}
@@ -0,0 +1,472 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FilterOptions is the user-facing description of a filter, typically straight from CLI flags.
+type FilterOptions struct {
+	Match     []string // domain globs, e.g. "api-*"; any may match
+	Type      string   // "file" or "symlink"
+	Host      string   // mapping host, e.g. "127.0.0.1"
+	PortRange string   // "36000-36100" or a single port
+	Tags      []string // all must be present
+	Reachable *bool    // nil: don't care
+	Expr      string   // e.g. "port>=36500 && type==file"
+	TimeoutMs int      // dial timeout when reachability is needed
+}
+
+// Filter selects entries. Build one with NewFilter; the zero value matches everything.
+type Filter struct {
+	opts    FilterOptions
+	portMin int
+	portMax int
+	expr    exprNode
+}
+
+// NewFilter validates opts and compiles the expression.
+func NewFilter(opts FilterOptions) (*Filter, error) {
+	f := &Filter{opts: opts}
+	switch opts.Type {
+	case "", "file", "symlink":
+	default:
+		return nil, fmt.Errorf("invalid type %q (want file or symlink)", opts.Type)
+	}
+	for _, g := range opts.Match {
+		if _, err := path.Match(g, ""); err != nil {
+			return nil, fmt.Errorf("invalid match pattern %q: %w", g, err)
+		}
+	}
+	if opts.PortRange != "" {
+		lo, hi, err := ParsePortRange(opts.PortRange)
+		if err != nil {
+			return nil, err
+		}
+		f.portMin, f.portMax = lo, hi
+	}
+	if strings.TrimSpace(opts.Expr) != "" {
+		n, err := parseExpr(opts.Expr)
+		if err != nil {
+			return nil, err
+		}
+		f.expr = n
+	}
+	return f, nil
+}
+
+// ParsePortRange parses "LO-HI" or a single port.
+func ParsePortRange(s string) (int, int, error) {
+	loStr, hiStr, found := strings.Cut(strings.TrimSpace(s), "-")
+	if !found {
+		hiStr = loStr
+	}
+	lo, err1 := strconv.Atoi(strings.TrimSpace(loStr))
+	hi, err2 := strconv.Atoi(strings.TrimSpace(hiStr))
+	if err1 != nil || err2 != nil || lo < 1 || hi > 65535 || lo > hi {
+		return 0, 0, fmt.Errorf("invalid port range %q (want LO-HI)", s)
+	}
+	return lo, hi, nil
+}
+
+// IsEmpty reports whether the filter matches everything.
+func (f *Filter) IsEmpty() bool {
+	o := f.opts
+	return len(o.Match) == 0 && o.Type == "" && o.Host == "" && o.PortRange == "" &&
+		len(o.Tags) == 0 && o.Reachable == nil && f.expr == nil
+}
+
+// NeedsReachability reports whether matching requires dialing entries.
+func (f *Filter) NeedsReachability() bool {
+	return f.opts.Reachable != nil || (f.expr != nil && f.expr.usesField("reachable"))
+}
+
+// Match reports whether e passes the filter. reachable is only called when needed.
+func (f *Filter) Match(e Entry, reachable func() bool) bool {
+	o := f.opts
+	if len(o.Match) > 0 {
+		ok := false
+		for _, g := range o.Match {
+			if m, _ := path.Match(g, e.Domain); m {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if o.Type != "" && entryType(e) != o.Type {
+		return false
+	}
+	if o.Host != "" || o.PortRange != "" {
+		m, err := ParseMapping(e.Mapping)
+		if e.IsSymlink || err != nil {
+			return false
+		}
+		if o.Host != "" && m.Host != o.Host {
+			return false
+		}
+		if o.PortRange != "" && (m.Port < f.portMin || m.Port > f.portMax) {
+			return false
+		}
+	}
+	for _, t := range o.Tags {
+		if e.Meta == nil || !e.Meta.HasTag(t) {
+			return false
+		}
+	}
+	if o.Reachable != nil && reachable() != *o.Reachable {
+		return false
+	}
+	if f.expr != nil && !f.expr.eval(e, reachable) {
+		return false
+	}
+	return true
+}
+
+// Apply returns the entries matching f, dialing them only if the filter needs reachability.
+func (f *Filter) Apply(entries []Entry) []Entry {
+	if f == nil || f.IsEmpty() {
+		return entries
+	}
+	timeout := time.Duration(f.opts.TimeoutMs) * time.Millisecond
+	out := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		e := e
+		if f.Match(e, func() bool { return entryReachable(e, timeout) }) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// ApplyResults filters validation results, reusing their reachability instead of dialing again.
+func (f *Filter) ApplyResults(results []ValidationResult) []ValidationResult {
+	if f == nil || f.IsEmpty() {
+		return results
+	}
+	out := make([]ValidationResult, 0, len(results))
+	for _, r := range results {
+		r := r
+		if f.Match(r.Entry, func() bool { return r.Reachable }) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func entryType(e Entry) string {
+	if e.IsSymlink {
+		return "symlink"
+	}
+	return "file"
+}
+
+// entryReachable mirrors ValidateEntries: symlinks count as reachable.
+func entryReachable(e Entry, timeout time.Duration) bool {
+	if e.IsSymlink {
+		return true
+	}
+	m, err := ParseMapping(e.Mapping)
+	if err != nil {
+		return false
+	}
+	return IsPortReachable(m.Host, m.Port, timeout)
+}
+
+// Expression language:
+//
+//	expr  := or
+//	or    := and ("||" and)*
+//	and   := unary ("&&" unary)*
+//	unary := "!" unary | "(" expr ")" | field op value
+//	op    := "==" | "!=" | ">=" | "<=" | ">" | "<" | "=~" (glob)
+//
+// Fields: domain, type, host, port, mapping, target, tag, owner, reachable.
+// Ordering operators are only valid on port.
+
+var exprFields = map[string]bool{
+	"domain": true, "type": true, "host": true, "port": true, "mapping": true,
+	"target": true, "tag": true, "owner": true, "reachable": true,
+}
+
+type exprNode interface {
+	eval(e Entry, reachable func() bool) bool
+	usesField(name string) bool
+}
+
+type exprAnd struct{ l, r exprNode }
+type exprOr struct{ l, r exprNode }
+type exprNot struct{ n exprNode }
+type exprCmp struct{ field, op, value string }
+
+func (n exprAnd) eval(e Entry, r func() bool) bool { return n.l.eval(e, r) && n.r.eval(e, r) }
+func (n exprOr) eval(e Entry, r func() bool) bool  { return n.l.eval(e, r) || n.r.eval(e, r) }
+func (n exprNot) eval(e Entry, r func() bool) bool { return !n.n.eval(e, r) }
+func (n exprAnd) usesField(f string) bool          { return n.l.usesField(f) || n.r.usesField(f) }
+func (n exprOr) usesField(f string) bool           { return n.l.usesField(f) || n.r.usesField(f) }
+func (n exprNot) usesField(f string) bool          { return n.n.usesField(f) }
+func (n exprCmp) usesField(f string) bool          { return n.field == f }
+
+func (n exprCmp) eval(e Entry, reachable func() bool) bool {
+	switch n.field {
+	case "port":
+		m, err := ParseMapping(e.Mapping)
+		if e.IsSymlink || err != nil {
+			return false
+		}
+		want, _ := strconv.Atoi(n.value)
+		return compareInts(m.Port, n.op, want)
+	case "tag":
+		// tag==x means "has tag x"; tag=~glob means "has a tag matching glob"
+		has := false
+		if e.Meta != nil {
+			for _, t := range e.Meta.Tags {
+				if (n.op == "=~" && matchString(t, n.op, n.value)) || t == normalizeTag(n.value) {
+					has = true
+					break
+				}
+			}
+		}
+		if n.op == "!=" {
+			return !has
+		}
+		return has
+	case "reachable":
+		want, _ := strconv.ParseBool(n.value)
+		got := reachable()
+		if n.op == "!=" {
+			return got != want
+		}
+		return got == want
+	}
+	return matchString(n.stringField(e), n.op, n.value)
+}
+
+func (n exprCmp) stringField(e Entry) string {
+	switch n.field {
+	case "domain":
+		return e.Domain
+	case "type":
+		return entryType(e)
+	case "mapping":
+		return e.Mapping
+	case "target":
+		return e.LinkTarget
+	case "owner":
+		if e.Meta != nil {
+			return e.Meta.Owner
+		}
+		return ""
+	case "host":
+		if m, err := ParseMapping(e.Mapping); err == nil && !e.IsSymlink {
+			return m.Host
+		}
+	}
+	return ""
+}
+
+func compareInts(a int, op string, b int) bool {
+	switch op {
+	case "==":
+		return a == b
+	case "!=":
+		return a != b
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	}
+	return false
+}
+
+func matchString(s, op, v string) bool {
+	switch op {
+	case "==":
+		return s == v
+	case "!=":
+		return s != v
+	case "=~":
+		m, _ := path.Match(v, s)
+		return m
+	}
+	return false
+}
+
+type exprParser struct {
+	toks []string
+	pos  int
+}
+
+func parseExpr(s string) (exprNode, error) {
+	toks, err := tokenizeExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("expression: unexpected %q", p.toks[p.pos])
+	}
+	return n, nil
+}
+
+func (p *exprParser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *exprParser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = exprOr{l, r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = exprAnd{l, r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	switch p.peek() {
+	case "!":
+		p.next()
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return exprNot{n}, nil
+	case "(":
+		p.next()
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("expression: missing )")
+		}
+		return n, nil
+	case "":
+		return nil, fmt.Errorf("expression: unexpected end")
+	}
+	field := strings.ToLower(p.next())
+	if !exprFields[field] {
+		return nil, fmt.Errorf("expression: unknown field %q", field)
+	}
+	op := p.next()
+	switch op {
+	case "==", "!=", "=~":
+	case ">=", "<=", ">", "<":
+		if field != "port" {
+			return nil, fmt.Errorf("expression: %s only supports ==, != and =~", field)
+		}
+	default:
+		return nil, fmt.Errorf("expression: expected operator after %s, got %q", field, op)
+	}
+	value := p.next()
+	if value == "" || isExprOperator(value) {
+		return nil, fmt.Errorf("expression: missing value for %s", field)
+	}
+	value = strings.Trim(value, `"'`)
+	switch field {
+	case "port":
+		if _, err := strconv.Atoi(value); err != nil || op == "=~" {
+			return nil, fmt.Errorf("expression: port needs a number and a comparison operator")
+		}
+	case "reachable":
+		if _, err := strconv.ParseBool(value); err != nil || op == "=~" {
+			return nil, fmt.Errorf("expression: reachable needs true or false")
+		}
+	case "type":
+		if value != "file" && value != "symlink" && op != "=~" {
+			return nil, fmt.Errorf("expression: type must be file or symlink")
+		}
+	}
+	return exprCmp{field: field, op: op, value: value}, nil
+}
+
+func isExprOperator(t string) bool {
+	switch t {
+	case "&&", "||", "!", "(", ")", "==", "!=", ">=", "<=", ">", "<", "=~":
+		return true
+	}
+	return false
+}
+
+func tokenizeExpr(s string) ([]string, error) {
+	var toks []string
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(s[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("expression: unterminated string")
+			}
+			toks = append(toks, s[i:i+end+2])
+			i += end + 2
+		case strings.HasPrefix(s[i:], "&&"), strings.HasPrefix(s[i:], "||"),
+			strings.HasPrefix(s[i:], "=="), strings.HasPrefix(s[i:], "!="),
+			strings.HasPrefix(s[i:], ">="), strings.HasPrefix(s[i:], "<="),
+			strings.HasPrefix(s[i:], "=~"):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		case strings.IndexByte("!()<>", c) >= 0:
+			toks = append(toks, string(c))
+			i++
+		case c == '=' || c == '&' || c == '|':
+			return nil, fmt.Errorf("expression: unexpected %q at %d", c, i)
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\"'!()<>=&|", s[j]) < 0 {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
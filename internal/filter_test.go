@@ -0,0 +1,91 @@
+package internal
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	entries := []Entry{
+		{Domain: "api-billing", Mapping: "36500", Meta: &Meta{Tags: []string{"payments"}, Owner: "pay"}},
+		{Domain: "api-search", Mapping: "127.0.0.2:36010"},
+		{Domain: "web", Mapping: "36020"},
+		{Domain: "docs", IsSymlink: true, LinkTarget: "/src/docs"},
+		{Domain: "broken", Mapping: "nope"},
+	}
+	cases := []struct {
+		name string
+		opts FilterOptions
+		want []string
+	}{
+		{"none", FilterOptions{}, []string{"api-billing", "api-search", "web", "docs", "broken"}},
+		{"match", FilterOptions{Match: []string{"api-*"}}, []string{"api-billing", "api-search"}},
+		{"type", FilterOptions{Type: "symlink"}, []string{"docs"}},
+		{"host", FilterOptions{Host: "127.0.0.2"}, []string{"api-search"}},
+		{"port range", FilterOptions{PortRange: "36000-36100"}, []string{"api-search", "web"}},
+		{"tag", FilterOptions{Tags: []string{"Payments"}}, []string{"api-billing"}},
+		{"expr", FilterOptions{Expr: "port>=36500 && type==file"}, []string{"api-billing"}},
+		{"expr or", FilterOptions{Expr: "domain=~'api-*' && (tag==payments || host==127.0.0.2)"}, []string{"api-billing", "api-search"}},
+		{"expr not", FilterOptions{Expr: "!(type==symlink) && port<36500"}, []string{"api-search", "web"}},
+		{"expr owner", FilterOptions{Expr: `owner=="pay"`}, []string{"api-billing"}},
+		{"combined", FilterOptions{Match: []string{"api-*"}, Expr: "port!=36500"}, []string{"api-search"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			f, err := NewFilter(tc.opts)
+			if err != nil {
+				t.Fatalf("NewFilter: %v", err)
+			}
+			var got []string
+			for _, e := range f.Apply(entries) {
+				got = append(got, e.Domain)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Fatalf("got %v, want %v", got, tc.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFilter_Reachability(t *testing.T) {
+	results := []ValidationResult{
+		{Entry: Entry{Domain: "up", Mapping: "36000"}, Reachable: true},
+		{Entry: Entry{Domain: "down", Mapping: "36010"}, Reachable: false},
+	}
+	no := false
+	f, err := NewFilter(FilterOptions{Reachable: &no})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.NeedsReachability() {
+		t.Fatalf("expected filter to need reachability")
+	}
+	got := f.ApplyResults(results)
+	if len(got) != 1 || got[0].Domain != "down" {
+		t.Fatalf("unexpected results: %#v", got)
+	}
+	f, _ = NewFilter(FilterOptions{Expr: "reachable==true"})
+	if got := f.ApplyResults(results); len(got) != 1 || got[0].Domain != "up" {
+		t.Fatalf("unexpected results: %#v", got)
+	}
+}
+
+func TestFilter_Errors(t *testing.T) {
+	bad := []FilterOptions{
+		{Type: "dir"},
+		{PortRange: "37000-36000"},
+		{Expr: "port>="},
+		{Expr: "domain>api"},
+		{Expr: "color==red"},
+		{Expr: "(port==1"},
+		{Expr: "port==1 &&"},
+		{Expr: "type=file"},
+	}
+	for _, o := range bad {
+		if _, err := NewFilter(o); err == nil {
+			t.Errorf("expected error for %#v", o)
+		}
+	}
+}
@@ -1,8 +1,7 @@
 package internal
 
 import (
-	"encoding/json"
-	"os"
+	"io"
 	"sort"
 	"strings"
 
@@ -14,17 +13,26 @@ type ListGroup struct {
 	Mapping string   `json:"mapping"` // "(symlink)" or concrete mapping
 	Domains []string `json:"domains"`
 	Note    string   `json:"note,omitempty"`
+	// Meta holds sidecar metadata for the domains in this group that have any.
+	Meta map[string]*Meta `json:"meta,omitempty"`
 }
 
 func GroupByMapping(entries []Entry) []ListGroup {
 	buckets := map[string][]string{}
+	metas := map[string]map[string]*Meta{}
 	symlinkKey := "(symlink)"
 	for _, e := range entries {
 		key := e.Mapping
 		if e.IsSymlink {
 			key = symlinkKey
 		}
-		buckets[key] = append(buckets[key], e.Domain)
+		buckets[key] = append(buckets[key], e.QualifiedDomain())
+		if e.Meta != nil {
+			if metas[key] == nil {
+				metas[key] = map[string]*Meta{}
+			}
+			metas[key][e.QualifiedDomain()] = e.Meta
+		}
 	}
 	groups := make([]ListGroup, 0, len(buckets))
 	for k, v := range buckets {
@@ -33,15 +41,15 @@ func GroupByMapping(entries []Entry) []ListGroup {
 		if k != symlinkKey && len(v) > 1 {
 			note = "duplicate mapping"
 		}
-		groups = append(groups, ListGroup{Mapping: k, Domains: v, Note: note})
+		groups = append(groups, ListGroup{Mapping: k, Domains: v, Note: note, Meta: metas[k]})
 	}
 	sort.Slice(groups, func(i, j int) bool { return groups[i].Mapping < groups[j].Mapping })
 	return groups
 }
 
-func PrintListFancy(entries []Entry) {
+func PrintListFancy(w io.Writer, entries []Entry) {
 	tw := table.NewWriter()
-	tw.SetOutputMirror(os.Stdout)
+	tw.SetOutputMirror(w)
 	tw.AppendHeader(table.Row{"Mapping", "Domains", "Note"})
 	groups := GroupByMapping(entries)
 	for _, g := range groups {
@@ -63,9 +71,16 @@ func PrintListFancy(entries []Entry) {
 	tw.Render()
 }
 
-func PrintListJSON(entries []Entry) error {
-	groups := GroupByMapping(entries)
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(groups)
+// ListView renders list output: json and yaml get the grouped view, record formats get one row per entry.
+func ListView(entries []Entry) View[Entry] {
+	if entries == nil {
+		entries = []Entry{}
+	}
+	return View[Entry]{
+		Kind:    KindListGroupList,
+		Doc:     GroupByMapping(entries),
+		Items:   entries,
+		Columns: EntryColumns(),
+		Name:    EntryName,
+	}
 }
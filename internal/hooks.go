@@ -0,0 +1,179 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Hook events: the mutations hooks run around.
+const (
+	EventCreate  = "create"
+	EventUpdate  = "update"
+	EventDelete  = "delete"
+	EventCleanup = "cleanup"
+	EventRename  = "rename"
+)
+
+// Hook phases. A failing pre hook aborts the mutation; a failing post hook is only reported.
+const (
+	HookPre  = "pre"
+	HookPost = "post"
+)
+
+// DefaultHookTimeout is how long a hook may run, in seconds, unless hook_timeout says otherwise.
+const DefaultHookTimeout = 10
+
+// HookKeys returns the keys accepted under "hooks": pre_create, post_create, ... post_rename.
+func HookKeys() []string {
+	var keys []string
+	for _, event := range []string{EventCreate, EventUpdate, EventDelete, EventCleanup, EventRename} {
+		keys = append(keys, HookPre+"_"+event, HookPost+"_"+event)
+	}
+	return keys
+}
+
+// HookChange is one entry change in a hook's payload, with the entry's metadata (e.g. its project dir).
+type HookChange struct {
+	EntryChange
+	Meta *Meta `json:"meta,omitempty"`
+}
+
+// HookPayload is the JSON document a hook reads on stdin.
+type HookPayload struct {
+	Event   string       `json:"event"` // create, update, delete, cleanup or rename
+	Phase   string       `json:"phase"` // pre or post
+	Dir     string       `json:"dir"`
+	TLD     string       `json:"tld"`
+	Changes []HookChange `json:"changes"`
+}
+
+// NewHookChanges attaches the metadata recorded in dir to changes (for a rename, the old name's). Call it
+// before the mutation, since deleting an entry drops its metadata.
+func NewHookChanges(dir string, changes ...EntryChange) []HookChange {
+	store, err := LoadMeta(dir)
+	out := make([]HookChange, len(changes))
+	for i, c := range changes {
+		out[i].EntryChange = c
+		if err != nil {
+			continue
+		}
+		if m, ok := store.Get(c.Domain); ok {
+			out[i].Meta = &m
+		} else if m, ok := store.Get(c.PreviousDomain); ok && c.PreviousDomain != "" {
+			out[i].Meta = &m
+		}
+	}
+	return out
+}
+
+// NewEntryChange describes a change of e for hooks and mutation results.
+func NewEntryChange(e Entry, status string) EntryChange {
+	c := EntryChange{Domain: e.Domain, Status: status, Type: "file", Mapping: e.Mapping}
+	if e.IsSymlink {
+		c.Type, c.Mapping, c.LinkTarget = "symlink", "", e.LinkTarget
+	}
+	return c
+}
+
+// Hooks runs the commands configured under "hooks" in the config around entry mutations. Each command is an
+// executable (absolute, ~/..., or looked up on PATH) followed by arguments, split on spaces; no shell is
+// involved. It gets the payload as JSON on stdin and PUMADEVCTL_HOOK (e.g. pre_create) and PUMADEVCTL_DIR
+// in its environment.
+type Hooks struct {
+	Commands map[string][]string // by hook key, e.g. "post_create"
+	Timeout  time.Duration       // per command
+	Output   io.Writer           // receives the commands' stdout and stderr
+}
+
+// Run runs the hooks for p.Phase and p.Event in order. Pre hooks stop at the first failure (a non-zero
+// exit, a timeout or a missing executable) and return it with CodeHookFailed; post hooks all run and their
+// failures are joined.
+func (h *Hooks) Run(p HookPayload) error {
+	key := p.Phase + "_" + p.Event
+	commands := h.Commands[key]
+	if len(commands) == 0 {
+		return nil
+	}
+	if p.Changes == nil {
+		p.Changes = []HookChange{}
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, command := range commands {
+		if err := h.run(key, command, p.Dir, payload); err != nil {
+			if p.Phase == HookPre {
+				return Errorf(CodeHookFailed, "%v; %s aborted", err, p.Event)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Hooks) run(key, command, dir string, payload []byte) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return nil
+	}
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	c := exec.CommandContext(ctx, expandHome(fields[0]), fields[1:]...)
+	c.Stdin = bytes.NewReader(payload)
+	c.Stdout, c.Stderr = h.Output, h.Output
+	c.Env = ExecEnv(os.Environ(), map[string]string{"PUMADEVCTL_HOOK": key, "PUMADEVCTL_DIR": dir})
+	c.WaitDelay = time.Second // don't wait for grandchildren holding the output pipe after a timeout
+	err := c.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return nil
+	case ctx.Err() != nil:
+		return Errorf(CodeHookFailed, "%s hook %s timed out after %s", key, fields[0], timeout)
+	case errors.As(err, &exitErr):
+		return Errorf(CodeHookFailed, "%s hook %s exited with status %d", key, fields[0], exitErr.ExitCode())
+	}
+	return Errorf(CodeHookFailed, "%s hook %s: %v", key, fields[0], err)
+}
+
+// validateHooks reports unknown hook keys and empty commands.
+func validateHooks(hooks map[string][]string) []ConfigIssue {
+	known := map[string]bool{}
+	for _, k := range HookKeys() {
+		known[k] = true
+	}
+	keys := make([]string, 0, len(hooks))
+	for k := range hooks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var issues []ConfigIssue
+	for _, k := range keys {
+		if !known[k] {
+			issues = append(issues, ConfigIssue{Key: "hooks." + k, Severity: SeverityError,
+				Message: fmt.Sprintf("unknown hook (known: %s)", strings.Join(HookKeys(), ", "))})
+			continue
+		}
+		for _, command := range hooks[k] {
+			if strings.TrimSpace(command) == "" {
+				issues = append(issues, ConfigIssue{Key: "hooks." + k, Severity: SeverityError, Message: "empty command"})
+			}
+		}
+	}
+	return issues
+}
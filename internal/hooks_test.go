@@ -0,0 +1,103 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeHook writes an executable shell script to dir and returns its path.
+func writeHook(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestHooks_Run(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	record := writeHook(t, dir, "record", `cat > "$1/$PUMADEVCTL_HOOK.json"; echo "ran $PUMADEVCTL_HOOK in $PUMADEVCTL_DIR"`)
+	fail := writeHook(t, dir, "fail", `echo "no way" >&2; exit 3`)
+	slow := writeHook(t, dir, "slow", `exec sleep 5`)
+
+	var out bytes.Buffer
+	h := &Hooks{
+		Commands: map[string][]string{
+			"pre_create":  {record + " " + dir},
+			"post_create": {fail, record + " " + dir},
+			"pre_delete":  {fail, record + " " + dir},
+			"pre_update":  {slow},
+		},
+		Timeout: 200 * time.Millisecond,
+		Output:  &out,
+	}
+	p := HookPayload{Event: EventCreate, Phase: HookPre, Dir: "/srv/puma-dev", TLD: "test",
+		Changes: []HookChange{{EntryChange: EntryChange{Domain: "api", Status: "created", Type: "file", Mapping: "36000"}}}}
+	if err := h.Run(p); err != nil {
+		t.Fatal(err)
+	}
+	var got HookPayload
+	b, err := os.ReadFile(filepath.Join(dir, "pre_create.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != EventCreate || got.Phase != HookPre || len(got.Changes) != 1 || got.Changes[0].Mapping != "36000" {
+		t.Errorf("unexpected payload %s", b)
+	}
+	if !strings.Contains(out.String(), "ran pre_create in /srv/puma-dev") {
+		t.Errorf("hook output not forwarded: %q", out.String())
+	}
+
+	// post hooks all run; failures are reported together
+	p.Phase = HookPost
+	if err := h.Run(p); CodeOf(err) != CodeHookFailed || !strings.Contains(err.Error(), "exited with status 3") {
+		t.Errorf("expected the failing post hook to be reported, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "post_create.json")); err != nil {
+		t.Errorf("the post hook after the failing one should still run: %v", err)
+	}
+
+	// pre hooks stop at the first failure
+	p.Event, p.Phase = EventDelete, HookPre
+	if err := h.Run(p); CodeOf(err) != CodeHookFailed || !strings.Contains(err.Error(), "delete aborted") {
+		t.Errorf("expected the pre hook to abort, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pre_delete.json")); err == nil {
+		t.Errorf("hooks after a failing pre hook must not run")
+	}
+
+	p.Event = EventUpdate
+	start := time.Now()
+	if err := h.Run(p); CodeOf(err) != CodeHookFailed || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("timeout not enforced: took %s", time.Since(start))
+	}
+
+	h.Commands = map[string][]string{"pre_cleanup": {filepath.Join(dir, "missing")}}
+	p.Event = EventCleanup
+	if err := h.Run(p); CodeOf(err) != CodeHookFailed {
+		t.Errorf("expected a missing hook executable to fail, got %v", err)
+	}
+}
+
+func TestValidateHooks(t *testing.T) {
+	issues := validateHooks(map[string][]string{"post_create": {"touch x"}, "after_create": {"x"}, "pre_delete": {" "}})
+	if len(issues) != 2 || issues[0].Key != "hooks.after_create" || issues[1].Key != "hooks.pre_delete" {
+		t.Errorf("unexpected issues %+v", issues)
+	}
+}
@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Lease records that a domain of a mappings dir owns a port block, whether or not its entry file exists.
+// Leases survive delete so a re-created domain gets its old block back and no one else is handed ports
+// that an app's config may still reference.
+type Lease struct {
+	Domain    string    `json:"domain"`
+	Dir       string    `json:"dir"`
+	Base      int       `json:"base"`
+	Size      int       `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// End returns the last port of the leased block.
+func (l Lease) End() int { return l.Base + l.Size - 1 }
+
+// Ledger is the persistent set of port leases, shared by all mappings dirs since ports are machine-wide.
+type Ledger struct {
+	path    string
+	Version int     `json:"version"`
+	Leases  []Lease `json:"leases"`
+}
+
+// LedgerPath returns the location of the port ledger under XDG state.
+func LedgerPath() string { return filepath.Join(XDGStateDir(), "ports.json") }
+
+// LoadLedger reads the ledger at path. A missing file yields an empty ledger.
+func LoadLedger(path string) (*Ledger, error) {
+	l := &Ledger{path: path, Version: 1}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return l, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, Errorf(CodeConfig, "parse %s: %w", path, err)
+	}
+	return l, nil
+}
+
+// Lookup returns the lease of domain in dir, if any.
+func (l *Ledger) Lookup(dir, domain string) (Lease, bool) {
+	if i := l.index(dir, domain); i >= 0 {
+		return l.Leases[i], true
+	}
+	return Lease{}, false
+}
+
+func (l *Ledger) index(dir, domain string) int {
+	for i, ls := range l.Leases {
+		if ls.Dir == dir && ls.Domain == domain {
+			return i
+		}
+	}
+	return -1
+}
+
+// Reserve records or refreshes the lease of domain in dir. CreatedAt is kept while the block stays the same.
+func (l *Ledger) Reserve(dir, domain string, base, size int, now time.Time) Lease {
+	now = now.UTC()
+	lease := Lease{Domain: domain, Dir: dir, Base: base, Size: size, CreatedAt: now, UpdatedAt: now}
+	if i := l.index(dir, domain); i >= 0 {
+		if old := l.Leases[i]; old.Base == base && old.Size == size {
+			lease.CreatedAt = old.CreatedAt
+		}
+		l.Leases[i] = lease
+	} else {
+		l.Leases = append(l.Leases, lease)
+	}
+	l.sort()
+	return lease
+}
+
+// Put stores lease as it is, replacing the lease of its domain; it undoes a Reserve.
+func (l *Ledger) Put(lease Lease) {
+	if i := l.index(lease.Dir, lease.Domain); i >= 0 {
+		l.Leases[i] = lease
+	} else {
+		l.Leases = append(l.Leases, lease)
+	}
+	l.sort()
+}
+
+// Release drops the lease of domain in dir and returns it.
+func (l *Ledger) Release(dir, domain string) (Lease, bool) {
+	i := l.index(dir, domain)
+	if i < 0 {
+		return Lease{}, false
+	}
+	lease := l.Leases[i]
+	l.Leases = append(l.Leases[:i], l.Leases[i+1:]...)
+	return lease, true
+}
+
+// Rename moves the lease of oldDomain to newDomain, replacing any lease newDomain had.
+func (l *Ledger) Rename(dir, oldDomain, newDomain string) bool {
+	i := l.index(dir, oldDomain)
+	if i < 0 {
+		return false
+	}
+	l.Release(dir, newDomain)
+	i = l.index(dir, oldDomain)
+	l.Leases[i].Domain = newDomain
+	l.sort()
+	return true
+}
+
+// GC removes leases whose entry is gone (per exists) and which were not refreshed since cutoff.
+// A zero cutoff removes every orphaned lease. It returns the removed leases.
+func (l *Ledger) GC(cutoff time.Time, exists func(Lease) bool) []Lease {
+	removed := []Lease{}
+	kept := l.Leases[:0]
+	for _, ls := range l.Leases {
+		if !exists(ls) && (cutoff.IsZero() || ls.UpdatedAt.Before(cutoff)) {
+			removed = append(removed, ls)
+			continue
+		}
+		kept = append(kept, ls)
+	}
+	l.Leases = kept
+	return removed
+}
+
+// Reservations returns the leased blocks as reservations, skipping domain in dir (the one being allocated).
+func (l *Ledger) Reservations(dir, domain string) []Reservation {
+	var out []Reservation
+	for _, ls := range l.Leases {
+		if ls.Dir == dir && ls.Domain == domain {
+			continue
+		}
+		out = append(out, Reservation{Domain: ls.Domain, Start: ls.Base, End: ls.End(), Source: "lease"})
+	}
+	return out
+}
+
+// save writes the ledger atomically, creating the state directory as needed.
+func (l *Ledger) save() error {
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	if l.Leases == nil {
+		l.Leases = []Lease{}
+	}
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(l.path, append(b, '\n'), 0644)
+}
+
+func (l *Ledger) sort() {
+	sort.Slice(l.Leases, func(i, j int) bool { return l.Leases[i].Base < l.Leases[j].Base })
+}
+
+// UpdateLedger loads the ledger at path, applies fn and saves it when fn reports a change, holding the
+// ledger's lock throughout so concurrent updates from other mappings dirs or contexts are not lost. Every
+// write to the ledger goes through it.
+func UpdateLedger(path string, fn func(l *Ledger) bool) error {
+	release, err := lockLedger(path)
+	if err != nil {
+		return err
+	}
+	defer release()
+	l, err := LoadLedger(path)
+	if err != nil {
+		return err
+	}
+	if !fn(l) {
+		return nil
+	}
+	return l.save()
+}
+
+// Allocator hands out port blocks within [Min, Max], avoiding existing entries, ledger leases and,
+// with a Prober, ports something is already listening on.
+type Allocator struct {
+	Min, Max, Block int
+	Dir             string     // mappings dir the allocation is for
+	Ledger          *Ledger    // optional
+	Prober          PortProber // optional; nil skips bind-probing
+	// Reserved holds blocks taken outside Dir, e.g. by entries of other contexts sharing the host's ports.
+	Reserved []Reservation
+	// Skipped collects the candidate blocks Allocate rejected and why, for --verbose.
+	Skipped []SkippedBlock
+}
+
+// SkippedBlock is a candidate block rejected during allocation.
+type SkippedBlock struct {
+	Base   int
+	End    int
+	Reason string
+}
+
+// Allocate returns the block for domain: the block it already leases when no other domain holds it
+// (it is not probed, since the domain's own app may still be running there), otherwise the first
+// block-aligned one that overlaps no reservation and whose ports all probe free.
+func (a *Allocator) Allocate(domain string, entries []Entry) (int, error) {
+	if err := checkBlockParams(a.Min, a.Max, a.Block); err != nil {
+		return 0, err
+	}
+	a.Skipped = nil
+	reserved := a.reserved(domain, entries)
+	if a.Ledger != nil {
+		if ls, ok := a.Ledger.Lookup(a.Dir, domain); ok && ls.Size >= a.Block {
+			r := overlapping(reserved, ls.Base, ls.Base+a.Block-1)
+			if r == nil {
+				return ls.Base, nil
+			}
+			a.skip(ls.Base, fmt.Sprintf("own lease now overlaps %s's %s %d-%d", r.Domain, r.Source, r.Start, r.End))
+		}
+	}
+	sort.Slice(reserved, func(i, j int) bool { return reserved[i].Start < reserved[j].Start })
+	for base := a.Min; base+a.Block-1 <= a.Max; base += a.Block {
+		end := base + a.Block - 1
+		if r := overlapping(reserved, base, end); r != nil {
+			a.skip(base, fmt.Sprintf("overlaps %s's %s %d-%d", r.Domain, r.Source, r.Start, r.End))
+			continue
+		}
+		if err := a.probe(base, end); err != nil {
+			a.skip(base, err.Error())
+			continue
+		}
+		return base, nil
+	}
+	return 0, Errorf(CodeNoFreePort, "no available port block in %d-%d with block size %d", a.Min, a.Max, a.Block)
+}
+
+func (a *Allocator) probe(start, end int) error {
+	if a.Prober == nil {
+		return nil
+	}
+	for p := start; p <= end; p++ {
+		if err := a.Prober.Probe(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Allocator) skip(base int, reason string) {
+	a.Skipped = append(a.Skipped, SkippedBlock{Base: base, End: base + a.Block - 1, Reason: reason})
+}
+
+// Check fails with CodeAlreadyExists when the block at base overlaps another domain's entry or lease.
+func (a *Allocator) Check(domain string, base int, entries []Entry) error {
+	if r := overlapping(a.reserved(domain, entries), base, base+a.Block-1); r != nil {
+		return Errorf(CodeAlreadyExists, "ports %d-%d overlap %s's %s %d-%d", base, base+a.Block-1, r.Domain, r.Source, r.Start, r.End)
+	}
+	return nil
+}
+
+// foreignLeases returns the leases of domains that have no entry among entries (or belong to another dir).
+func (a *Allocator) foreignLeases(entries []Entry) []Reservation {
+	if a.Ledger == nil {
+		return nil
+	}
+	present := map[string]bool{}
+	for _, e := range entries {
+		present[e.Domain] = true
+	}
+	var out []Reservation
+	for _, ls := range a.Ledger.Leases {
+		if ls.Dir != a.Dir || !present[ls.Domain] {
+			out = append(out, Reservation{Domain: ls.Domain, Start: ls.Base, End: ls.End(), Source: "lease"})
+		}
+	}
+	return out
+}
+
+// reserved collects the reservations of every domain but the one being allocated.
+func (a *Allocator) reserved(domain string, entries []Entry) []Reservation {
+	var others []Entry
+	for _, e := range entries {
+		if e.Domain != domain {
+			others = append(others, e)
+		}
+	}
+	reserved := EntryReservations(others, a.Block)
+	if a.Ledger != nil {
+		reserved = append(reserved, a.Ledger.Reservations(a.Dir, domain)...)
+	}
+	return append(reserved, a.Reserved...)
+}
+
+// String describes a lease for human output.
+func (l Lease) String() string {
+	return fmt.Sprintf("%s → %d-%d", l.Domain, l.Base, l.End())
+}
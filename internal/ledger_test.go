@@ -0,0 +1,98 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAllocator_LeasesSurviveDelete(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(t.TempDir(), "ports.json")
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	l, err := LoadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &Allocator{Min: 36000, Max: 36099, Block: 10, Dir: dir, Ledger: l}
+	l.Reserve(dir, "old", 36000, 10, now)
+	l.Reserve(dir, "app", 36010, 10, now)
+	if err := l.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Neither entry file exists, but both blocks stay taken for other domains.
+	if base, err := a.Allocate("new", nil); err != nil || base != 36020 {
+		t.Fatalf("expected 36020 for a new domain, got %d (%v)", base, err)
+	}
+	// A re-created domain gets its old block back.
+	if base, err := a.Allocate("app", nil); err != nil || base != 36010 {
+		t.Fatalf("expected app to get 36010 back, got %d (%v)", base, err)
+	}
+	// Unless someone else's entry took it meanwhile.
+	if base, _ := a.Allocate("app", []Entry{{Domain: "squatter", Mapping: "36015"}}); base != 36030 {
+		t.Fatalf("expected 36030 when the old block is taken, got %d", base)
+	}
+	if err := a.Check("new", 36005, nil); CodeOf(err) != CodeAlreadyExists {
+		t.Fatalf("expected overlap error, got %v", err)
+	}
+
+	l, _ = LoadLedger(path)
+	if ls, ok := l.Lookup(dir, "app"); !ok || ls.Base != 36010 || !ls.CreatedAt.Equal(now) {
+		t.Fatalf("lease not persisted: %#v", ls)
+	}
+	l.Reserve(dir, "app", 36010, 10, now.Add(time.Hour))
+	if ls, _ := l.Lookup(dir, "app"); !ls.CreatedAt.Equal(now) || !ls.UpdatedAt.Equal(now.Add(time.Hour)) {
+		t.Fatalf("refresh must keep created_at: %#v", ls)
+	}
+	prev, _ := l.Lookup(dir, "app")
+	l.Reserve(dir, "app", 36020, 10, now.Add(2*time.Hour))
+	l.Put(prev)
+	if ls, _ := l.Lookup(dir, "app"); ls != prev {
+		t.Fatalf("Put must restore the lease as it was: %#v", ls)
+	}
+
+	exists := func(ls Lease) bool { return ls.Domain == "old" }
+	if removed := l.GC(now, exists); len(removed) != 0 {
+		t.Fatalf("recently refreshed lease must survive gc, removed %v", removed)
+	}
+	if removed := l.GC(time.Time{}, exists); len(removed) != 1 || removed[0].Domain != "app" {
+		t.Fatalf("expected app's orphaned lease to be collected, removed %v", removed)
+	}
+}
+
+func TestUpdateLedger_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ports.json")
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// a different mappings dir each, as with several contexts
+			dir := fmt.Sprintf("/dirs/%d", i)
+			errs <- UpdateLedger(path, func(l *Ledger) bool {
+				l.Reserve(dir, "app", 36000+10*i, 10, time.Now())
+				return true
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	l, err := LoadLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.Leases) != n {
+		t.Fatalf("expected %d leases, got %d: concurrent updates were lost", n, len(l.Leases))
+	}
+}
@@ -0,0 +1,233 @@
+package internal
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lint rules.
+const (
+	RuleDuplicateTarget = "duplicate-target" // several domains point at the same host:port
+	RuleBlockOverlap    = "block-overlap"    // port blocks of different targets overlap
+	RuleOutOfRange      = "out-of-range"     // port outside port_min..port_max
+	RulePrivilegedPort  = "privileged-port"  // port below 1024 needs root to bind
+	RuleUnparsable      = "unparsable"       // file content is not a PORT or HOST:PORT mapping
+	RuleInvalidDomain   = "invalid-domain"   // file name is not a valid puma-dev app name
+	RuleNonCanonical    = "non-canonical"    // mapping spells out the default host instead of a bare port
+)
+
+// Lint severities.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// LintIssue is one problem found by LintEntries. Fix describes a safe automatic rewrite, if there is one.
+type LintIssue struct {
+	Rule     string   `json:"rule"`
+	Severity string   `json:"severity"`
+	Domains  []string `json:"domains"`
+	Message  string   `json:"message"`
+	Fix      string   `json:"fix,omitempty"`
+	Fixed    bool     `json:"fixed,omitempty"`
+
+	fix    func(dir string) error
+	event  string      // hook event of the fix: update or rename
+	change EntryChange // the fix as an entry change, for hooks
+}
+
+// LintReport is the machine-readable result of lint.
+type LintReport struct {
+	Errors   int         `json:"errors"`
+	Warnings int         `json:"warnings"`
+	Fixed    int         `json:"fixed"`
+	Issues   []LintIssue `json:"issues"`
+}
+
+// LintOptions are the allocation settings and TLD (DefaultTLD when empty) entries are checked against.
+type LintOptions struct {
+	Min, Max, Block int
+	TLD             string
+}
+
+// NormalizeTarget returns the canonical host:port a mapping points at: hosts are lowercased and
+// "localhost" is treated as 127.0.0.1, so "36010", "127.0.0.1:36010" and "localhost:36010" compare equal.
+func NormalizeTarget(m *Mapping) string {
+	host := strings.ToLower(m.Host)
+	if host == "localhost" {
+		host = "127.0.0.1"
+	}
+	return net.JoinHostPort(host, strconv.Itoa(m.Port))
+}
+
+// CanonicalMapping is the preferred spelling of m: a bare port for 127.0.0.1, HOST:PORT otherwise.
+func CanonicalMapping(m *Mapping) string {
+	if m.Host == "127.0.0.1" {
+		return strconv.Itoa(m.Port)
+	}
+	return net.JoinHostPort(strings.ToLower(m.Host), strconv.Itoa(m.Port))
+}
+
+// LintEntries checks entries for semantic duplicates, overlapping blocks, out-of-range and privileged ports,
+// unparsable mappings and invalid domain names. Issues are sorted by severity, then rule and domain.
+func LintEntries(entries []Entry, opts LintOptions) LintReport {
+	tld := opts.TLD
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	var issues []LintIssue
+	add := func(is LintIssue) { issues = append(issues, is) }
+
+	type target struct {
+		key     string
+		port    int
+		domains []string
+	}
+	targets := map[string]*target{}
+	existing := map[string]bool{}
+	for _, e := range entries {
+		existing[e.Domain] = true
+	}
+	for _, e := range entries {
+		if is, ok := lintDomainName(e.Domain, tld, existing); ok {
+			add(is)
+		}
+		if e.IsSymlink {
+			continue
+		}
+		m, err := ParseMapping(e.Mapping)
+		if err != nil {
+			add(LintIssue{Rule: RuleUnparsable, Severity: SeverityError, Domains: []string{e.Domain},
+				Message: fmt.Sprintf("%s: %v (content %q)", e.Domain, err, e.Mapping)})
+			continue
+		}
+		// localhost is left alone: it may resolve to ::1 first, which is not the same listener.
+		if c := CanonicalMapping(m); c != e.Mapping && m.Host == "127.0.0.1" {
+			domain := e.Domain
+			add(LintIssue{Rule: RuleNonCanonical, Severity: SeverityWarning, Domains: []string{domain},
+				Message: fmt.Sprintf("%s: %q is spelled differently from the canonical %q", domain, e.Mapping, c),
+				Fix:     fmt.Sprintf("rewrite %s to %q", domain, c),
+				fix:     func(dir string) error { return UpdateEntry(dir, domain, c) },
+				event:   EventUpdate, change: EntryChange{Domain: domain, Status: "updated", Type: "file", Mapping: c}})
+		}
+		key := NormalizeTarget(m)
+		t := targets[key]
+		if t == nil {
+			t = &target{key: key, port: m.Port}
+			targets[key] = t
+		}
+		t.domains = append(t.domains, e.Domain)
+		switch {
+		case m.Port < 1024:
+			add(LintIssue{Rule: RulePrivilegedPort, Severity: SeverityWarning, Domains: []string{e.Domain},
+				Message: fmt.Sprintf("%s: port %d is privileged; apps need root to bind it", e.Domain, m.Port)})
+		case opts.Max > 0 && (m.Port < opts.Min || m.Port > opts.Max):
+			add(LintIssue{Rule: RuleOutOfRange, Severity: SeverityWarning, Domains: []string{e.Domain},
+				Message: fmt.Sprintf("%s: port %d is outside the allocation range %d-%d", e.Domain, m.Port, opts.Min, opts.Max)})
+		}
+	}
+
+	list := make([]*target, 0, len(targets))
+	for _, t := range targets {
+		sort.Strings(t.domains)
+		list = append(list, t)
+		if len(t.domains) > 1 {
+			add(LintIssue{Rule: RuleDuplicateTarget, Severity: SeverityWarning, Domains: t.domains,
+				Message: fmt.Sprintf("%s all point at %s", strings.Join(t.domains, ", "), t.key)})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].port < list[j].port || list[i].port == list[j].port && list[i].key < list[j].key
+	})
+	if opts.Block > 1 {
+		for i, a := range list {
+			for _, b := range list[i+1:] {
+				if b.port-a.port >= opts.Block {
+					break
+				}
+				if b.port == a.port {
+					continue // same port on different hosts
+				}
+				add(LintIssue{Rule: RuleBlockOverlap, Severity: SeverityError, Domains: append(append([]string{}, a.domains...), b.domains...),
+					Message: fmt.Sprintf("blocks %d-%d (%s) and %d-%d (%s) overlap; run `ports compact`",
+						a.port, a.port+opts.Block-1, strings.Join(a.domains, ", "), b.port, b.port+opts.Block-1, strings.Join(b.domains, ", "))})
+			}
+		}
+	}
+
+	rep := LintReport{Issues: []LintIssue{}}
+	for _, is := range issues {
+		if is.Severity == SeverityError {
+			rep.Errors++
+		} else {
+			rep.Warnings++
+		}
+		rep.Issues = append(rep.Issues, is)
+	}
+	sort.SliceStable(rep.Issues, func(i, j int) bool {
+		a, b := rep.Issues[i], rep.Issues[j]
+		if a.Severity != b.Severity {
+			return a.Severity == SeverityError
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return strings.Join(a.Domains, ",") < strings.Join(b.Domains, ",")
+	})
+	return rep
+}
+
+// lintDomainName flags names puma-dev cannot serve (errors) and names ending in the TLD, which would be
+// served as <name>.<tld>.<tld> (warnings). Both can be fixed by renaming to the normalized name.
+func lintDomainName(name, tld string, existing map[string]bool) (LintIssue, bool) {
+	is := LintIssue{Rule: RuleInvalidDomain, Domains: []string{name}}
+	if err := ValidateDomain(name); err != nil {
+		is.Severity, is.Message = SeverityError, err.Error()
+	} else if strings.HasSuffix(name, "."+tld) {
+		is.Severity = SeverityWarning
+		is.Message = fmt.Sprintf("%q ends in .%s; puma-dev serves it as %s.%s", name, tld, name, tld)
+	} else {
+		return LintIssue{}, false
+	}
+	if fixed, err := NormalizeDomain(name, tld); err == nil && fixed != name && !existing[fixed] {
+		existing[fixed] = true // claim it so two names never get renamed onto the same one
+		is.Fix = fmt.Sprintf("rename %s to %s", name, fixed)
+		is.event, is.change = EventRename, EntryChange{Domain: fixed, Status: "renamed", PreviousDomain: name}
+		is.fix = func(dir string) error {
+			if err := RenameEntry(dir, name, fixed, false); err != nil {
+				return err
+			}
+			return UpdateLedger(LedgerPath(), func(l *Ledger) bool { return l.Rename(dir, name, fixed) })
+		}
+	}
+	return is, true
+}
+
+// FixLint applies the safe fixes in rep to dir and updates the counters. Mapping rewrites run before
+// renames, since both may target the same entry. Each fix runs through run, given its hook event (update or
+// rename) and change, so the caller can wrap it in hooks.
+func FixLint(dir string, rep *LintReport, run func(event string, change EntryChange, apply func() error) error) error {
+	for _, renames := range []bool{false, true} {
+		for i := range rep.Issues {
+			is := &rep.Issues[i]
+			if is.fix == nil || (is.Rule == RuleInvalidDomain) != renames {
+				continue
+			}
+			fix := func() error { return is.fix(dir) }
+			if err := run(is.event, is.change, fix); err != nil {
+				return fmt.Errorf("%s: %w", is.Fix, err)
+			}
+			is.Fixed = true
+			rep.Fixed++
+			if is.Severity == SeverityError {
+				rep.Errors--
+			} else {
+				rep.Warnings--
+			}
+		}
+	}
+	return nil
+}
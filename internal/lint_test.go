@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintEntries(t *testing.T) {
+	entries := []Entry{
+		{Domain: "api", Mapping: "36000"},
+		{Domain: "api-alias", Mapping: "localhost:36000"},
+		{Domain: "web", Mapping: "127.0.0.1:36005"},
+		{Domain: "old", Mapping: "8080"},
+		{Domain: "root", Mapping: "80"},
+		{Domain: "broken", Mapping: "not a port"},
+		{Domain: "Shop", Mapping: "36020"},
+		{Domain: "blog.test", Mapping: "36030"},
+		{Domain: "docs", IsSymlink: true, LinkTarget: "/src/docs"},
+	}
+	rep := LintEntries(entries, LintOptions{Min: 36000, Max: 36999, Block: 10})
+
+	got := map[string][]string{}
+	for _, is := range rep.Issues {
+		got[is.Rule] = append(got[is.Rule], is.Domains...)
+	}
+	want := map[string]int{
+		RuleDuplicateTarget: 2, // api, api-alias
+		RuleBlockOverlap:    3, // api, api-alias and web
+		RuleOutOfRange:      1, // old
+		RulePrivilegedPort:  1, // root
+		RuleUnparsable:      1, // broken
+		RuleInvalidDomain:   2, // Shop, blog.test
+		RuleNonCanonical:    1, // web
+	}
+	for rule, n := range want {
+		if len(got[rule]) != n {
+			t.Errorf("%s: got domains %v, want %d", rule, got[rule], n)
+		}
+	}
+	if rep.Errors != 3 || rep.Warnings != 5 {
+		t.Fatalf("expected 3 errors and 5 warnings, got %d/%d: %#v", rep.Errors, rep.Warnings, rep.Issues)
+	}
+	if rep.Issues[0].Severity != SeverityError {
+		t.Fatalf("errors must sort first: %#v", rep.Issues[0])
+	}
+}
+
+func TestFixLint(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	for name, content := range map[string]string{"web": "127.0.0.1:36010", "Shop": "127.0.0.1:36020", "shop2.test": "36030", "db": "localhost:36040"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := LoadEntries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := LintEntries(entries, LintOptions{Min: 36000, Max: 36999, Block: 10})
+	var events []string
+	err = FixLint(dir, &rep, func(event string, change EntryChange, apply func() error) error {
+		events = append(events, event+" "+change.Domain)
+		return apply()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(events, ","); got != "update Shop,update web,rename shop,rename shop2" {
+		t.Errorf("unexpected fix events %s", got)
+	}
+	if rep.Fixed != 4 || rep.Errors != 0 || rep.Warnings != 0 {
+		t.Fatalf("expected 4 fixes and a clean report, got %#v", rep)
+	}
+	entries, _ = LoadEntries(dir)
+	mappings := map[string]string{}
+	for _, e := range entries {
+		mappings[e.Domain] = e.Mapping
+	}
+	want := map[string]string{"web": "36010", "shop": "36020", "shop2": "36030", "db": "localhost:36040"}
+	if len(mappings) != len(want) {
+		t.Fatalf("unexpected entries after fix: %v", mappings)
+	}
+	for d, m := range want {
+		if mappings[d] != m {
+			t.Errorf("%s: got %q, want %q", d, mappings[d], m)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockDir takes an exclusive, non-blocking lock on the mappings dir so concurrent pumadevctl
+// processes cannot interleave mutations. It fails with CodeLocked when another process holds it.
+// The returned function releases the lock.
+func LockDir(dir string) (func(), error) {
+	release, err := lockPath(filepath.Join(dir, MetaDirName, "lock"), 0)
+	if err != nil && CodeOf(err) == CodeLocked {
+		return nil, Errorf(CodeLocked, "%s is locked by another pumadevctl process", dir)
+	}
+	return release, err
+}
+
+// ledgerLockWait bounds how long a ledger update waits for another process's, which only lasts a
+// read-modify-write.
+const ledgerLockWait = 5 * time.Second
+
+// lockLedger takes the exclusive lock next to the ledger at path, shared by every mappings dir and
+// context, waiting up to ledgerLockWait for another process to finish its update.
+func lockLedger(path string) (func(), error) {
+	release, err := lockPath(path+".lock", ledgerLockWait)
+	if err != nil && CodeOf(err) == CodeLocked {
+		return nil, Errorf(CodeLocked, "port ledger %s is locked by another pumadevctl process", path)
+	}
+	return release, err
+}
+
+// lockPath locks the file at path, creating it, retrying for up to wait while another process holds it.
+// Other locking failures (e.g. ENOLCK on NFS) are returned as they are, not as CodeLocked.
+func lockPath(path string, wait time.Duration) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		err := lockFile(f)
+		if err == nil {
+			break
+		}
+		if !lockHeld(err) {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, Errorf(CodeLocked, "%s is locked", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
@@ -0,0 +1,20 @@
+//go:build unix
+
+package internal
+
+import (
+	"fmt"
+	"syscall"
+	"testing"
+)
+
+func TestLockHeld(t *testing.T) {
+	if !lockHeld(syscall.EWOULDBLOCK) || !lockHeld(fmt.Errorf("flock: %w", syscall.EAGAIN)) {
+		t.Error("EWOULDBLOCK/EAGAIN mean another process holds the lock")
+	}
+	for _, err := range []error{syscall.ENOLCK, syscall.EBADF} {
+		if lockHeld(err) {
+			t.Errorf("%v must be reported as it is, not as a held lock", err)
+		}
+	}
+}
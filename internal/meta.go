@@ -0,0 +1,192 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MetaDirName is the subdirectory of the mappings dir holding pumadevctl's sidecar data.
+// LoadEntries (like puma-dev) ignores subdirectories, so it never shows up as an entry.
+const MetaDirName = ".pumadevctl"
+
+const metaFileName = "meta.json"
+
+// Meta is free-form information about a domain that cannot live in the entry file itself.
+type Meta struct {
+	Tags    []string `json:"tags,omitempty"`
+	Owner   string   `json:"owner,omitempty"`
+	Note    string   `json:"note,omitempty"`
+	Project string   `json:"project,omitempty"` // path of the app's repository
+	// Roles maps port role names to their offset inside the domain's block (see AssignPorts).
+	Roles map[string]int `json:"roles,omitempty"`
+}
+
+// IsZero reports whether m carries no information.
+func (m Meta) IsZero() bool {
+	return len(m.Tags) == 0 && m.Owner == "" && m.Note == "" && m.Project == "" && len(m.Roles) == 0
+}
+
+// HasTag reports whether m is tagged with tag (case-insensitive).
+func (m Meta) HasTag(tag string) bool {
+	tag = normalizeTag(tag)
+	for _, t := range m.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTags adds tags, keeping the list normalized, unique and sorted.
+func (m *Meta) AddTags(tags ...string) {
+	set := map[string]bool{}
+	for _, t := range m.Tags {
+		set[t] = true
+	}
+	for _, t := range tags {
+		if t = normalizeTag(t); t != "" {
+			set[t] = true
+		}
+	}
+	m.Tags = sortedKeys(set)
+}
+
+// RemoveTags removes tags if present.
+func (m *Meta) RemoveTags(tags ...string) {
+	drop := map[string]bool{}
+	for _, t := range tags {
+		drop[normalizeTag(t)] = true
+	}
+	kept := m.Tags[:0]
+	for _, t := range m.Tags {
+		if !drop[t] {
+			kept = append(kept, t)
+		}
+	}
+	m.Tags = kept
+}
+
+func normalizeTag(t string) string { return strings.ToLower(strings.TrimSpace(t)) }
+
+func sortedKeys(set map[string]bool) []string {
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// MetaStore is the JSON sidecar keyed by domain, stored at <dir>/.pumadevctl/meta.json.
+type MetaStore struct {
+	path    string
+	Version int             `json:"version"`
+	Domains map[string]Meta `json:"domains"`
+}
+
+// MetaPath returns the sidecar file location for a mappings dir.
+func MetaPath(dir string) string { return filepath.Join(dir, MetaDirName, metaFileName) }
+
+// LoadMeta reads the sidecar for dir. A missing file yields an empty store.
+func LoadMeta(dir string) (*MetaStore, error) {
+	s := &MetaStore{path: MetaPath(dir), Version: 1, Domains: map[string]Meta{}}
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return s, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	if s.Domains == nil {
+		s.Domains = map[string]Meta{}
+	}
+	return s, nil
+}
+
+// Get returns the metadata for domain, if any.
+func (s *MetaStore) Get(domain string) (Meta, bool) {
+	m, ok := s.Domains[domain]
+	return m, ok
+}
+
+// Set stores m for domain; zero metadata removes the key.
+func (s *MetaStore) Set(domain string, m Meta) {
+	if m.IsZero() {
+		delete(s.Domains, domain)
+		return
+	}
+	s.Domains[domain] = m
+}
+
+// Delete drops the metadata for domain.
+func (s *MetaStore) Delete(domain string) { delete(s.Domains, domain) }
+
+// Rename moves the metadata of oldDomain to newDomain.
+func (s *MetaStore) Rename(oldDomain, newDomain string) {
+	if m, ok := s.Domains[oldDomain]; ok {
+		delete(s.Domains, oldDomain)
+		s.Domains[newDomain] = m
+	}
+}
+
+// Save writes the sidecar atomically (temp file + rename).
+func (s *MetaStore) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, append(b, '\n'), 0644)
+}
+
+// Attach sets Entry.Meta for entries that have metadata.
+func (s *MetaStore) Attach(entries []Entry) {
+	for i := range entries {
+		if m, ok := s.Domains[entries[i].Domain]; ok {
+			mm := m
+			entries[i].Meta = &mm
+		}
+	}
+}
+
+// updateMeta loads the sidecar for dir, applies fn and saves it when fn reports a change.
+func updateMeta(dir string, fn func(s *MetaStore) bool) error {
+	s, err := LoadMeta(dir)
+	if err != nil {
+		return err
+	}
+	if !fn(s) {
+		return nil
+	}
+	return s.Save()
+}
+
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMetaFollowsRenameAndDelete(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteEntry(dir, "billing", "36000", false); err != nil {
+		t.Fatal(err)
+	}
+	store, err := LoadMeta(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := Meta{Owner: "payments"}
+	m.AddTags("Payments", "web", "payments")
+	store.Set("billing", m)
+	if err := store.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := LoadEntries(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("sidecar dir must not be listed as an entry: %#v", entries)
+	}
+	if entries[0].Meta == nil || entries[0].Meta.Owner != "payments" || len(entries[0].Meta.Tags) != 2 {
+		t.Fatalf("expected attached metadata, got %#v", entries[0].Meta)
+	}
+	if !entries[0].Meta.HasTag("PAYMENTS") {
+		t.Fatalf("expected tag filter to match case-insensitively")
+	}
+
+	if err := RenameEntry(dir, "billing", "bill", false); err != nil {
+		t.Fatal(err)
+	}
+	e, err := ReadEntry(dir, "bill")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Meta == nil || e.Meta.Owner != "payments" {
+		t.Fatalf("metadata did not follow rename: %#v", e.Meta)
+	}
+
+	if err := DeleteEntry(dir, "bill"); err != nil {
+		t.Fatal(err)
+	}
+	store, err = LoadMeta(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(store.Domains) != 0 {
+		t.Fatalf("metadata not removed on delete: %#v", store.Domains)
+	}
+	if _, err := os.Stat(filepath.Join(dir, MetaDirName)); err != nil {
+		t.Fatalf("sidecar dir should remain: %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// AppURL builds the URL puma-dev serves host (a name without the TLD, e.g. "admin.myapp") on. path may
+// carry a query or fragment; a missing leading slash is added.
+func AppURL(host, tld, path string, https bool) string {
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	u := url.URL{Scheme: "http", Host: host + "." + tld, Path: "/"}
+	if https {
+		u.Scheme = "https"
+	}
+	s := u.String()
+	if path = strings.TrimPrefix(path, "/"); path != "" {
+		s += path
+	}
+	return s
+}
+
+// Launcher opens a URL, typically in a browser.
+type Launcher interface {
+	Name() string
+	Launch(url string) error
+}
+
+// CommandLauncher runs Command with the URL appended to Args, or substituted for "%s" when an argument
+// contains it (the $BROWSER convention). It does not wait for the browser to exit.
+type CommandLauncher struct {
+	Command string
+	Args    []string
+}
+
+func (l CommandLauncher) Name() string { return l.Command }
+
+func (l CommandLauncher) Launch(u string) error {
+	args := make([]string, 0, len(l.Args)+1)
+	substituted := false
+	for _, a := range l.Args {
+		if strings.Contains(a, "%s") {
+			a = strings.ReplaceAll(a, "%s", u)
+			substituted = true
+		}
+		args = append(args, a)
+	}
+	if !substituted {
+		args = append(args, u)
+	}
+	c := exec.Command(l.Command, args...)
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Process.Release()
+}
+
+// ErrNoLauncher is returned by FindLauncher when neither $BROWSER nor a platform opener is available.
+var ErrNoLauncher = errors.New("no browser launcher found (set $BROWSER or install xdg-open)")
+
+// FindLauncher picks the first available launcher: each ':'-separated command in $BROWSER, then the platform
+// opener (open on macOS, xdg-open elsewhere, rundll32 on Windows). lookPath is exec.LookPath outside tests.
+func FindLauncher(getenv func(string) string, lookPath func(string) (string, error)) (Launcher, error) {
+	var candidates []CommandLauncher
+	for _, b := range strings.Split(getenv("BROWSER"), string(os.PathListSeparator)) {
+		if fields := strings.Fields(b); len(fields) > 0 {
+			candidates = append(candidates, CommandLauncher{Command: fields[0], Args: fields[1:]})
+		}
+	}
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = append(candidates, CommandLauncher{Command: "open"})
+	case "windows":
+		candidates = append(candidates, CommandLauncher{Command: "rundll32", Args: []string{"url.dll,FileProtocolHandler"}})
+	default:
+		candidates = append(candidates, CommandLauncher{Command: "xdg-open"})
+	}
+	for _, c := range candidates {
+		if path, err := lookPath(c.Command); err == nil {
+			c.Command = path
+			return c, nil
+		}
+	}
+	return nil, ErrNoLauncher
+}
+
+// OpenResult is the machine-readable result of open.
+type OpenResult struct {
+	Domain   string `json:"domain"` // entry serving the host
+	Host     string `json:"host"`
+	URL      string `json:"url"`
+	Launcher string `json:"launcher,omitempty"`
+	Launched bool   `json:"launched"`
+	Error    string `json:"error,omitempty"` // why launching failed; the URL is still printed
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestAppURL(t *testing.T) {
+	tests := []struct {
+		host, tld, path string
+		https           bool
+		want            string
+	}{
+		{"myapp", "", "", false, "http://myapp.test/"},
+		{"admin.myapp", "", "", true, "https://admin.myapp.test/"},
+		{"myapp", "localhost", "/login", false, "http://myapp.localhost/login"},
+		{"myapp", "", "users?page=2#top", false, "http://myapp.test/users?page=2#top"},
+	}
+	for _, tt := range tests {
+		if got := AppURL(tt.host, tt.tld, tt.path, tt.https); got != tt.want {
+			t.Errorf("AppURL(%q, %q, %q, %v) = %q, want %q", tt.host, tt.tld, tt.path, tt.https, got, tt.want)
+		}
+	}
+}
+
+func TestFindLauncher(t *testing.T) {
+	installed := map[string]bool{}
+	lookPath := func(name string) (string, error) {
+		if installed[name] {
+			return "/bin/" + name, nil
+		}
+		return "", errors.New("not found")
+	}
+	env := map[string]string{}
+	getenv := func(k string) string { return env[k] }
+
+	if _, err := FindLauncher(getenv, lookPath); !errors.Is(err, ErrNoLauncher) {
+		t.Fatalf("expected ErrNoLauncher, got %v", err)
+	}
+
+	env["BROWSER"] = "missing-browser:firefox --new-tab"
+	installed["firefox"] = true
+	l, err := FindLauncher(getenv, lookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := l.(CommandLauncher)
+	if c.Command != "/bin/firefox" || len(c.Args) != 1 || c.Args[0] != "--new-tab" {
+		t.Fatalf("expected firefox from $BROWSER, got %#v", c)
+	}
+
+	if runtime.GOOS == "linux" {
+		env["BROWSER"] = ""
+		installed["xdg-open"] = true
+		if l, err := FindLauncher(getenv, lookPath); err != nil || l.Name() != "/bin/xdg-open" {
+			t.Fatalf("expected xdg-open, got %v, %v", l, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/jedib0t/go-pretty/v6/text"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats accepted by -o/--output.
+const (
+	OutputTable    = "table"
+	OutputWide     = "wide"
+	OutputJSON     = "json"
+	OutputJSONL    = "jsonl"
+	OutputYAML     = "yaml"
+	OutputCSV      = "csv"
+	OutputTSV      = "tsv"
+	OutputName     = "name"
+	OutputTemplate = "template"
+)
+
+// OutputFormats lists the accepted -o values (template takes the form template=TEXT).
+var OutputFormats = []string{OutputTable, OutputWide, OutputJSON, OutputJSONL, OutputYAML, OutputCSV, OutputTSV, OutputName, OutputTemplate + "="}
+
+// Renderer writes command results in the format selected with -o.
+type Renderer struct {
+	Format string
+	Out    io.Writer
+	tmpl   *template.Template
+}
+
+// NewRenderer parses an -o spec such as "json" or "template={{.Domain}}" and binds it to w (stdout when nil).
+func NewRenderer(spec string, w io.Writer) (*Renderer, error) {
+	if w == nil {
+		w = os.Stdout
+	}
+	if spec == "" {
+		spec = OutputTable
+	}
+	r := &Renderer{Format: spec, Out: w}
+	if name, body, ok := strings.Cut(spec, "="); ok {
+		if name != OutputTemplate {
+			return nil, fmt.Errorf("unknown output format %q", spec)
+		}
+		t, err := template.New("output").Option("missingkey=error").Parse(body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output template: %w", err)
+		}
+		r.Format, r.tmpl = OutputTemplate, t
+		return r, nil
+	}
+	switch spec {
+	case OutputTable, OutputWide, OutputJSON, OutputJSONL, OutputYAML, OutputCSV, OutputTSV, OutputName:
+		return r, nil
+	case OutputTemplate:
+		return nil, fmt.Errorf("template output needs a template: -o template='{{.Domain}}'")
+	}
+	return nil, fmt.Errorf("unknown output format %q (want one of %s)", spec, strings.Join(OutputFormats, ", "))
+}
+
+// IsHuman reports whether the default table format is selected; commands then print their styled output.
+func (r *Renderer) IsHuman() bool { return r.Format == OutputTable }
+
+// IsMachine reports whether the selected format is meant for scripts (anything but table and wide).
+func (r *Renderer) IsMachine() bool { return r.Format != OutputTable && r.Format != OutputWide }
+
+// Column describes one field of a record in tabular formats.
+type Column[T any] struct {
+	Header string
+	Wide   bool // only shown with -o wide
+	Value  func(T) string
+}
+
+// View describes a command result for every format.
+// Doc is what json and yaml encode; Items are the records for jsonl, csv, tsv, table, wide, name and template.
+// A non-empty Kind versions the json/yaml document (see Versioned).
+type View[T any] struct {
+	Kind    string
+	Doc     any
+	Items   []T
+	Columns []Column[T]
+	Name    func(T) string
+}
+
+// Render writes v in r's format.
+func Render[T any](r *Renderer, v View[T]) error {
+	switch r.Format {
+	case OutputJSON:
+		doc, err := Versioned(v.Kind, v.Doc)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(r.Out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	case OutputYAML:
+		doc, err := Versioned(v.Kind, v.Doc)
+		if err != nil {
+			return err
+		}
+		return writeYAML(r.Out, doc)
+	case OutputJSONL:
+		enc := json.NewEncoder(r.Out)
+		for _, it := range v.Items {
+			if err := enc.Encode(it); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputName:
+		for _, it := range v.Items {
+			if _, err := fmt.Fprintln(r.Out, v.Name(it)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputTemplate:
+		for _, it := range v.Items {
+			var buf bytes.Buffer
+			if err := r.tmpl.Execute(&buf, it); err != nil {
+				return err
+			}
+			if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+				buf.WriteByte('\n')
+			}
+			if _, err := r.Out.Write(buf.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case OutputCSV, OutputTSV:
+		cw := csv.NewWriter(r.Out)
+		if r.Format == OutputTSV {
+			cw.Comma = '\t'
+		}
+		cols := v.Columns
+		header := make([]string, len(cols))
+		for i, c := range cols {
+			header[i] = strings.ToLower(c.Header)
+		}
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+		for _, it := range v.Items {
+			row := make([]string, len(cols))
+			for i, c := range cols {
+				row[i] = c.Value(it)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	// table and wide
+	wide := r.Format == OutputWide
+	tw := table.NewWriter()
+	tw.SetOutputMirror(r.Out)
+	var header table.Row
+	for _, c := range v.Columns {
+		if c.Wide && !wide {
+			continue
+		}
+		header = append(header, c.Header)
+	}
+	tw.AppendHeader(header)
+	for _, it := range v.Items {
+		var row table.Row
+		for _, c := range v.Columns {
+			if c.Wide && !wide {
+				continue
+			}
+			row = append(row, c.Value(it))
+		}
+		tw.AppendRow(row)
+	}
+	tw.SetStyle(table.StyleRounded)
+	tw.Style().Format.Header = text.FormatDefault
+	tw.Render()
+	return nil
+}
+
+// listDocument is the envelope for collection documents.
+type listDocument struct {
+	SchemaVersion int    `json:"schema_version"`
+	Kind          string `json:"kind"`
+	Items         any    `json:"items"`
+}
+
+// versionHeader holds the fields prepended to object documents.
+type versionHeader struct {
+	SchemaVersion int    `json:"schema_version"`
+	Kind          string `json:"kind"`
+}
+
+// Versioned adds "schema_version" and "kind" to doc: collections are wrapped as {"schema_version", "kind", "items"},
+// objects get the two fields prepended. An empty kind returns doc unchanged.
+func Versioned(kind string, doc any) (any, error) {
+	if kind == "" {
+		return doc, nil
+	}
+	rv := reflect.ValueOf(doc)
+	for rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() == reflect.Slice {
+		return listDocument{SchemaVersion: SchemaVersion, Kind: kind, Items: doc}, nil
+	}
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) < 2 || b[0] != '{' {
+		return nil, fmt.Errorf("cannot version %s document of type %T", kind, doc)
+	}
+	head, err := json.Marshal(versionHeader{SchemaVersion: SchemaVersion, Kind: kind})
+	if err != nil {
+		return nil, err
+	}
+	if string(b) == "{}" {
+		return json.RawMessage(head), nil
+	}
+	// splice: {"schema_version":1,"kind":"X"} + {"a":...} → {"schema_version":1,"kind":"X","a":...}
+	head[len(head)-1] = ','
+	return json.RawMessage(append(head, b[1:]...)), nil
+}
+
+// writeYAML encodes doc as YAML using its JSON field names and order.
+func writeYAML(w io.Writer, doc any) error {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return err
+	}
+	resetYAMLStyle(&node)
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// resetYAMLStyle drops the flow/quoted styles inherited from JSON so output is block-style YAML.
+func resetYAMLStyle(n *yaml.Node) {
+	n.Style = 0
+	for _, c := range n.Content {
+		resetYAMLStyle(c)
+	}
+}
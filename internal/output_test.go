@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRender_Formats(t *testing.T) {
+	entries := []Entry{
+		{Domain: "api", Mapping: "36000"},
+		{Domain: "docs", IsSymlink: true, LinkTarget: "/src/docs"},
+	}
+	cases := map[string]string{
+		"name":                   "api\ndocs\n",
+		"template={{.Domain}}":   "api\ndocs\n",
+		"csv":                    "domain,type,target,tags,owner,project\napi,file,36000,,,\ndocs,symlink,/src/docs,,,\n",
+		"jsonl":                  `{"domain":"api","mapping":"36000","is_symlink":false}` + "\n" + `{"domain":"docs","mapping":"","is_symlink":true,"link_target":"/src/docs"}` + "\n",
+		"yaml":                   "schema_version: 1\nkind: ListGroupList\nitems:\n  - mapping: (symlink)\n    domains:\n      - docs\n  - mapping: \"36000\"\n    domains:\n      - api\n",
+		"template={{.Mapping}}x": "36000x\nx\n",
+	}
+	for spec, want := range cases {
+		var buf bytes.Buffer
+		r, err := NewRenderer(spec, &buf)
+		if err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if err := Render(r, ListView(entries)); err != nil {
+			t.Fatalf("%s: %v", spec, err)
+		}
+		if buf.String() != want {
+			t.Errorf("%s:\n got %q\nwant %q", spec, buf.String(), want)
+		}
+	}
+}
+
+func TestNewRenderer_Errors(t *testing.T) {
+	for _, spec := range []string{"xml", "template", "template={{.Domain", "jsonpath={.x}"} {
+		if _, err := NewRenderer(spec, nil); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+	r, err := NewRenderer("", nil)
+	if err != nil || !r.IsHuman() || r.IsMachine() {
+		t.Fatalf("empty spec should select the human table format")
+	}
+	r, _ = NewRenderer("wide", nil)
+	if r.IsHuman() || r.IsMachine() {
+		t.Fatalf("wide is neither the styled table nor a machine format")
+	}
+	if !strings.Contains(strings.Join(OutputFormats, ","), "jsonl") {
+		t.Fatalf("OutputFormats should list jsonl")
+	}
+}
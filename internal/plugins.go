@@ -0,0 +1,113 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// PluginPrefix starts the file name of plugin executables: pumadevctl-foo runs as `pumadevctl foo`.
+const PluginPrefix = "pumadevctl-"
+
+// Plugin states.
+const (
+	PluginOK       = "ok"
+	PluginShadowed = "shadowed" // a built-in command has the same name, so the plugin never runs
+)
+
+// Plugin is an external subcommand found on disk.
+type Plugin struct {
+	Name   string   `json:"name"`
+	Path   string   `json:"path"`
+	Status string   `json:"status"`           // ok or shadowed
+	Hidden []string `json:"hidden,omitempty"` // executables of the same name found later, which never run
+}
+
+// PluginDir is the directory searched for plugins before $PATH.
+func PluginDir() string { return filepath.Join(XDGDataDir(), "plugins") }
+
+// PluginSearchPath returns the directories searched for plugins, in order: PluginDir, then $PATH.
+func PluginSearchPath() []string {
+	return append([]string{PluginDir()}, filepath.SplitList(os.Getenv("PATH"))...)
+}
+
+// FindPlugins lists the pumadevctl-* executables in dirs, sorted by name. The first executable of a name
+// wins, like a shell lookup; builtin reports names taken by built-in commands.
+func FindPlugins(dirs []string, builtin func(name string) bool) []Plugin {
+	byName := map[string]*Plugin{}
+	seen := map[string]bool{}
+	for _, dir := range dirs {
+		if dir == "" || seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		items, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, it := range items {
+			name, ok := pluginName(it.Name())
+			if !ok {
+				continue
+			}
+			path := filepath.Join(dir, it.Name())
+			if fi, err := os.Stat(path); err != nil || !isExecutable(fi) {
+				continue
+			}
+			if p, ok := byName[name]; ok {
+				p.Hidden = append(p.Hidden, path)
+				continue
+			}
+			p := &Plugin{Name: name, Path: path, Status: PluginOK}
+			if builtin != nil && builtin(name) {
+				p.Status = PluginShadowed
+			}
+			byName[name] = p
+		}
+	}
+	out := make([]Plugin, 0, len(byName))
+	for _, p := range byName {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// pluginName returns the subcommand name of a plugin file name (without the prefix and, on Windows, the
+// extension).
+func pluginName(file string) (string, bool) {
+	if !strings.HasPrefix(file, PluginPrefix) {
+		return "", false
+	}
+	name := strings.TrimPrefix(file, PluginPrefix)
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(name))
+		if ext != ".exe" && ext != ".bat" && ext != ".cmd" {
+			return "", false
+		}
+		name = strings.TrimSuffix(name, filepath.Ext(name))
+	}
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, " \t") {
+		return "", false
+	}
+	return name, true
+}
+
+func isExecutable(fi os.FileInfo) bool {
+	if fi.IsDir() {
+		return false
+	}
+	return runtime.GOOS == "windows" || fi.Mode()&0o111 != 0
+}
+
+// PluginEnv is the environment a plugin runs with, on top of pumadevctl's own: the resolved mappings dir,
+// the config file and the output mode, so a plugin (or a pumadevctl it runs) works on the same dir.
+func PluginEnv(dir, configPath, output string) map[string]string {
+	return map[string]string{
+		"PUMADEVCTL_DIR":    dir,
+		"PUMADEVCTL_CONFIG": configPath,
+		"PUMADEVCTL_OUTPUT": output,
+	}
+}
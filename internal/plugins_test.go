@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFindPlugins(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are found by extension on windows")
+	}
+	first, second := t.TempDir(), t.TempDir()
+	write := func(dir, name string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	deploy := write(first, "pumadevctl-deploy", 0o755)
+	hidden := write(second, "pumadevctl-deploy", 0o755)
+	write(first, "pumadevctl-notes", 0o644)
+	write(first, "other-tool", 0o755)
+	list := write(second, "pumadevctl-list", 0o755)
+	if err := os.Mkdir(filepath.Join(second, "pumadevctl-dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	got := FindPlugins([]string{first, "", second, first}, func(name string) bool { return name == "list" })
+	if len(got) != 2 {
+		t.Fatalf("expected deploy and list, got %+v", got)
+	}
+	if got[0].Name != "deploy" || got[0].Path != deploy || got[0].Status != PluginOK ||
+		len(got[0].Hidden) != 1 || got[0].Hidden[0] != hidden {
+		t.Errorf("unexpected deploy plugin %+v", got[0])
+	}
+	if got[1].Name != "list" || got[1].Path != list || got[1].Status != PluginShadowed {
+		t.Errorf("unexpected list plugin %+v", got[1])
+	}
+}
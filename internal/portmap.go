@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// Port block states shown by ports map.
+const (
+	BlockFree     = "free"
+	BlockOwned    = "owned"    // one aligned entry (or aliases sharing its port)
+	BlockLeased   = "leased"   // held by a ledger lease whose entry is gone
+	BlockConflict = "conflict" // misaligned entry or several entries overlapping
+	BlockForeign  = "foreign"  // something not managed here is listening
+)
+
+// PortBlock is one block-aligned slot of the configured port range.
+type PortBlock struct {
+	Base    int      `json:"base"`
+	End     int      `json:"end"`
+	State   string   `json:"state"`
+	Domains []string `json:"domains,omitempty"`
+	Detail  string   `json:"detail,omitempty"`
+}
+
+// PortMapStats summarizes a PortMap.
+type PortMapStats struct {
+	Blocks         int     `json:"blocks"`
+	Free           int     `json:"free"`
+	Owned          int     `json:"owned"`
+	Leased         int     `json:"leased"`
+	Conflict       int     `json:"conflict"`
+	Foreign        int     `json:"foreign"`
+	Utilization    float64 `json:"utilization"`               // share of non-free blocks, 0..1
+	LargestFreeRun int     `json:"largest_free_run"`          // consecutive free blocks
+	LargestFreeAt  int     `json:"largest_free_at,omitempty"` // base port of that run
+}
+
+// PortMap is the state of every block in [Min, Max].
+type PortMap struct {
+	Min       int          `json:"port_min"`
+	Max       int          `json:"port_max"`
+	BlockSize int          `json:"block_size"`
+	Stats     PortMapStats `json:"stats"`
+	Blocks    []PortBlock  `json:"blocks"`
+}
+
+// BuildPortMap classifies each aligned block of a's range using the same reservations the allocator honours:
+// entry blocks, ledger leases of domains without an entry and, with a.Prober, foreign listeners.
+// Ports inside entry blocks are not probed, since the owning app is expected to listen there.
+func BuildPortMap(entries []Entry, a *Allocator) (PortMap, error) {
+	pm := PortMap{Min: a.Min, Max: a.Max, BlockSize: a.Block, Blocks: []PortBlock{}}
+	if err := checkBlockParams(a.Min, a.Max, a.Block); err != nil {
+		return pm, err
+	}
+	owned := EntryReservations(entries, a.Block)
+	leased := a.foreignLeases(entries)
+	run, runAt := 0, 0
+	for base := a.Min; base+a.Block-1 <= a.Max; base += a.Block {
+		b := PortBlock{Base: base, End: base + a.Block - 1, State: BlockFree}
+		classifyBlock(&b, owned, leased, a)
+		pm.Blocks = append(pm.Blocks, b)
+		s := &pm.Stats
+		s.Blocks++
+		switch b.State {
+		case BlockFree:
+			s.Free++
+			if run == 0 {
+				runAt = base
+			}
+			run++
+			if run > s.LargestFreeRun {
+				s.LargestFreeRun, s.LargestFreeAt = run, runAt
+			}
+			continue
+		case BlockOwned:
+			s.Owned++
+		case BlockLeased:
+			s.Leased++
+		case BlockConflict:
+			s.Conflict++
+		case BlockForeign:
+			s.Foreign++
+		}
+		run = 0
+	}
+	if pm.Stats.Blocks > 0 {
+		pm.Stats.Utilization = float64(pm.Stats.Blocks-pm.Stats.Free) / float64(pm.Stats.Blocks)
+	}
+	return pm, nil
+}
+
+func classifyBlock(b *PortBlock, owned, leased []Reservation, a *Allocator) {
+	var hits []Reservation
+	ports := map[int]bool{}
+	for _, r := range owned {
+		if !(b.End < r.Start || b.Base > r.End) {
+			hits = append(hits, r)
+			ports[r.Start] = true
+		}
+	}
+	if len(hits) > 0 {
+		for _, r := range hits {
+			b.Domains = append(b.Domains, r.Domain)
+		}
+		sort.Strings(b.Domains)
+		switch {
+		case len(ports) > 1:
+			b.State, b.Detail = BlockConflict, "overlapping entries"
+		case !ports[b.Base]:
+			b.State, b.Detail = BlockConflict, fmt.Sprintf("misaligned: starts at %d", hits[0].Start)
+		default:
+			b.State = BlockOwned
+		}
+		return
+	}
+	if r := overlapping(leased, b.Base, b.End); r != nil {
+		b.State, b.Domains, b.Detail = BlockLeased, []string{r.Domain}, fmt.Sprintf("lease %d-%d", r.Start, r.End)
+		return
+	}
+	if err := a.probe(b.Base, b.End); err != nil {
+		b.State, b.Detail = BlockForeign, err.Error()
+	}
+}
+
+var blockGlyphs = map[string]string{
+	BlockFree:     text.FgHiBlack.Sprint("."),
+	BlockOwned:    text.FgGreen.Sprint("#"),
+	BlockLeased:   text.FgCyan.Sprint("L"),
+	BlockConflict: text.FgRed.Sprint("!"),
+	BlockForeign:  text.FgYellow.Sprint("x"),
+}
+
+// PrintPortMap draws pm as a grid with perRow blocks per line, followed by a legend.
+func PrintPortMap(w io.Writer, pm PortMap, perRow int) {
+	if perRow <= 0 {
+		perRow = 10
+	}
+	for i := 0; i < len(pm.Blocks); i += perRow {
+		var row strings.Builder
+		for j := i; j < i+perRow && j < len(pm.Blocks); j++ {
+			row.WriteString(blockGlyphs[pm.Blocks[j].State])
+			row.WriteByte(' ')
+		}
+		fmt.Fprintf(w, "%5d  %s\n", pm.Blocks[i].Base, strings.TrimRight(row.String(), " "))
+	}
+	fmt.Fprintf(w, "\n%s free  %s owned  %s leased  %s misaligned/overlapping  %s foreign  (1 cell = %d ports)\n",
+		blockGlyphs[BlockFree], blockGlyphs[BlockOwned], blockGlyphs[BlockLeased], blockGlyphs[BlockConflict], blockGlyphs[BlockForeign], pm.BlockSize)
+}
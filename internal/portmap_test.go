@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildPortMap(t *testing.T) {
+	dir := t.TempDir()
+	entries := []Entry{
+		{Domain: "a.test", Mapping: "36000"},
+		{Domain: "a-alias.test", Mapping: "36000"},
+		{Domain: "b.test", Mapping: "36025"}, // misaligned: spills over 36020 and 36030
+		{Domain: "docs.test", IsSymlink: true},
+	}
+	ledger := &Ledger{}
+	ledger.Reserve(dir, "gone.test", 36050, 10, time.Now())
+	a := &Allocator{Min: 36000, Max: 36099, Block: 10, Dir: dir, Ledger: ledger, Prober: busyPorts{36088: true}}
+
+	pm, err := BuildPortMap(entries, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{BlockOwned, BlockFree, BlockConflict, BlockConflict, BlockFree, BlockLeased, BlockFree, BlockFree, BlockForeign, BlockFree}
+	if len(pm.Blocks) != len(want) {
+		t.Fatalf("expected %d blocks, got %d", len(want), len(pm.Blocks))
+	}
+	for i, b := range pm.Blocks {
+		if b.State != want[i] {
+			t.Errorf("block %d: got %s (%s), want %s", b.Base, b.State, b.Detail, want[i])
+		}
+	}
+	if got := pm.Blocks[0].Domains; len(got) != 2 {
+		t.Errorf("expected both aliases on the first block, got %v", got)
+	}
+	s := pm.Stats
+	if s.Free != 5 || s.Owned != 1 || s.Conflict != 2 || s.Leased != 1 || s.Foreign != 1 {
+		t.Errorf("unexpected counts %#v", s)
+	}
+	if s.Utilization != 0.5 || s.LargestFreeRun != 2 || s.LargestFreeAt != 36060 {
+		t.Errorf("unexpected summary %#v", s)
+	}
+}
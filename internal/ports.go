@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPortRoles is used when no port_roles are configured: the whole block is the app's PORT.
+const DefaultPortRoles = "web:+0"
+
+// PortRole names a port at a fixed offset inside a domain's block.
+type PortRole struct {
+	Name   string
+	Offset int
+}
+
+// PortAssignment is the port a role of a domain resolves to.
+type PortAssignment struct {
+	Domain string `json:"domain"`
+	Role   string `json:"role"`
+	Offset int    `json:"offset"`
+	Port   int    `json:"port"`
+	Env    string `json:"env"` // environment variable printed by --export-env
+}
+
+// ParsePortRoles parses a spec such as "web:+0,vite:+1,cable:+2". Offsets must fit in a block of the given size
+// and neither names nor offsets may repeat.
+func ParsePortRoles(spec string, block int) ([]PortRole, error) {
+	var roles []PortRole
+	names := map[string]bool{}
+	offsets := map[int]string{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, off, ok := strings.Cut(part, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || !validRoleName(name) {
+			return nil, Errorf(CodeConfig, "invalid port role %q (want name:+offset)", part)
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(off), "+"))
+		if err != nil || n < 0 {
+			return nil, Errorf(CodeConfig, "invalid offset in port role %q", part)
+		}
+		if block > 0 && n >= block {
+			return nil, Errorf(CodeConfig, "port role %s offset +%d does not fit in a block of %d ports", name, n, block)
+		}
+		if names[name] {
+			return nil, Errorf(CodeConfig, "port role %s defined twice", name)
+		}
+		if other, dup := offsets[n]; dup {
+			return nil, Errorf(CodeConfig, "port roles %s and %s share offset +%d", other, name, n)
+		}
+		names[name], offsets[n] = true, name
+		roles = append(roles, PortRole{Name: name, Offset: n})
+	}
+	return roles, nil
+}
+
+func validRoleName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// RoleEnvVar is the environment variable for a role: the base port (offset 0) is PORT,
+// other roles are <ROLE>_PORT (vite → VITE_PORT, sidekiq-web → SIDEKIQ_WEB_PORT).
+func RoleEnvVar(role string, offset int) string {
+	if offset == 0 {
+		return "PORT"
+	}
+	return strings.ToUpper(strings.ReplaceAll(role, "-", "_")) + "_PORT"
+}
+
+// AssignPorts resolves roles for a domain whose block starts at base. Offsets already recorded in m.Roles win,
+// so changing the configured roles never moves an existing reservation; new roles get their configured offset,
+// or the first free one in the block when it is taken. With reset, recorded offsets are replaced by the config.
+// It reports whether m.Roles changed and needs saving.
+func AssignPorts(domain string, base, block int, roles []PortRole, m *Meta, reset bool) ([]PortAssignment, bool, error) {
+	if reset && len(m.Roles) > 0 {
+		m.Roles = nil
+	}
+	changed := reset
+	used := map[int]bool{}
+	for _, off := range m.Roles {
+		used[off] = true
+	}
+	for _, r := range roles {
+		if _, ok := m.Roles[r.Name]; ok {
+			continue
+		}
+		off := r.Offset
+		for used[off] {
+			off++
+		}
+		if block > 0 && off >= block {
+			return nil, false, Errorf(CodeNoFreePort, "no free port left in the block of %s for role %s", domain, r.Name)
+		}
+		if m.Roles == nil {
+			m.Roles = map[string]int{}
+		}
+		m.Roles[r.Name] = off
+		used[off] = true
+		changed = true
+	}
+	out := make([]PortAssignment, 0, len(m.Roles))
+	for name, off := range m.Roles {
+		out = append(out, PortAssignment{Domain: domain, Role: name, Offset: off, Port: base + off, Env: RoleEnvVar(name, off)})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out, changed, nil
+}
+
+// EntryBasePort returns the port of a file entry, the base of its block.
+func EntryBasePort(e *Entry) (int, error) {
+	if e.IsSymlink {
+		return 0, fmt.Errorf("entry %s is a symlink and has no port block", e.Domain)
+	}
+	m, err := ParseMapping(e.Mapping)
+	if err != nil {
+		return 0, fmt.Errorf("entry %s: %w", e.Domain, err)
+	}
+	return m.Port, nil
+}
+
+// AssignDomainPorts records the configured roles for domain in the metadata sidecar and returns its assignments.
+func AssignDomainPorts(dir, domain string, block int, roles []PortRole, reset bool) ([]PortAssignment, error) {
+	e, err := readEntry(dir, domain)
+	if err != nil {
+		return nil, err
+	}
+	base, err := EntryBasePort(e)
+	if err != nil {
+		return nil, err
+	}
+	var out []PortAssignment
+	var assignErr error
+	err = updateMeta(dir, func(s *MetaStore) bool {
+		m, _ := s.Get(domain)
+		var changed bool
+		out, changed, assignErr = AssignPorts(domain, base, block, roles, &m, reset)
+		if assignErr != nil || !changed {
+			return false
+		}
+		s.Set(domain, m)
+		return true
+	})
+	if assignErr != nil {
+		return nil, assignErr
+	}
+	return out, err
+}
@@ -0,0 +1,64 @@
+package internal
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortRoles(t *testing.T) {
+	roles, err := ParsePortRoles("web:+0, vite:+1,Sidekiq-Web:2", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PortRole{{"web", 0}, {"vite", 1}, {"sidekiq-web", 2}}
+	if !reflect.DeepEqual(roles, want) {
+		t.Fatalf("got %v, want %v", roles, want)
+	}
+	for _, bad := range []string{"web", "web:+x", "web:+10", "web:+0,web:+1", "web:+0,vite:+0", "we b:+1"} {
+		if _, err := ParsePortRoles(bad, 10); CodeOf(err) != CodeConfig {
+			t.Errorf("%q: expected config error, got %v", bad, err)
+		}
+	}
+}
+
+func TestAssignDomainPorts_RolesDoNotShift(t *testing.T) {
+	dir := t.TempDir()
+	if err := WriteEntry(dir, "app", "36010", false); err != nil {
+		t.Fatal(err)
+	}
+	roles, _ := ParsePortRoles("web:+0,vite:+1,cable:+2", 10)
+	got, err := AssignDomainPorts(dir, "app", 10, roles, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || got[1].Port != 36011 || got[1].Env != "VITE_PORT" || got[0].Env != "PORT" {
+		t.Fatalf("unexpected assignments %#v", got)
+	}
+
+	// Reordering the config must not move recorded roles; a new role colliding with one takes the next free offset.
+	roles = []PortRole{{"web", 0}, {"cable", 1}, {"vite", 2}, {"anycable", 1}}
+	got, err = AssignDomainPorts(dir, "app", 10, roles, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ports := map[string]int{}
+	for _, p := range got {
+		ports[p.Role] = p.Port
+	}
+	want := map[string]int{"web": 36010, "vite": 36011, "cable": 36012, "anycable": 36013}
+	if !reflect.DeepEqual(ports, want) {
+		t.Fatalf("got %v, want %v", ports, want)
+	}
+
+	// The recorded offsets follow the entry when its base port changes.
+	if err := UpdateEntry(dir, "app", "36100"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ = AssignDomainPorts(dir, "app", 10, nil, false)
+	if got[1].Port != 36101 {
+		t.Fatalf("expected vite to follow the base port, got %#v", got)
+	}
+	if got, _ = AssignDomainPorts(dir, "app", 10, []PortRole{{"web", 0}}, true); len(got) != 1 {
+		t.Fatalf("expected reset to keep only configured roles, got %#v", got)
+	}
+}
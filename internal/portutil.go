@@ -44,6 +44,15 @@ func ParseMapping(s string) (*Mapping, error) {
 	return &Mapping{Host: host, Port: p, Raw: s}, nil
 }
 
+// IsLoopback reports whether the mapping targets this host, where its port block takes local ports.
+func (m Mapping) IsLoopback() bool {
+	if m.Host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(m.Host)
+	return ip != nil && ip.IsLoopback()
+}
+
 // IsPortReachable tries to connect to host:port with timeout
 func IsPortReachable(host string, port int, timeout time.Duration) bool {
 	addr := net.JoinHostPort(host, strconv.Itoa(port))
@@ -71,51 +80,74 @@ func FindNextFreePort(start int, used map[int]bool) (int, error) {
 		_ = ln.Close()
 		return p, nil
 	}
-	return 0, fmt.Errorf("no free port found")
+	return 0, Errorf(CodeNoFreePort, "no free port found")
+}
+
+// Reservation is a range of ports [Start, End] held by a domain, through its entry file or a ledger lease.
+type Reservation struct {
+	Domain string
+	Start  int
+	End    int
+	Source string // "entry", "lease" or "entry in context NAME"
+}
+
+// EntryReservations returns the block each file entry reserves, starting at its mapped port.
+func EntryReservations(entries []Entry, block int) []Reservation {
+	var out []Reservation
+	for _, e := range entries {
+		if e.IsSymlink {
+			continue
+		}
+		m, err := ParseMapping(e.Mapping)
+		if err != nil {
+			continue
+		}
+		out = append(out, Reservation{Domain: e.Domain, Start: m.Port, End: m.Port + block - 1, Source: "entry"})
+	}
+	return out
 }
 
 // FindNextAvailablePortBlock returns the base port of the first available block within [min,max]
 // where each allocation reserves `block` consecutive ports [base, base+block-1].
 // Existing file entries are interpreted as reserving a block starting at their mapped port.
 func FindNextAvailablePortBlock(entries []Entry, min, max, block int) (int, error) {
+	if err := checkBlockParams(min, max, block); err != nil {
+		return 0, err
+	}
+	return findFreeBlock(EntryReservations(entries, block), min, max, block)
+}
+
+func checkBlockParams(min, max, block int) error {
 	if block <= 0 {
-		return 0, fmt.Errorf("invalid block size: %d", block)
+		return Errorf(CodeConfig, "invalid block size: %d", block)
 	}
 	if min < 1 || max > 65535 || min > max {
-		return 0, fmt.Errorf("invalid port range: %d-%d", min, max)
+		return Errorf(CodeConfig, "invalid port range: %d-%d", min, max)
 	}
 	if max-min+1 < block {
-		return 0, fmt.Errorf("port range too small for block size: range=%d, block=%d", max-min+1, block)
+		return Errorf(CodeConfig, "port range too small for block size: range=%d, block=%d", max-min+1, block)
 	}
-	// collect reserved ranges
-	type rng struct{ s, e int }
-	var reserved []rng
-	for _, e := range entries {
-		if e.IsSymlink {
-			continue
-		}
-		m, err := ParseMapping(e.Mapping)
-		if err != nil {
-			continue
-		}
-		base := m.Port
-		reserved = append(reserved, rng{base, base + block - 1})
-	}
-	// merge (optional) - for faster checks sort by start
-	sort.Slice(reserved, func(i, j int) bool { return reserved[i].s < reserved[j].s })
+	return nil
+}
+
+// findFreeBlock scans block-aligned candidates in [min,max] and returns the first one overlapping no reservation.
+func findFreeBlock(reserved []Reservation, min, max, block int) (int, error) {
+	sort.Slice(reserved, func(i, j int) bool { return reserved[i].Start < reserved[j].Start })
 	// scan aligned to block boundaries for determinism
 	for base := min; base+block-1 <= max; base += block {
-		end := base + block - 1
-		conflict := false
-		for _, r := range reserved {
-			if !(end < r.s || base > r.e) { // overlap
-				conflict = true
-				break
-			}
-		}
-		if !conflict {
+		if overlapping(reserved, base, base+block-1) == nil {
 			return base, nil
 		}
 	}
-	return 0, fmt.Errorf("no available port block in %d-%d with block size %d", min, max, block)
+	return 0, Errorf(CodeNoFreePort, "no available port block in %d-%d with block size %d", min, max, block)
+}
+
+// overlapping returns the first reservation intersecting [start, end], or nil.
+func overlapping(reserved []Reservation, start, end int) *Reservation {
+	for i, r := range reserved {
+		if !(end < r.Start || start > r.End) {
+			return &reserved[i]
+		}
+	}
+	return nil
 }
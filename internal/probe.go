@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// PortProber reports whether a port is in use on this machine: nil means free, an error says why not.
+// The allocator takes it as an interface so tests can stay hermetic.
+type PortProber interface {
+	Probe(port int) error
+}
+
+// ListenProber probes by binding each port on every host in Hosts (default 127.0.0.1 and ::1).
+// Hosts the system cannot bind at all, such as ::1 without IPv6, are skipped.
+type ListenProber struct {
+	Hosts []string
+}
+
+// DefaultProbeHosts are the loopback addresses apps behind puma-dev usually bind.
+var DefaultProbeHosts = []string{"127.0.0.1", "::1"}
+
+func (p ListenProber) Probe(port int) error {
+	hosts := p.Hosts
+	if len(hosts) == 0 {
+		hosts = DefaultProbeHosts
+	}
+	for _, h := range hosts {
+		addr := net.JoinHostPort(h, strconv.Itoa(port))
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			if errors.Is(err, syscall.EADDRNOTAVAIL) || errors.Is(err, syscall.EAFNOSUPPORT) {
+				continue
+			}
+			if errors.Is(err, syscall.EADDRINUSE) {
+				return fmt.Errorf("port %d in use on %s", port, h)
+			}
+			return fmt.Errorf("port %d not bindable on %s: %w", port, h, err)
+		}
+		_ = ln.Close()
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectFileNames are the per-repo config files searched for, in order, in a directory and its parents.
+var ProjectFileNames = []string{".pumadev.yml", ".pumadev.yaml"}
+
+// Project entry modes.
+const (
+	ModePort = "port" // file entries holding the app's port
+	ModeLink = "link" // symlink entries pointing at the project, started by puma-dev itself
+)
+
+// ProjectConfig is a repo's .pumadev.yml:
+//
+//	domain: myapp                # or domains: [myapp, admin.myapp]; the first one owns the port block
+//	port: 36500                  # preferred base port; auto-allocated like `create` when empty or taken
+//	mode: port                   # or link: symlink every domain to the project (or to `link:`)
+//	roles: "web:+0,vite:+1"
+//	port_min: 36000              # dir, port_min, port_max, port_block_size and tld override the user config
+//
+// Relative paths are resolved against the directory holding the file.
+type ProjectConfig struct {
+	Domains []string `yaml:"domains"`
+	Port    int      `yaml:"port"`
+	Mode    string   `yaml:"mode"`
+	Link    string   `yaml:"link"`
+	Roles   string   `yaml:"roles"`
+
+	Dir           string `yaml:"dir"`
+	PortMin       int    `yaml:"port_min"`
+	PortMax       int    `yaml:"port_max"`
+	PortBlockSize int    `yaml:"port_block_size"`
+	TLD           string `yaml:"tld"`
+
+	Path string `yaml:"-"` // the file
+	Root string `yaml:"-"` // its directory
+}
+
+// FindProjectConfig loads the nearest project file in start or its parents. It returns nil, nil when there is none.
+func FindProjectConfig(start string) (*ProjectConfig, error) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		for _, name := range ProjectFileNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return LoadProjectConfig(path)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// LoadProjectConfig parses and validates a project file.
+func LoadProjectConfig(path string) (*ProjectConfig, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		ProjectConfig `yaml:",inline"`
+		Domain        string `yaml:"domain"`
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(b))
+	dec.KnownFields(true) // catch typos such as "prot:"
+	if err := dec.Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+		return nil, Errorf(CodeConfig, "parse %s: %w", path, err)
+	}
+	pc := raw.ProjectConfig
+	pc.Path, pc.Root = path, filepath.Dir(path)
+	if raw.Domain != "" {
+		pc.Domains = append([]string{raw.Domain}, pc.Domains...)
+	}
+	if len(pc.Domains) == 0 {
+		return nil, Errorf(CodeConfig, "%s: no domain declared", path)
+	}
+	seen := map[string]bool{}
+	for i, d := range pc.Domains {
+		n, err := NormalizeDomain(d, pc.TLD)
+		if err != nil {
+			return nil, Errorf(CodeConfig, "%s: %v", path, err)
+		}
+		if seen[n] {
+			return nil, Errorf(CodeConfig, "%s: domain %s declared twice", path, n)
+		}
+		seen[n], pc.Domains[i] = true, n
+	}
+	switch pc.Mode {
+	case "":
+		pc.Mode = ModePort
+		if pc.Link != "" {
+			pc.Mode = ModeLink
+		}
+	case ModePort, ModeLink:
+	default:
+		return nil, Errorf(CodeConfig, "%s: mode must be %q or %q, got %q", path, ModePort, ModeLink, pc.Mode)
+	}
+	if pc.Mode == ModeLink && pc.Port != 0 {
+		return nil, Errorf(CodeConfig, "%s: port is only used in %s mode", path, ModePort)
+	}
+	if pc.Mode == ModeLink {
+		pc.Link = pc.resolvePath(pc.Link)
+	}
+	if pc.Dir != "" {
+		pc.Dir = pc.resolvePath(pc.Dir)
+	}
+	pc.TLD = strings.TrimPrefix(pc.TLD, ".")
+	return &pc, nil
+}
+
+// resolvePath expands ~ and makes p absolute relative to the project root; empty means the root itself.
+func (pc *ProjectConfig) resolvePath(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		home, _ := os.UserHomeDir()
+		p = filepath.Join(home, p[1:])
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(pc.Root, p)
+	}
+	return filepath.Clean(p)
+}
+
+// layer returns the project's overrides of the user config.
+func (pc *ProjectConfig) layer() AppConfig {
+	return AppConfig{
+		Dir:           pc.Dir,
+		PortMin:       pc.PortMin,
+		PortMax:       pc.PortMax,
+		PortBlockSize: pc.PortBlockSize,
+		PortRoles:     pc.Roles,
+		TLD:           pc.TLD,
+	}
+}
+
+// Project entry states reported by up, down and status.
+const (
+	StateOK      = "ok"      // exists as described
+	StateMissing = "missing" // not created yet
+	StateDrift   = "drift"   // exists but differs from the project file
+	StateCreated = "created"
+	StateUpdated = "updated"
+	StateRemoved = "removed"
+	StateSkipped = "skipped" // drifted entry left alone by down
+)
+
+// ProjectEntry compares one declared domain with its entry.
+type ProjectEntry struct {
+	Domain string `json:"domain"`
+	State  string `json:"state"`
+	Want   string `json:"want"`           // port, "auto", or link target
+	Have   string `json:"have,omitempty"` // current mapping or link target
+	Detail string `json:"detail,omitempty"`
+}
+
+// ProjectReport is the machine-readable result of up, down and status.
+type ProjectReport struct {
+	Project string         `json:"project"` // the project file
+	Dir     string         `json:"dir"`
+	Mode    string         `json:"mode"`
+	Entries []ProjectEntry `json:"entries"`
+}
+
+// Drifted counts entries that are missing or differ from the project file.
+func (r ProjectReport) Drifted() int {
+	n := 0
+	for _, e := range r.Entries {
+		if e.State == StateMissing || e.State == StateDrift {
+			n++
+		}
+	}
+	return n
+}
+
+// ProjectStatus compares the entries pc declares with entries. In port mode the first domain owns the block:
+// without a preferred port any port it has is fine, and the other domains must share it.
+func ProjectStatus(pc *ProjectConfig, dir string, entries []Entry) ProjectReport {
+	rep := ProjectReport{Project: pc.Path, Dir: dir, Mode: pc.Mode, Entries: []ProjectEntry{}}
+	byName := map[string]*Entry{}
+	for i := range entries {
+		byName[entries[i].Domain] = &entries[i]
+	}
+	want := pc.Link
+	if pc.Mode == ModePort {
+		want = "auto"
+		if pc.Port != 0 {
+			want = strconv.Itoa(pc.Port)
+		} else if e := byName[pc.Domains[0]]; e != nil && !e.IsSymlink {
+			if base, err := EntryBasePort(e); err == nil {
+				want = strconv.Itoa(base)
+			}
+		}
+	}
+	for _, d := range pc.Domains {
+		pe := ProjectEntry{Domain: d, State: StateMissing, Want: want}
+		if e := byName[d]; e != nil {
+			pe.State, pe.Have = StateOK, entryTarget(*e)
+			pe.Detail = entryDrift(pc, e, want)
+			if pe.Detail != "" {
+				pe.State = StateDrift
+			}
+		}
+		rep.Entries = append(rep.Entries, pe)
+	}
+	return rep
+}
+
+// AliasPort returns the port the project's other domains share in port mode: the base port of the primary
+// entry as it exists in dir, once it is ok, created or updated. A primary left drifted (or missing) has no
+// port to share; the error says why.
+func AliasPort(dir string, primary ProjectEntry) (string, error) {
+	switch primary.State {
+	case StateOK, StateCreated, StateUpdated:
+	case StateDrift:
+		return "", fmt.Errorf("%s %s", primary.Domain, primary.Detail)
+	default:
+		return "", fmt.Errorf("%s is %s", primary.Domain, primary.State)
+	}
+	e, err := ReadEntry(dir, primary.Domain)
+	if err != nil {
+		return "", err
+	}
+	base, err := EntryBasePort(e)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(base), nil
+}
+
+func entryDrift(pc *ProjectConfig, e *Entry, want string) string {
+	if pc.Mode == ModeLink {
+		if !e.IsSymlink {
+			return "is a port entry, want a symlink"
+		}
+		if filepath.Clean(e.LinkTarget) != want {
+			return fmt.Sprintf("links to %s", e.LinkTarget)
+		}
+		return ""
+	}
+	if e.IsSymlink {
+		return "is a symlink, want a port entry"
+	}
+	base, err := EntryBasePort(e)
+	if err != nil {
+		return err.Error()
+	}
+	if want != "auto" && strconv.Itoa(base) != want {
+		return fmt.Sprintf("on port %d", base)
+	}
+	return ""
+}
+
+// loadProjectLayer finds the project file for the working directory, if any.
+func loadProjectLayer() (*ProjectConfig, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, nil
+	}
+	pc, err := FindProjectConfig(wd)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return pc, nil
+}
+
+// RecordProject sets the project path in the metadata of domains that exist, so compaction reports and
+// `meta` point at the repo.
+func RecordProject(dir string, domains []string, root string) error {
+	return updateMeta(dir, func(s *MetaStore) bool {
+		changed := false
+		for _, d := range domains {
+			if !entryExists(dir, d) {
+				continue
+			}
+			m, _ := s.Get(d)
+			if m.Project != root {
+				m.Project = root
+				s.Set(d, m)
+				changed = true
+			}
+		}
+		return changed
+	})
+}
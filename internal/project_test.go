@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeProjectFile(t *testing.T, dir, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, ProjectFileNames[0])
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	tests := []struct {
+		name    string
+		body    string
+		mode    string
+		domains string
+		code    ErrorCode
+	}{
+		{"single domain", "domain: MyApp.test\nport: 36500\n", ModePort, "myapp", ""},
+		{"domain and aliases", "domain: myapp\ndomains: [admin.myapp]\n", ModePort, "myapp,admin.myapp", ""},
+		{"link implies mode", "domains: [myapp]\nlink: .\n", ModeLink, "myapp", ""},
+		{"no domain", "port: 36500\n", "", "", CodeConfig},
+		{"duplicate", "domains: [myapp, MYAPP]\n", "", "", CodeConfig},
+		{"port in link mode", "domain: myapp\nmode: link\nport: 36500\n", "", "", CodeConfig},
+		{"unknown key", "domain: myapp\nprot: 36500\n", "", "", CodeConfig},
+		{"invalid domain", "domain: my_app\n", "", "", CodeConfig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pc, err := LoadProjectConfig(writeProjectFile(t, root, tt.body))
+			if tt.code != "" {
+				if CodeOf(err) != tt.code {
+					t.Fatalf("expected %s, got %v", tt.code, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pc.Mode != tt.mode || strings.Join(pc.Domains, ",") != tt.domains {
+				t.Fatalf("got %s %q, want %s %q", pc.Mode, pc.Domains, tt.mode, tt.domains)
+			}
+			if pc.Mode == ModeLink && pc.Link != root {
+				t.Fatalf("link %q not resolved against %q", pc.Link, root)
+			}
+		})
+	}
+}
+
+func TestFindProjectConfig(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "app", "models")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if pc, err := FindProjectConfig(sub); err != nil || pc != nil {
+		t.Fatalf("expected no project, got %v, %v", pc, err)
+	}
+	writeProjectFile(t, root, "domain: myapp\ndir: ../mappings\n")
+	pc, err := FindProjectConfig(sub)
+	if err != nil || pc == nil {
+		t.Fatalf("expected the project in %s, got %v, %v", root, pc, err)
+	}
+	if pc.Root != root || pc.Dir != filepath.Join(filepath.Dir(root), "mappings") {
+		t.Fatalf("got root %q dir %q", pc.Root, pc.Dir)
+	}
+}
+
+func TestProjectStatus(t *testing.T) {
+	pc := &ProjectConfig{Domains: []string{"myapp", "admin.myapp", "api.myapp"}, Mode: ModePort}
+	entries := []Entry{
+		{Domain: "myapp", Mapping: "36010"},
+		{Domain: "admin.myapp", Mapping: "36000"},
+	}
+	rep := ProjectStatus(pc, "/dir", entries)
+	var states []string
+	for _, e := range rep.Entries {
+		states = append(states, e.State)
+	}
+	if got := strings.Join(states, ","); got != "ok,drift,missing" || rep.Entries[2].Want != "36010" {
+		t.Fatalf("got %s, want ok,drift,missing sharing 36010: %#v", got, rep.Entries)
+	}
+	if rep.Drifted() != 2 {
+		t.Fatalf("expected 2 drifted entries, got %d", rep.Drifted())
+	}
+
+	pc.Port = 36500
+	rep = ProjectStatus(pc, "/dir", entries)
+	if rep.Entries[0].State != StateDrift || rep.Entries[0].Detail != "on port 36010" {
+		t.Fatalf("expected the primary to drift from the preferred port, got %#v", rep.Entries[0])
+	}
+}
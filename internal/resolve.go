@@ -0,0 +1,213 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/jedib0t/go-pretty/v6/text"
+)
+
+// DefaultApp is the entry puma-dev serves for hosts that match no other entry.
+const DefaultApp = "default"
+
+// How a host was matched by ResolveHost.
+const (
+	MatchExact   = "exact"   // an entry named after the full host
+	MatchParent  = "parent"  // a less specific entry: admin.myapp.test falls back to myapp
+	MatchDefault = "default" // the "default" entry catches everything else
+	MatchNone    = "none"    // puma-dev answers 404
+)
+
+// ResolveStep is one candidate puma-dev tries for a host, most specific first.
+type ResolveStep struct {
+	Name  string `json:"name"`
+	Found bool   `json:"found"`
+}
+
+// Resolution explains which entry puma-dev would serve a host from.
+type Resolution struct {
+	Host   string        `json:"host"`
+	Name   string        `json:"name"` // host without the TLD
+	Match  string        `json:"match"`
+	Domain string        `json:"domain,omitempty"`
+	Entry  *Entry        `json:"entry,omitempty"`
+	Steps  []ResolveStep `json:"steps"`
+	Reason string        `json:"reason"`
+}
+
+// ResolveHost reproduces puma-dev's app lookup for host against entries: the TLD is dropped, then the name
+// and each parent (dropping the leftmost label) are tried in turn, and finally the "default" entry. A scheme,
+// port, path or trailing dot on host is ignored.
+func ResolveHost(entries []Entry, host, tld string) (*Resolution, error) {
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	h := strings.ToLower(strings.TrimSpace(host))
+	if i := strings.Index(h, "://"); i >= 0 {
+		h = h[i+3:]
+	}
+	if i := strings.IndexAny(h, "/?#"); i >= 0 {
+		h = h[:i]
+	}
+	if hp, _, err := net.SplitHostPort(h); err == nil {
+		h = hp
+	}
+	h = strings.TrimSuffix(h, ".")
+	name := strings.TrimSuffix(h, "."+tld)
+	if err := ValidateDomain(name); err != nil {
+		return nil, Errorf(CodeUsage, "invalid host %q: %v", host, err)
+	}
+	byName := make(map[string]*Entry, len(entries))
+	for i := range entries {
+		byName[entries[i].Domain] = &entries[i]
+	}
+	res := &Resolution{Host: name + "." + tld, Name: name, Match: MatchNone, Steps: []ResolveStep{}}
+	labels := strings.Split(name, ".")
+	for i := range labels {
+		candidate := strings.Join(labels[i:], ".")
+		e, ok := byName[candidate]
+		res.Steps = append(res.Steps, ResolveStep{Name: candidate, Found: ok})
+		if !ok {
+			continue
+		}
+		res.Domain, res.Entry = candidate, e
+		if i == 0 {
+			res.Match, res.Reason = MatchExact, fmt.Sprintf("entry %s matches the host exactly", candidate)
+		} else {
+			res.Match = MatchParent
+			res.Reason = fmt.Sprintf("no entry for %s; %s serves its subdomains", strings.Join(labels[:i], ".")+"."+candidate, candidate)
+		}
+		return res, nil
+	}
+	if e, ok := byName[DefaultApp]; ok && name != DefaultApp {
+		res.Steps = append(res.Steps, ResolveStep{Name: DefaultApp, Found: true})
+		res.Match, res.Domain, res.Entry = MatchDefault, DefaultApp, e
+		res.Reason = fmt.Sprintf("no entry for %s or its parents; the %s entry catches all other hosts", name, DefaultApp)
+		return res, nil
+	}
+	res.Reason = fmt.Sprintf("no entry for %s or its parents and no %s entry; puma-dev answers 404", name, DefaultApp)
+	return res, nil
+}
+
+// Subdomains returns the entries nested below domain (admin.myapp below myapp). puma-dev serves those hosts
+// from them instead of from domain.
+func Subdomains(entries []Entry, domain string) []string {
+	var out []string
+	for _, e := range entries {
+		if strings.HasSuffix(e.Domain, "."+domain) {
+			out = append(out, e.Domain)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// DomainNode is one name in the hierarchy implied by entry names, in depth-first order.
+type DomainNode struct {
+	Name   string `json:"name"`
+	Depth  int    `json:"depth"`
+	Entry  *Entry `json:"entry,omitempty"` // nil when no entry has this name
+	Served string `json:"served,omitempty"`
+}
+
+// DomainTree arranges entries by their dot-separated labels, right to left, so admin.myapp sits below myapp.
+// Intermediate names without an entry are included; Served names the entry puma-dev falls back to for them
+// (a parent, or the "default" entry).
+func DomainTree(entries []Entry) []DomainNode {
+	type node struct {
+		name     string
+		entry    *Entry
+		children map[string]*node
+	}
+	root := &node{children: map[string]*node{}}
+	for i := range entries {
+		labels := strings.Split(entries[i].Domain, ".")
+		n := root
+		for j := len(labels) - 1; j >= 0; j-- {
+			name := strings.Join(labels[j:], ".")
+			c := n.children[name]
+			if c == nil {
+				c = &node{name: name, children: map[string]*node{}}
+				n.children[name] = c
+			}
+			n = c
+		}
+		n.entry = &entries[i]
+	}
+	var out []DomainNode
+	var walk func(n *node, depth int, served string)
+	walk = func(n *node, depth int, served string) {
+		names := make([]string, 0, len(n.children))
+		for name := range n.children {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			c := n.children[name]
+			s := served
+			if c.entry != nil {
+				s = c.name
+			}
+			dn := DomainNode{Name: c.name, Depth: depth, Entry: c.entry}
+			if c.entry == nil {
+				dn.Served = served
+			}
+			out = append(out, dn)
+			walk(c, depth+1, s)
+		}
+	}
+	fallback := ""
+	if c := root.children[DefaultApp]; c != nil && c.entry != nil {
+		fallback = DefaultApp
+	}
+	walk(root, 0, fallback)
+	return out
+}
+
+// PrintDomainTree draws the nodes of DomainTree with box-drawing connectors.
+func PrintDomainTree(w io.Writer, nodes []DomainNode, tld string) {
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	for i, n := range nodes {
+		var prefix strings.Builder
+		for d := 1; d <= n.Depth; d++ {
+			more := hasSibling(nodes[i+1:], d) // does the branch at this depth continue below?
+			switch {
+			case d < n.Depth && more:
+				prefix.WriteString("│  ")
+			case d < n.Depth:
+				prefix.WriteString("   ")
+			case more:
+				prefix.WriteString("├─ ")
+			default:
+				prefix.WriteString("└─ ")
+			}
+		}
+		label := n.Name + "." + tld
+		switch {
+		case n.Entry != nil:
+			fmt.Fprintf(w, "%s%s → %s\n", prefix.String(), text.FgCyan.Sprint(label), entryTarget(*n.Entry))
+		case n.Served != "":
+			fmt.Fprintf(w, "%s%s\n", prefix.String(), text.FgHiBlack.Sprintf("%s (no entry, served by %s)", label, n.Served))
+		default:
+			fmt.Fprintf(w, "%s%s\n", prefix.String(), text.FgYellow.Sprintf("%s (no entry, 404)", label))
+		}
+	}
+}
+
+// hasSibling reports whether another node at depth follows before the tree climbs above it.
+func hasSibling(rest []DomainNode, depth int) bool {
+	for _, n := range rest {
+		if n.Depth < depth {
+			return false
+		}
+		if n.Depth == depth {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestResolveHost(t *testing.T) {
+	entries := []Entry{
+		{Domain: "myapp", Mapping: "36000"},
+		{Domain: "admin.myapp", Mapping: "36010"},
+		{Domain: "api.shop", Mapping: "36020"},
+	}
+	tests := []struct {
+		host   string
+		match  string
+		domain string
+		steps  int
+	}{
+		{"myapp.test", MatchExact, "myapp", 1},
+		{"admin.myapp.test", MatchExact, "admin.myapp", 1},
+		{"www.myapp.test", MatchParent, "myapp", 2},
+		{"v2.www.myapp.test", MatchParent, "myapp", 3},
+		{"x.admin.myapp.test", MatchParent, "admin.myapp", 2},
+		{"https://Admin.MyApp.test:443/login", MatchExact, "admin.myapp", 1},
+		{"myapp.test.", MatchExact, "myapp", 1},
+		{"shop.test", MatchNone, "", 1},
+		{"unknown.test", MatchNone, "", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			res, err := ResolveHost(entries, tt.host, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.Match != tt.match || res.Domain != tt.domain || len(res.Steps) != tt.steps {
+				t.Fatalf("got %s/%q with %d steps (%s), want %s/%q with %d", res.Match, res.Domain, len(res.Steps), res.Reason, tt.match, tt.domain, tt.steps)
+			}
+		})
+	}
+
+	withDefault := append(entries, Entry{Domain: DefaultApp, Mapping: "36090"})
+	res, _ := ResolveHost(withDefault, "unknown.test", "")
+	if res.Match != MatchDefault || res.Domain != DefaultApp {
+		t.Fatalf("expected the default entry, got %#v", res)
+	}
+	if _, err := ResolveHost(entries, "../etc.test", ""); CodeOf(err) != CodeUsage {
+		t.Fatalf("expected a usage error, got %v", err)
+	}
+}
+
+func TestDomainTree(t *testing.T) {
+	entries := []Entry{
+		{Domain: "myapp", Mapping: "36000"},
+		{Domain: "admin.myapp", Mapping: "36010"},
+		{Domain: "api.shop", Mapping: "36020"},
+	}
+	nodes := DomainTree(entries)
+	var got []string
+	for _, n := range nodes {
+		got = append(got, strings.Repeat(" ", n.Depth)+n.Name+":"+n.Served)
+	}
+	want := []string{"myapp:", " admin.myapp:", "shop:", " api.shop:"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	var buf bytes.Buffer
+	PrintDomainTree(&buf, nodes, "")
+	out := buf.String()
+	for _, s := range []string{"└─ ", "admin.myapp.test", "shop.test (no entry, 404)"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("tree output missing %q:\n%s", s, out)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EntryChange is the machine-readable result of create, update, delete and rename.
+type EntryChange struct {
+	Domain         string `json:"domain"`
+	Status         string `json:"status"`         // created, updated, deleted, renamed
+	Type           string `json:"type,omitempty"` // file or symlink
+	Mapping        string `json:"mapping,omitempty"`
+	LinkTarget     string `json:"link_target,omitempty"`
+	PreviousDomain string `json:"previous_domain,omitempty"`
+}
+
+// CleanupItem is one entry considered by cleanup.
+type CleanupItem struct {
+	Entry
+	Status string `json:"status"` // pending (dry run or not confirmed), deleted, failed
+	Error  string `json:"error,omitempty"`
+}
+
+// CleanupResult is the machine-readable result of cleanup.
+type CleanupResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Entries []CleanupItem `json:"entries"`
+}
+
+// DomainMeta pairs a domain with its metadata for output.
+type DomainMeta struct {
+	Domain string `json:"domain"`
+	Meta
+}
+
+// EntryColumns are the tabular columns for entries.
+func EntryColumns() []Column[Entry] {
+	return []Column[Entry]{
+		{Header: "Domain", Value: Entry.QualifiedDomain},
+		{Header: "Type", Value: entryType},
+		{Header: "Target", Value: entryTarget},
+		{Header: "Tags", Wide: true, Value: func(e Entry) string { return metaField(e.Meta, "tags") }},
+		{Header: "Owner", Wide: true, Value: func(e Entry) string { return metaField(e.Meta, "owner") }},
+		{Header: "Project", Wide: true, Value: func(e Entry) string { return metaField(e.Meta, "project") }},
+	}
+}
+
+// EntryName returns the domain (with its context when listing several), used by -o name.
+func EntryName(e Entry) string { return e.QualifiedDomain() }
+
+// ValidationColumns are the tabular columns for validation results.
+func ValidationColumns() []Column[ValidationResult] {
+	return []Column[ValidationResult]{
+		{Header: "Domain", Value: func(r ValidationResult) string { return r.QualifiedDomain() }},
+		{Header: "Type", Value: func(r ValidationResult) string { return entryType(r.Entry) }},
+		{Header: "Target", Value: func(r ValidationResult) string { return entryTarget(r.Entry) }},
+		{Header: "Reachable", Value: func(r ValidationResult) string { return strconv.FormatBool(r.Reachable) }},
+		{Header: "Reason", Value: func(r ValidationResult) string { return r.Reason }},
+		{Header: "TLS Subject", Wide: true, Value: func(r ValidationResult) string {
+			if r.TLS == nil {
+				return ""
+			}
+			return r.TLS.Subject
+		}},
+		{Header: "TLS Expires", Wide: true, Value: func(r ValidationResult) string {
+			if r.TLS == nil || r.TLS.NotAfter == nil {
+				return ""
+			}
+			return r.TLS.NotAfter.Format("2006-01-02")
+		}},
+		{Header: "TLS Verified", Wide: true, Value: func(r ValidationResult) string {
+			if r.TLS == nil {
+				return ""
+			}
+			return strconv.FormatBool(r.TLS.Verified)
+		}},
+	}
+}
+
+// ValidationName returns the domain (with its context when validating several), used by -o name.
+func ValidationName(r ValidationResult) string { return r.QualifiedDomain() }
+
+// EntryChangeColumns are the tabular columns for mutation results.
+func EntryChangeColumns() []Column[EntryChange] {
+	return []Column[EntryChange]{
+		{Header: "Domain", Value: func(c EntryChange) string { return c.Domain }},
+		{Header: "Status", Value: func(c EntryChange) string { return c.Status }},
+		{Header: "Type", Value: func(c EntryChange) string { return c.Type }},
+		{Header: "Target", Value: func(c EntryChange) string {
+			if c.LinkTarget != "" {
+				return c.LinkTarget
+			}
+			return c.Mapping
+		}},
+		{Header: "Previous", Wide: true, Value: func(c EntryChange) string { return c.PreviousDomain }},
+	}
+}
+
+// EntryChangeView renders a single mutation result.
+func EntryChangeView(c EntryChange) View[EntryChange] {
+	return View[EntryChange]{
+		Kind:    KindEntryChange,
+		Doc:     c,
+		Items:   []EntryChange{c},
+		Columns: EntryChangeColumns(),
+		Name:    func(c EntryChange) string { return c.Domain },
+	}
+}
+
+// EntryView renders a single entry.
+func EntryView(e Entry) View[Entry] {
+	return View[Entry]{
+		Kind:    KindEntry,
+		Doc:     e,
+		Items:   []Entry{e},
+		Columns: EntryColumns(),
+		Name:    EntryName,
+	}
+}
+
+// ValidationView renders validation results.
+func ValidationView(results []ValidationResult) View[ValidationResult] {
+	if results == nil {
+		results = []ValidationResult{}
+	}
+	return View[ValidationResult]{
+		Kind:    KindValidationResultList,
+		Doc:     results,
+		Items:   results,
+		Columns: ValidationColumns(),
+		Name:    ValidationName,
+	}
+}
+
+// CleanupView renders the result of cleanup.
+func CleanupView(res CleanupResult) View[CleanupItem] {
+	if res.Entries == nil {
+		res.Entries = []CleanupItem{}
+	}
+	return View[CleanupItem]{
+		Kind:    KindCleanupResult,
+		Doc:     res,
+		Items:   res.Entries,
+		Columns: CleanupColumns(),
+		Name:    func(c CleanupItem) string { return c.QualifiedDomain() },
+	}
+}
+
+// CleanupColumns are the tabular columns for cleanup results.
+func CleanupColumns() []Column[CleanupItem] {
+	return []Column[CleanupItem]{
+		{Header: "Domain", Value: func(c CleanupItem) string { return c.QualifiedDomain() }},
+		{Header: "Mapping", Value: func(c CleanupItem) string { return c.Mapping }},
+		{Header: "Status", Value: func(c CleanupItem) string { return c.Status }},
+		{Header: "Error", Value: func(c CleanupItem) string { return c.Error }},
+	}
+}
+
+// DomainMetaView renders the metadata of one domain.
+func DomainMetaView(dm DomainMeta) View[DomainMeta] {
+	return View[DomainMeta]{
+		Kind:  KindDomainMeta,
+		Doc:   dm,
+		Items: []DomainMeta{dm},
+		Columns: []Column[DomainMeta]{
+			{Header: "Domain", Value: func(d DomainMeta) string { return d.Domain }},
+			{Header: "Tags", Value: func(d DomainMeta) string { return strings.Join(d.Tags, ",") }},
+			{Header: "Owner", Value: func(d DomainMeta) string { return d.Owner }},
+			{Header: "Project", Value: func(d DomainMeta) string { return d.Project }},
+			{Header: "Note", Value: func(d DomainMeta) string { return d.Note }},
+		},
+		Name: func(d DomainMeta) string { return d.Domain },
+	}
+}
+
+// CertColumns are the tabular columns for certificates.
+func CertColumns() []Column[CertInfo] {
+	return []Column[CertInfo]{
+		{Header: "Domain", Value: func(c CertInfo) string { return c.Domain }},
+		{Header: "Subject", Value: func(c CertInfo) string { return c.Subject }},
+		{Header: "Names", Value: func(c CertInfo) string { return strings.Join(c.DNSNames, ",") }},
+		{Header: "Expires", Value: func(c CertInfo) string { return c.NotAfter.Format("2006-01-02") }},
+		{Header: "Cert", Wide: true, Value: func(c CertInfo) string { return c.CertPath }},
+		{Header: "Key", Wide: true, Value: func(c CertInfo) string { return c.KeyPath }},
+	}
+}
+
+// CertView renders certificates; single renders Doc as one object rather than a list.
+func CertView(certs []CertInfo, single bool) View[CertInfo] {
+	if certs == nil {
+		certs = []CertInfo{}
+	}
+	kind, doc := KindCertInfoList, any(certs)
+	if single && len(certs) == 1 {
+		kind, doc = KindCertInfo, certs[0]
+	}
+	return View[CertInfo]{
+		Kind:    kind,
+		Doc:     doc,
+		Items:   certs,
+		Columns: CertColumns(),
+		Name: func(c CertInfo) string {
+			if c.Domain != "" {
+				return c.Domain
+			}
+			return c.Subject
+		},
+	}
+}
+
+// PortAssignmentView renders the role → port table of a domain.
+func PortAssignmentView(ports []PortAssignment) View[PortAssignment] {
+	if ports == nil {
+		ports = []PortAssignment{}
+	}
+	return View[PortAssignment]{
+		Kind:  KindPortAssignmentList,
+		Doc:   ports,
+		Items: ports,
+		Columns: []Column[PortAssignment]{
+			{Header: "Role", Value: func(p PortAssignment) string { return p.Role }},
+			{Header: "Port", Value: func(p PortAssignment) string { return strconv.Itoa(p.Port) }},
+			{Header: "Offset", Value: func(p PortAssignment) string { return "+" + strconv.Itoa(p.Offset) }},
+			{Header: "Env", Value: func(p PortAssignment) string { return p.Env }},
+			{Header: "Domain", Wide: true, Value: func(p PortAssignment) string { return p.Domain }},
+		},
+		Name: func(p PortAssignment) string { return p.Role },
+	}
+}
+
+// LeaseView renders ledger leases; single renders Doc as one object rather than a list.
+func LeaseView(leases []Lease, single bool) View[Lease] {
+	if leases == nil {
+		leases = []Lease{}
+	}
+	kind, doc := KindLeaseList, any(leases)
+	if single && len(leases) == 1 {
+		kind, doc = KindLease, leases[0]
+	}
+	return View[Lease]{
+		Kind:  kind,
+		Doc:   doc,
+		Items: leases,
+		Columns: []Column[Lease]{
+			{Header: "Domain", Value: func(l Lease) string { return l.Domain }},
+			{Header: "Ports", Value: func(l Lease) string { return strconv.Itoa(l.Base) + "-" + strconv.Itoa(l.End()) }},
+			{Header: "Updated", Value: func(l Lease) string { return l.UpdatedAt.Format("2006-01-02") }},
+			{Header: "Created", Wide: true, Value: func(l Lease) string { return l.CreatedAt.Format("2006-01-02") }},
+			{Header: "Dir", Wide: true, Value: func(l Lease) string { return l.Dir }},
+		},
+		Name: func(l Lease) string { return l.Domain },
+	}
+}
+
+// CompactView renders a compaction plan; records are the individual moves.
+func CompactView(plan CompactPlan) View[PortMove] {
+	if plan.Moves == nil {
+		plan.Moves = []PortMove{}
+	}
+	return View[PortMove]{
+		Kind:  KindCompactPlan,
+		Doc:   plan,
+		Items: plan.Moves,
+		Columns: []Column[PortMove]{
+			{Header: "Domain", Value: func(m PortMove) string { return m.Domain }},
+			{Header: "From", Value: func(m PortMove) string { return strconv.Itoa(m.From) }},
+			{Header: "To", Value: func(m PortMove) string { return strconv.Itoa(m.To) }},
+			{Header: "Mapping", Wide: true, Value: func(m PortMove) string { return m.NewMapping }},
+			{Header: "Project", Wide: true, Value: func(m PortMove) string { return m.Project }},
+		},
+		Name: func(m PortMove) string { return m.Domain },
+	}
+}
+
+// PortMapView renders the port map; records are the blocks.
+func PortMapView(pm PortMap) View[PortBlock] {
+	return View[PortBlock]{
+		Kind:  KindPortMap,
+		Doc:   pm,
+		Items: pm.Blocks,
+		Columns: []Column[PortBlock]{
+			{Header: "Ports", Value: func(b PortBlock) string { return strconv.Itoa(b.Base) + "-" + strconv.Itoa(b.End) }},
+			{Header: "State", Value: func(b PortBlock) string { return b.State }},
+			{Header: "Domains", Value: func(b PortBlock) string { return strings.Join(b.Domains, ",") }},
+			{Header: "Detail", Wide: true, Value: func(b PortBlock) string { return b.Detail }},
+		},
+		Name: func(b PortBlock) string { return strconv.Itoa(b.Base) },
+	}
+}
+
+// LintView renders the issues of a lint report; -o json keeps the counters.
+func LintView(rep LintReport) View[LintIssue] {
+	return View[LintIssue]{
+		Kind:  KindLintReport,
+		Doc:   rep,
+		Items: rep.Issues,
+		Columns: []Column[LintIssue]{
+			{Header: "Severity", Value: func(is LintIssue) string { return is.Severity }},
+			{Header: "Rule", Value: func(is LintIssue) string { return is.Rule }},
+			{Header: "Domains", Value: func(is LintIssue) string { return strings.Join(is.Domains, ",") }},
+			{Header: "Message", Value: func(is LintIssue) string { return is.Message }},
+			{Header: "Fix", Wide: true, Value: func(is LintIssue) string { return is.Fix }},
+			{Header: "Fixed", Wide: true, Value: func(is LintIssue) string { return strconv.FormatBool(is.Fixed) }},
+		},
+		Name: func(is LintIssue) string { return is.Rule },
+	}
+}
+
+// ResolutionView renders the lookup steps of resolve; -o json keeps the whole explanation.
+func ResolutionView(res Resolution) View[ResolveStep] {
+	return View[ResolveStep]{
+		Kind:  KindResolution,
+		Doc:   res,
+		Items: res.Steps,
+		Columns: []Column[ResolveStep]{
+			{Header: "Candidate", Value: func(s ResolveStep) string { return s.Name }},
+			{Header: "Found", Value: func(s ResolveStep) string { return strconv.FormatBool(s.Found) }},
+		},
+		Name: func(s ResolveStep) string { return s.Name },
+	}
+}
+
+// OpenView renders the result of open.
+func OpenView(res OpenResult) View[OpenResult] {
+	return View[OpenResult]{
+		Kind:  KindOpenResult,
+		Doc:   res,
+		Items: []OpenResult{res},
+		Columns: []Column[OpenResult]{
+			{Header: "URL", Value: func(o OpenResult) string { return o.URL }},
+			{Header: "Domain", Value: func(o OpenResult) string { return o.Domain }},
+			{Header: "Launched", Value: func(o OpenResult) string { return strconv.FormatBool(o.Launched) }},
+			{Header: "Launcher", Wide: true, Value: func(o OpenResult) string { return o.Launcher }},
+		},
+		Name: func(o OpenResult) string { return o.URL },
+	}
+}
+
+// ProjectView renders the declared entries of a project file and their state.
+func ProjectView(rep ProjectReport) View[ProjectEntry] {
+	return View[ProjectEntry]{
+		Kind:  KindProjectReport,
+		Doc:   rep,
+		Items: rep.Entries,
+		Columns: []Column[ProjectEntry]{
+			{Header: "Domain", Value: func(e ProjectEntry) string { return e.Domain }},
+			{Header: "State", Value: func(e ProjectEntry) string { return e.State }},
+			{Header: "Want", Value: func(e ProjectEntry) string { return e.Want }},
+			{Header: "Have", Value: func(e ProjectEntry) string { return e.Have }},
+			{Header: "Detail", Wide: true, Value: func(e ProjectEntry) string { return e.Detail }},
+		},
+		Name: func(e ProjectEntry) string { return e.Domain },
+	}
+}
+
+// ConfigView renders effective config values; with origin the Origin column is shown in table output too.
+func ConfigView(values []ConfigValue, origin bool) View[ConfigValue] {
+	return View[ConfigValue]{
+		Kind:  KindConfigValueList,
+		Doc:   values,
+		Items: values,
+		Columns: []Column[ConfigValue]{
+			{Header: "Key", Value: func(v ConfigValue) string { return v.Key }},
+			{Header: "Value", Value: func(v ConfigValue) string { return v.Value }},
+			{Header: "Origin", Wide: !origin, Value: func(v ConfigValue) string { return ConfigOrigin(v) }},
+		},
+		Name: func(v ConfigValue) string { return v.Key },
+	}
+}
+
+// ConfigOrigin describes where a value came from, e.g. "user profile work (~/.config/pumadevctl/config.json)".
+func ConfigOrigin(v ConfigValue) string {
+	s := v.Origin
+	if v.Profile != "" {
+		s += " profile " + v.Profile
+	}
+	if v.Source != "" {
+		s += " (" + v.Source + ")"
+	}
+	return s
+}
+
+// ConfigValueView renders a single config value (config get).
+func ConfigValueView(v ConfigValue) View[ConfigValue] {
+	view := ConfigView([]ConfigValue{v}, true)
+	view.Kind, view.Doc = KindConfigValue, v
+	return view
+}
+
+// ConfigChangeView renders the result of config set and unset.
+func ConfigChangeView(c ConfigChange) View[ConfigChange] {
+	return View[ConfigChange]{
+		Kind:  KindConfigChange,
+		Doc:   c,
+		Items: []ConfigChange{c},
+		Columns: []Column[ConfigChange]{
+			{Header: "Key", Value: func(c ConfigChange) string { return c.Key }},
+			{Header: "Status", Value: func(c ConfigChange) string { return c.Status }},
+			{Header: "Value", Value: func(c ConfigChange) string { return c.Value }},
+			{Header: "Previous", Value: func(c ConfigChange) string { return c.Previous }},
+			{Header: "File", Wide: true, Value: func(c ConfigChange) string { return c.File }},
+		},
+		Name: func(c ConfigChange) string { return c.Key },
+	}
+}
+
+// ConfigReportView renders the issues found by config validate.
+func ConfigReportView(rep ConfigReport) View[ConfigIssue] {
+	return View[ConfigIssue]{
+		Kind:  KindConfigReport,
+		Doc:   rep,
+		Items: rep.Issues,
+		Columns: []Column[ConfigIssue]{
+			{Header: "Key", Value: func(i ConfigIssue) string { return i.Key }},
+			{Header: "Severity", Value: func(i ConfigIssue) string { return i.Severity }},
+			{Header: "Message", Value: func(i ConfigIssue) string { return i.Message }},
+			{Header: "Origin", Wide: true, Value: func(i ConfigIssue) string { return i.Origin }},
+		},
+		Name: func(i ConfigIssue) string { return i.Key },
+	}
+}
+
+// ConfigFileView renders a config file's path and state (config path, init and edit).
+func ConfigFileView(info ConfigFileInfo) View[ConfigFileInfo] {
+	return View[ConfigFileInfo]{
+		Kind:  KindConfigFileInfo,
+		Doc:   info,
+		Items: []ConfigFileInfo{info},
+		Columns: []Column[ConfigFileInfo]{
+			{Header: "Path", Value: func(i ConfigFileInfo) string { return i.Path }},
+			{Header: "Layer", Value: func(i ConfigFileInfo) string { return i.Layer }},
+			{Header: "Exists", Value: func(i ConfigFileInfo) string { return strconv.FormatBool(i.Exists) }},
+		},
+		Name: func(i ConfigFileInfo) string { return i.Path },
+	}
+}
+
+// ContextView renders the declared contexts.
+func ContextView(contexts []ContextInfo) View[ContextInfo] {
+	if contexts == nil {
+		contexts = []ContextInfo{}
+	}
+	return View[ContextInfo]{
+		Kind:  KindContextInfoList,
+		Doc:   contexts,
+		Items: contexts,
+		Columns: []Column[ContextInfo]{
+			{Header: "Current", Value: func(c ContextInfo) string {
+				if c.Current {
+					return "*"
+				}
+				return ""
+			}},
+			{Header: "Name", Value: func(c ContextInfo) string { return c.Name }},
+			{Header: "Dir", Value: func(c ContextInfo) string { return c.Dir }},
+			{Header: "Entries", Value: func(c ContextInfo) string {
+				if !c.Exists {
+					return "(missing)"
+				}
+				return strconv.Itoa(c.Entries)
+			}},
+		},
+		Name: func(c ContextInfo) string { return c.Name },
+	}
+}
+
+// PluginView renders the plugins found by plugin list.
+func PluginView(plugins []Plugin) View[Plugin] {
+	if plugins == nil {
+		plugins = []Plugin{}
+	}
+	return View[Plugin]{
+		Kind:  KindPluginList,
+		Doc:   plugins,
+		Items: plugins,
+		Columns: []Column[Plugin]{
+			{Header: "Name", Value: func(p Plugin) string { return p.Name }},
+			{Header: "Path", Value: func(p Plugin) string { return p.Path }},
+			{Header: "Status", Value: func(p Plugin) string { return p.Status }},
+			{Header: "Hidden", Wide: true, Value: func(p Plugin) string { return strings.Join(p.Hidden, ", ") }},
+		},
+		Name: func(p Plugin) string { return p.Name },
+	}
+}
+
+// CompletionInstallView renders an installed completion script.
+func CompletionInstallView(c CompletionInstall) View[CompletionInstall] {
+	return View[CompletionInstall]{
+		Kind:  KindCompletionInstall,
+		Doc:   c,
+		Items: []CompletionInstall{c},
+		Columns: []Column[CompletionInstall]{
+			{Header: "Shell", Value: func(c CompletionInstall) string { return c.Shell }},
+			{Header: "Path", Value: func(c CompletionInstall) string { return c.Path }},
+			{Header: "Hint", Wide: true, Value: func(c CompletionInstall) string { return c.Hint }},
+		},
+		Name: func(c CompletionInstall) string { return c.Path },
+	}
+}
+
+func entryTarget(e Entry) string {
+	if e.IsSymlink {
+		return e.LinkTarget
+	}
+	return e.Mapping
+}
+
+func metaField(m *Meta, field string) string {
+	if m == nil {
+		return ""
+	}
+	switch field {
+	case "tags":
+		return strings.Join(m.Tags, ",")
+	case "owner":
+		return m.Owner
+	case "project":
+		return m.Project
+	}
+	return ""
+}
@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ExecEnv returns base (KEY=VALUE pairs, usually os.Environ()) with vars set, replacing existing values.
+func ExecEnv(base []string, vars map[string]string) []string {
+	out := make([]string, 0, len(base)+len(vars))
+	for _, kv := range base {
+		k, _, _ := strings.Cut(kv, "=")
+		if _, ok := vars[k]; !ok {
+			out = append(out, kv)
+		}
+	}
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out = append(out, k+"="+vars[k])
+	}
+	return out
+}
+
+// AppEnv is the environment exec gives an app: PUMADEV_DOMAIN, PUMADEV_HOST, PORT and one variable per
+// port role. PORT is always the entry's port, the one puma-dev proxies to, even when no role sits at +0.
+func AppEnv(domain, tld string, port int, ports []PortAssignment) map[string]string {
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	vars := map[string]string{
+		"PUMADEV_DOMAIN": domain,
+		"PUMADEV_HOST":   domain + "." + tld,
+		"PORT":           fmt.Sprint(port),
+	}
+	for _, p := range ports {
+		vars[p.Env] = fmt.Sprint(p.Port)
+	}
+	return vars
+}
+
+// Process is a started child whose signals are forwarded until it exits.
+type Process struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error
+}
+
+// StartProcess starts c and forwards the signals pumadevctl receives to it until it exits. Signals a terminal
+// already delivers to the whole foreground group (Ctrl-C, Ctrl-\) are only forwarded when stdin is not a
+// terminal, so the app never sees them twice.
+func StartProcess(c *exec.Cmd) (*Process, error) {
+	sigs := make(chan os.Signal, 4)
+	signal.Notify(sigs, forwardedSignals...)
+	if err := c.Start(); err != nil {
+		signal.Stop(sigs)
+		return nil, err
+	}
+	p := &Process{cmd: c, done: make(chan struct{})}
+	interactive := isTerminal(os.Stdin)
+	go func() {
+		for {
+			select {
+			case s := <-sigs:
+				if interactive && terminalSignal(s) {
+					continue
+				}
+				_ = c.Process.Signal(s)
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	go func() {
+		p.err = c.Wait()
+		signal.Stop(sigs)
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// Done is closed when the process has exited.
+func (p *Process) Done() <-chan struct{} { return p.done }
+
+// Stop asks the process to terminate (SIGTERM on unix).
+func (p *Process) Stop() error { return stopProcess(p.cmd.Process) }
+
+// Wait blocks until the process exits and returns its exit status; a process killed by a signal reports
+// 128+signal like a shell. The error is only set when the status could not be determined.
+func (p *Process) Wait() (int, error) {
+	<-p.done
+	if p.cmd.ProcessState == nil {
+		return 1, p.err
+	}
+	return exitStatus(p.cmd.ProcessState), nil
+}
+
+// WaitReachable polls host:port with IsPortReachable until it accepts connections, timeout passes, or exited
+// is closed (the app died before it was ready).
+func WaitReachable(host string, port int, timeout time.Duration, exited <-chan struct{}) error {
+	deadline := time.Now().Add(timeout)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		if IsPortReachable(host, port, 200*time.Millisecond) {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return Errorf(CodeValidationFailed, "%s not reachable after %s", hostPort(host, port), timeout)
+		}
+		select {
+		case <-exited:
+			return Errorf(CodeValidationFailed, "process exited before %s was reachable", hostPort(host, port))
+		case <-tick.C:
+		}
+	}
+}
+
+func hostPort(host string, port int) string {
+	if strings.Contains(host, ":") {
+		return fmt.Sprintf("[%s]:%d", host, port)
+	}
+	return fmt.Sprintf("%s:%d", host, port)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package internal
+
+import "os"
+
+// Only Ctrl-C can be observed elsewhere; the console delivers it to the child itself, so it is never forwarded.
+var forwardedSignals = []os.Signal{os.Interrupt}
+
+func terminalSignal(os.Signal) bool { return true }
+
+func stopProcess(p *os.Process) error { return p.Kill() }
+
+func exitStatus(ps *os.ProcessState) int { return ps.ExitCode() }
@@ -0,0 +1,63 @@
+package internal
+
+import (
+	"net"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExecEnv(t *testing.T) {
+	ports := []PortAssignment{{Role: "web", Port: 36000, Env: "PORT"}, {Role: "vite", Offset: 1, Port: 36001, Env: "VITE_PORT"}}
+	env := ExecEnv([]string{"HOME=/home/me", "PORT=3000", "PATH=/bin"}, AppEnv("myapp", "", 36000, ports))
+	want := "HOME=/home/me PATH=/bin PORT=36000 PUMADEV_DOMAIN=myapp PUMADEV_HOST=myapp.test VITE_PORT=36001"
+	if got := strings.Join(env, " "); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestStartProcess_ExitStatus(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	p, err := StartProcess(exec.Command("sh", "-c", "exit 3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, err := p.Wait(); err != nil || status != 3 {
+		t.Fatalf("expected status 3, got %d, %v", status, err)
+	}
+
+	p, err = StartProcess(exec.Command("sh", "-c", "sleep 5"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := p.Wait(); status == 0 {
+		t.Fatalf("expected a non-zero status after Stop, got %d", status)
+	}
+}
+
+func TestWaitReachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	if err := WaitReachable("127.0.0.1", port, time.Second, nil); err != nil {
+		t.Fatalf("listening port not reachable: %v", err)
+	}
+	ln.Close()
+
+	exited := make(chan struct{})
+	close(exited)
+	if err := WaitReachable("127.0.0.1", port, time.Minute, exited); CodeOf(err) != CodeValidationFailed {
+		t.Fatalf("expected validation_failed once the process exited, got %v", err)
+	}
+	if err := WaitReachable("127.0.0.1", port, 150*time.Millisecond, nil); CodeOf(err) != CodeValidationFailed {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+}
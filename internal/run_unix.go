@@ -0,0 +1,22 @@
+//go:build unix
+
+package internal
+
+import (
+	"os"
+	"syscall"
+)
+
+var forwardedSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2}
+
+// terminalSignal reports whether a terminal sends s to the whole foreground process group.
+func terminalSignal(s os.Signal) bool { return s == syscall.SIGINT || s == syscall.SIGQUIT }
+
+func stopProcess(p *os.Process) error { return p.Signal(syscall.SIGTERM) }
+
+func exitStatus(ps *os.ProcessState) int {
+	if ws, ok := ps.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return ps.ExitCode()
+}
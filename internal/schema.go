@@ -0,0 +1,310 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SchemaVersion is the version of the machine-readable output documents. It is bumped on
+// breaking changes (removed or renamed fields, changed types); adding fields is not breaking.
+const SchemaVersion = 1
+
+// Document kinds, recorded as "kind" next to "schema_version" in json and yaml output.
+const (
+	KindListGroupList        = "ListGroupList"
+	KindEntry                = "Entry"
+	KindValidationResultList = "ValidationResultList"
+	KindEntryChange          = "EntryChange"
+	KindCleanupResult        = "CleanupResult"
+	KindDomainMeta           = "DomainMeta"
+	KindCertInfo             = "CertInfo"
+	KindCertInfoList         = "CertInfoList"
+	KindPortAssignmentList   = "PortAssignmentList"
+	KindLease                = "Lease"
+	KindLeaseList            = "LeaseList"
+	KindCompactPlan          = "CompactPlan"
+	KindPortMap              = "PortMap"
+	KindLintReport           = "LintReport"
+	KindResolution           = "Resolution"
+	KindOpenResult           = "OpenResult"
+	KindProjectReport        = "ProjectReport"
+	KindConfigValueList      = "ConfigValueList"
+	KindConfigValue          = "ConfigValue"
+	KindConfigChange         = "ConfigChange"
+	KindConfigReport         = "ConfigReport"
+	KindConfigFileInfo       = "ConfigFileInfo"
+	KindContextInfoList      = "ContextInfoList"
+	KindPluginList           = "PluginList"
+	KindCompletionInstall    = "CompletionInstall"
+)
+
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// OutputSchema describes the json document a command prints and the records it emits in jsonl.
+type OutputSchema struct {
+	Command string
+	Kind    string
+	Item    any  // zero value of the document's item (List) or of the document itself
+	List    bool // document wraps items in "items" rather than being a single object
+	Record  any  // zero value of a jsonl record when it differs from Item
+}
+
+var outputSchemas = []OutputSchema{
+	{Command: "list", Kind: KindListGroupList, Item: ListGroup{}, List: true, Record: Entry{}},
+	{Command: "read", Kind: KindEntry, Item: Entry{}},
+	{Command: "validate", Kind: KindValidationResultList, Item: ValidationResult{}, List: true},
+	{Command: "create", Kind: KindEntryChange, Item: EntryChange{}},
+	{Command: "update", Kind: KindEntryChange, Item: EntryChange{}},
+	{Command: "delete", Kind: KindEntryChange, Item: EntryChange{}},
+	{Command: "rename", Kind: KindEntryChange, Item: EntryChange{}},
+	{Command: "cleanup", Kind: KindCleanupResult, Item: CleanupResult{}, Record: CleanupItem{}},
+	{Command: "meta", Kind: KindDomainMeta, Item: DomainMeta{}},
+	{Command: "tag", Kind: KindDomainMeta, Item: DomainMeta{}},
+	{Command: "note", Kind: KindDomainMeta, Item: DomainMeta{}},
+	{Command: "cert ca init", Kind: KindCertInfo, Item: CertInfo{}},
+	{Command: "cert issue", Kind: KindCertInfo, Item: CertInfo{}},
+	{Command: "cert list", Kind: KindCertInfoList, Item: CertInfo{}, List: true},
+	{Command: "ports", Kind: KindPortAssignmentList, Item: PortAssignment{}, List: true},
+	{Command: "ports reserve", Kind: KindLease, Item: Lease{}},
+	{Command: "ports release", Kind: KindLease, Item: Lease{}},
+	{Command: "ports gc", Kind: KindLeaseList, Item: Lease{}, List: true},
+	{Command: "ports leases", Kind: KindLeaseList, Item: Lease{}, List: true},
+	{Command: "ports compact", Kind: KindCompactPlan, Item: CompactPlan{}, Record: PortMove{}},
+	{Command: "ports map", Kind: KindPortMap, Item: PortMap{}, Record: PortBlock{}},
+	{Command: "lint", Kind: KindLintReport, Item: LintReport{}, Record: LintIssue{}},
+	{Command: "resolve", Kind: KindResolution, Item: Resolution{}, Record: ResolveStep{}},
+	{Command: "open", Kind: KindOpenResult, Item: OpenResult{}},
+	{Command: "up", Kind: KindProjectReport, Item: ProjectReport{}, Record: ProjectEntry{}},
+	{Command: "down", Kind: KindProjectReport, Item: ProjectReport{}, Record: ProjectEntry{}},
+	{Command: "status", Kind: KindProjectReport, Item: ProjectReport{}, Record: ProjectEntry{}},
+	{Command: "config show", Kind: KindConfigValueList, Item: ConfigValue{}, List: true},
+	{Command: "config get", Kind: KindConfigValue, Item: ConfigValue{}},
+	{Command: "config set", Kind: KindConfigChange, Item: ConfigChange{}},
+	{Command: "config unset", Kind: KindConfigChange, Item: ConfigChange{}},
+	{Command: "config validate", Kind: KindConfigReport, Item: ConfigReport{}, Record: ConfigIssue{}},
+	{Command: "config path", Kind: KindConfigFileInfo, Item: ConfigFileInfo{}},
+	{Command: "config init", Kind: KindConfigFileInfo, Item: ConfigFileInfo{}},
+	{Command: "config edit", Kind: KindConfigFileInfo, Item: ConfigFileInfo{}},
+	{Command: "context list", Kind: KindContextInfoList, Item: ContextInfo{}, List: true},
+	{Command: "context add", Kind: KindConfigChange, Item: ConfigChange{}},
+	{Command: "context use", Kind: KindConfigChange, Item: ConfigChange{}},
+	{Command: "plugin list", Kind: KindPluginList, Item: Plugin{}, List: true},
+	{Command: "completion install", Kind: KindCompletionInstall, Item: CompletionInstall{}},
+}
+
+// OutputSchemas returns the registered command output schemas sorted by command.
+func OutputSchemas() []OutputSchema {
+	out := append([]OutputSchema(nil), outputSchemas...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Command < out[j].Command })
+	return out
+}
+
+// LookupOutputSchema finds the schema registered for command (e.g. "list" or "cert issue").
+func LookupOutputSchema(command string) (OutputSchema, error) {
+	command = strings.Join(strings.Fields(command), " ")
+	for _, s := range outputSchemas {
+		if s.Command == command {
+			return s, nil
+		}
+	}
+	return OutputSchema{}, fmt.Errorf("no schema for command %q", command)
+}
+
+// DocumentSchema returns the JSON Schema (draft 2020-12) of the json/yaml document, including the envelope.
+func (s OutputSchema) DocumentSchema() map[string]any {
+	envelope := map[string]any{
+		"schema_version": map[string]any{"const": SchemaVersion},
+		"kind":           map[string]any{"const": s.Kind},
+	}
+	var doc map[string]any
+	if s.List {
+		envelope["items"] = map[string]any{"type": "array", "items": schemaOf(reflect.TypeOf(s.Item))}
+		doc = map[string]any{
+			"type":                 "object",
+			"properties":           envelope,
+			"required":             []string{"schema_version", "kind", "items"},
+			"additionalProperties": false,
+		}
+	} else {
+		doc = schemaOf(reflect.TypeOf(s.Item))
+		props := doc["properties"].(map[string]any)
+		for k, v := range envelope {
+			props[k] = v
+		}
+		doc["required"] = append([]string{"schema_version", "kind"}, doc["required"].([]string)...)
+	}
+	doc["$schema"] = jsonSchemaDialect
+	doc["title"] = "pumadevctl " + s.Command + " output"
+	return doc
+}
+
+// ItemSchema returns the JSON Schema (draft 2020-12) of one jsonl record.
+func (s OutputSchema) ItemSchema() map[string]any {
+	rec := s.Record
+	if rec == nil {
+		rec = s.Item
+	}
+	doc := schemaOf(reflect.TypeOf(rec))
+	doc["$schema"] = jsonSchemaDialect
+	doc["title"] = "pumadevctl " + s.Command + " record"
+	return doc
+}
+
+// CheckDocument reports where data, one -o json document, departs from DocumentSchema.
+func (s OutputSchema) CheckDocument(data []byte) error {
+	return checkJSON(s.DocumentSchema(), data, s.Command)
+}
+
+// CheckItem reports where data, one -o jsonl record, departs from ItemSchema.
+func (s OutputSchema) CheckItem(data []byte) error {
+	return checkJSON(s.ItemSchema(), data, s.Command+" record")
+}
+
+func checkJSON(schema map[string]any, data []byte, path string) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return checkSchema(schema, v, path)
+}
+
+// checkSchema validates v (decoded JSON) against the subset of JSON Schema that schemaOf emits:
+// type, const, properties, required, additionalProperties and items.
+func checkSchema(schema map[string]any, v any, path string) error {
+	if c, ok := schema["const"]; ok {
+		if fmt.Sprint(c) != fmt.Sprint(v) {
+			return fmt.Errorf("%s: want const %v, got %v", path, c, v)
+		}
+	}
+	switch schema["type"] {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s: want object, got %T", path, v)
+		}
+		props, _ := schema["properties"].(map[string]any)
+		if req, ok := schema["required"].([]string); ok {
+			for _, k := range req {
+				if _, ok := obj[k]; !ok {
+					return fmt.Errorf("%s: missing required %q", path, k)
+				}
+			}
+		}
+		for k, fv := range obj {
+			if ps, ok := props[k]; ok {
+				if err := checkSchema(ps.(map[string]any), fv, path+"."+k); err != nil {
+					return err
+				}
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					return fmt.Errorf("%s: unexpected property %q", path, k)
+				}
+			case map[string]any:
+				if err := checkSchema(ap, fv, path+"."+k); err != nil {
+					return err
+				}
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fmt.Errorf("%s: want array, got %T", path, v)
+		}
+		for i, it := range arr {
+			if err := checkSchema(schema["items"].(map[string]any), it, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		if _, ok := v.(string); !ok {
+			return fmt.Errorf("%s: want string, got %T", path, v)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fmt.Errorf("%s: want boolean, got %T", path, v)
+		}
+	case "integer":
+		n, ok := v.(float64)
+		if !ok || n != float64(int64(n)) {
+			return fmt.Errorf("%s: want integer, got %v", path, v)
+		}
+	}
+	return nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf derives a schema from a Go type following encoding/json rules:
+// json tags name properties, omitempty fields are optional, embedded structs are flattened.
+func schemaOf(t reflect.Type) map[string]any {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]any{}
+		required := []string{}
+		collectStructSchema(t, props, &required)
+		return map[string]any{
+			"type":                 "object",
+			"properties":           props,
+			"required":             required,
+			"additionalProperties": false,
+		}
+	}
+	return map[string]any{}
+}
+
+func collectStructSchema(t reflect.Type, props map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				collectStructSchema(ft, props, required)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaOf(f.Type)
+		if !strings.Contains(opts, "omitempty") {
+			*required = append(*required, name)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func renderJSON[T any](t *testing.T, format string, v View[T]) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	r, err := NewRenderer(format, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Render(r, v); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func assertMatchesSchema[T any](t *testing.T, command string, v View[T]) {
+	t.Helper()
+	s, err := LookupOutputSchema(command)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Kind != v.Kind {
+		t.Fatalf("%s: view kind %q does not match schema kind %q", command, v.Kind, s.Kind)
+	}
+	if err := s.CheckDocument(renderJSON(t, "json", v)); err != nil {
+		t.Errorf("document: %v", err)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(renderJSON(t, "jsonl", v)), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if err := s.CheckItem(line); err != nil {
+			t.Errorf("item: %v", err)
+		}
+	}
+}
+
+func TestOutputsMatchSchemas(t *testing.T) {
+	meta := &Meta{Tags: []string{"payments"}, Owner: "pay", Roles: map[string]int{"web": 0, "vite": 1}}
+	entries := []Entry{
+		{Domain: "api", Mapping: "36000", Meta: meta},
+		{Domain: "web", Mapping: "36000"},
+		{Domain: "docs", IsSymlink: true, LinkTarget: "/src/docs"},
+	}
+	now := time.Now()
+	results := []ValidationResult{
+		{Entry: entries[0], Reachable: true, TLS: &TLSResult{Status: TLSOK, Subject: "CN=api.test", DNSNames: []string{"api.test"}, NotAfter: &now, Verified: true, HostnameOK: true}},
+		{Entry: entries[1], Reachable: false, Reason: "connection failed"},
+		{Entry: entries[1], Reachable: true, TLS: &TLSResult{Status: TLSNone}},
+		{Entry: entries[2], Reachable: true},
+	}
+	cert := CertInfo{Domain: "api", Subject: "CN=api.test", DNSNames: []string{"api.test", "*.api.test"}, NotBefore: time.Now(), NotAfter: time.Now(), CertPath: "/x/api.pem", KeyPath: "/x/api-key.pem"}
+
+	assertMatchesSchema(t, "list", ListView(entries))
+	assertMatchesSchema(t, "list", ListView(nil))
+	assertMatchesSchema(t, "read", EntryView(entries[0]))
+	assertMatchesSchema(t, "read", EntryView(entries[2]))
+	assertMatchesSchema(t, "validate", ValidationView(results))
+	assertMatchesSchema(t, "create", EntryChangeView(EntryChange{Domain: "api", Status: "created", Type: "file", Mapping: "36000"}))
+	assertMatchesSchema(t, "delete", EntryChangeView(EntryChange{Domain: "api", Status: "deleted"}))
+	assertMatchesSchema(t, "rename", EntryChangeView(EntryChange{Domain: "b", Status: "renamed", PreviousDomain: "a"}))
+	assertMatchesSchema(t, "cleanup", CleanupView(CleanupResult{DryRun: true, Entries: []CleanupItem{{Entry: entries[1], Status: "pending"}}}))
+	assertMatchesSchema(t, "cleanup", CleanupView(CleanupResult{}))
+	assertMatchesSchema(t, "meta", DomainMetaView(DomainMeta{Domain: "api", Meta: *meta}))
+	assertMatchesSchema(t, "cert issue", CertView([]CertInfo{cert}, true))
+	assertMatchesSchema(t, "cert list", CertView([]CertInfo{cert}, false))
+	lease := Lease{Domain: "api", Dir: "/x", Base: 36000, Size: 10, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	assertMatchesSchema(t, "ports reserve", LeaseView([]Lease{lease}, true))
+	assertMatchesSchema(t, "ports gc", LeaseView(nil, false))
+	assertMatchesSchema(t, "ports compact", CompactView(CompactPlan{Moves: []PortMove{{Domain: "api", From: 36005, To: 36000, Mapping: "36005", NewMapping: "36000", Env: []EnvChange{{Name: "PORT", From: 36005, To: 36000}}}}}))
+	assertMatchesSchema(t, "ports compact", CompactView(CompactPlan{}))
+	assertMatchesSchema(t, "ports map", PortMapView(PortMap{Min: 36000, Max: 36019, BlockSize: 10, Blocks: []PortBlock{{Base: 36000, End: 36009, State: BlockOwned, Domains: []string{"api"}}, {Base: 36010, End: 36019, State: BlockFree}}}))
+	assertMatchesSchema(t, "lint", LintView(LintReport{Errors: 1, Issues: []LintIssue{{Rule: RuleBlockOverlap, Severity: SeverityError, Domains: []string{"a", "b"}, Message: "overlap"}}}))
+	assertMatchesSchema(t, "lint", LintView(LintReport{Issues: []LintIssue{}}))
+	res, _ := ResolveHost(entries, "admin.api.test", "")
+	assertMatchesSchema(t, "resolve", ResolutionView(*res))
+	assertMatchesSchema(t, "open", OpenView(OpenResult{Domain: "api", Host: "admin.api.test", URL: "http://admin.api.test/", Launcher: "xdg-open", Launched: true}))
+	assertMatchesSchema(t, "status", ProjectView(ProjectReport{Project: "/src/api/.pumadev.yml", Dir: "/x", Mode: ModePort, Entries: []ProjectEntry{{Domain: "api", State: StateDrift, Want: "36500", Have: "36000", Detail: "on port 36000"}}}))
+	assertMatchesSchema(t, "config show", ConfigView([]ConfigValue{{Key: "port_min", Value: "40000", Origin: OriginUser, Source: "/home/me/.config/pumadevctl/config.json", Profile: "work"}}, true))
+	assertMatchesSchema(t, "config get", ConfigValueView(ConfigValue{Key: "tld", Value: "test", Origin: OriginDefault}))
+	assertMatchesSchema(t, "config set", ConfigChangeView(ConfigChange{Key: "port_min", Status: "set", Value: "40000", Previous: "36000", File: "/home/me/.config/pumadevctl/config.json"}))
+	assertMatchesSchema(t, "config validate", ConfigReportView(NewConfigReport("", []ConfigIssue{{Key: "port_max", Severity: SeverityError, Message: "30000 is below port_min 36000", Origin: OriginUser}})))
+	assertMatchesSchema(t, "config validate", ConfigReportView(NewConfigReport("", nil)))
+	assertMatchesSchema(t, "config path", ConfigFileView(ConfigFileInfo{Path: "/etc/pumadevctl/config.json", Layer: OriginSystem}))
+	assertMatchesSchema(t, "completion install", CompletionInstallView(CompletionInstall{Shell: "zsh", Path: "/home/u/.local/share/zsh/site-functions/_pumadevctl", Hint: "add fpath"}))
+	assertMatchesSchema(t, "plugin list", PluginView([]Plugin{{Name: "deploy", Path: "/usr/local/bin/pumadevctl-deploy", Status: PluginOK, Hidden: []string{"/usr/bin/pumadevctl-deploy"}}}))
+	assertMatchesSchema(t, "context list", ContextView([]ContextInfo{{Name: "ruby2", Dir: "/home/me/.puma-dev-ruby2", Current: true, Exists: true, Entries: 3}}))
+	assertMatchesSchema(t, "ports", PortAssignmentView([]PortAssignment{{Domain: "api", Role: "web", Port: 36000, Env: "PORT"}}))
+}
+
+func TestSchema_ChecksCatchDrift(t *testing.T) {
+	s, _ := LookupOutputSchema("create")
+	bad := map[string]any{"schema_version": float64(SchemaVersion), "kind": KindEntryChange, "domain": "x"}
+	if err := checkSchema(s.DocumentSchema(), bad, "create"); err == nil {
+		t.Fatalf("expected missing status to fail")
+	}
+	bad["status"] = "created"
+	bad["surprise"] = true
+	if err := checkSchema(s.DocumentSchema(), bad, "create"); err == nil {
+		t.Fatalf("expected unknown property to fail")
+	}
+	if got := s.DocumentSchema()["$schema"]; got != "https://json-schema.org/draft/2020-12/schema" {
+		t.Fatalf("unexpected dialect %v", got)
+	}
+	if !reflect.DeepEqual(schemaOf(reflect.TypeOf(time.Time{})), map[string]any{"type": "string", "format": "date-time"}) {
+		t.Fatalf("time.Time should map to a date-time string")
+	}
+}
@@ -1,18 +1,84 @@
 package internal
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
 	"time"
 )
 
 type ValidationResult struct {
 	Entry
-	Reachable bool   `json:"reachable"`
-	Reason    string `json:"reason,omitempty"`
+	Reachable bool       `json:"reachable"`
+	Reason    string     `json:"reason,omitempty"`
+	TLS       *TLSResult `json:"tls,omitempty"`
+}
+
+// TLS check outcomes.
+const (
+	TLSOK     = "ok"     // verified, matching, not expiring soon
+	TLSNone   = "none"   // the port speaks plain TCP/HTTP; not a failure, most apps leave TLS to puma-dev
+	TLSFailed = "failed" // handshake failed, or the certificate is unverified, for another host or expiring
+)
+
+// TLSResult describes the certificate presented by an entry serving HTTPS directly.
+type TLSResult struct {
+	Status       string     `json:"status"` // ok, none or failed
+	Subject      string     `json:"subject,omitempty"`
+	DNSNames     []string   `json:"dns_names,omitempty"`
+	NotAfter     *time.Time `json:"not_after,omitempty"`
+	Verified     bool       `json:"verified"`
+	VerifyError  string     `json:"verify_error,omitempty"`
+	HostnameOK   bool       `json:"hostname_ok"`
+	ExpiringSoon bool       `json:"expiring_soon,omitempty"`
+	Error        string     `json:"error,omitempty"` // handshake failure
+}
+
+// Healthy reports whether the handshake succeeded with a verified, matching, not-soon-expiring certificate.
+func (t *TLSResult) Healthy() bool {
+	return t.Error == "" && t.Verified && t.HostnameOK && !t.ExpiringSoon
+}
+
+// ValidationFailures counts unreachable non-symlink entries and reachable entries whose TLS check failed.
+// Entries without TLS on their port are not failures.
+func ValidationFailures(results []ValidationResult) (unreachable, tlsIssues int) {
+	for _, r := range results {
+		switch {
+		case r.IsSymlink:
+		case !r.Reachable:
+			unreachable++
+		case r.TLS != nil && r.TLS.Status == TLSFailed:
+			tlsIssues++
+		}
+	}
+	return unreachable, tlsIssues
+}
+
+// ValidateOptions controls ValidateEntriesWith.
+type ValidateOptions struct {
+	TimeoutMs int
+	// TLS performs a TLS handshake against reachable entries and records certificate details.
+	TLS bool
+	// RootCAs verifies presented chains; nil means the system pool.
+	RootCAs *x509.CertPool
+	// ExpiryThreshold flags certificates expiring within this duration.
+	ExpiryThreshold time.Duration
+	// TLD is appended to the domain when checking the certificate hostname (defaults to DefaultTLD).
+	TLD string
 }
 
 // ValidateEntries checks TCP reachability for non-symlink entries
 func ValidateEntries(entries []Entry, timeoutMs int) []ValidationResult {
-	timeout := time.Duration(timeoutMs) * time.Millisecond
+	return ValidateEntriesWith(entries, ValidateOptions{TimeoutMs: timeoutMs})
+}
+
+// ValidateEntriesWith checks TCP reachability and, when opts.TLS is set, the TLS certificate of each entry.
+func ValidateEntriesWith(entries []Entry, opts ValidateOptions) []ValidationResult {
+	timeout := time.Duration(opts.TimeoutMs) * time.Millisecond
 	results := make([]ValidationResult, 0, len(entries))
 	for _, e := range entries {
 		vr := ValidationResult{Entry: e, Reachable: true}
@@ -31,8 +97,77 @@ func ValidateEntries(entries []Entry, timeoutMs int) []ValidationResult {
 		if !IsPortReachable(m.Host, m.Port, timeout) {
 			vr.Reachable = false
 			vr.Reason = "connection failed"
+		} else if opts.TLS {
+			vr.TLS = CheckTLS(e.Domain, m, opts)
 		}
 		results = append(results, vr)
 	}
 	return results
 }
+
+// CheckTLS handshakes with the mapping's address and inspects the leaf certificate.
+// The handshake itself never fails on verification; chain and hostname checks are reported separately.
+// A server answering the handshake with something other than TLS gets status none.
+func CheckTLS(domain string, m *Mapping, opts ValidateOptions) *TLSResult {
+	tld := opts.TLD
+	if tld == "" {
+		tld = DefaultTLD
+	}
+	host := domain + "." + tld
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+	dialer := &net.Dialer{Timeout: time.Duration(opts.TimeoutMs) * time.Millisecond}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true, // verified below so details can be reported either way
+	})
+	var notTLS tls.RecordHeaderError
+	switch {
+	case errors.As(err, &notTLS):
+		return &TLSResult{Status: TLSNone}
+	case err != nil:
+		return &TLSResult{Status: TLSFailed, Error: err.Error()}
+	}
+	defer conn.Close()
+	state := conn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return &TLSResult{Status: TLSFailed, Error: "no certificate presented"}
+	}
+	leaf := state.PeerCertificates[0]
+	notAfter := leaf.NotAfter.UTC()
+	res := &TLSResult{
+		Subject:  leaf.Subject.String(),
+		DNSNames: leaf.DNSNames,
+		NotAfter: &notAfter,
+	}
+	inter := x509.NewCertPool()
+	for _, c := range state.PeerCertificates[1:] {
+		inter.AddCert(c)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{Roots: opts.RootCAs, Intermediates: inter}); err != nil {
+		res.VerifyError = err.Error()
+	} else {
+		res.Verified = true
+	}
+	res.HostnameOK = leaf.VerifyHostname(host) == nil
+	if opts.ExpiryThreshold > 0 && time.Until(leaf.NotAfter) < opts.ExpiryThreshold {
+		res.ExpiringSoon = true
+	}
+	res.Status = TLSFailed
+	if res.Healthy() {
+		res.Status = TLSOK
+	}
+	return res
+}
+
+// LoadCertPool reads PEM certificates from path into a new pool.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateEntriesWith_TLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // the plain reachability dial aborts a handshake
+	srv.StartTLS()
+	defer srv.Close()
+	entries := []Entry{{Domain: "example", Mapping: srv.Listener.Addr().String()}}
+
+	pool := srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	results := ValidateEntriesWith(entries, ValidateOptions{
+		TimeoutMs:       1000,
+		TLS:             true,
+		RootCAs:         pool,
+		ExpiryThreshold: 100 * 365 * 24 * time.Hour, // httptest certs expire in 2084
+		TLD:             "com",
+	})
+	if len(results) != 1 || !results[0].Reachable {
+		t.Fatalf("expected reachable result, got %#v", results)
+	}
+	tr := results[0].TLS
+	if tr == nil || tr.Error != "" {
+		t.Fatalf("expected TLS details, got %#v", tr)
+	}
+	if !tr.Verified {
+		t.Fatalf("expected chain to verify against server CA: %s", tr.VerifyError)
+	}
+	if !tr.HostnameOK {
+		t.Fatalf("expected example.com to match SANs %v", tr.DNSNames)
+	}
+	if !tr.ExpiringSoon || tr.Status != TLSFailed {
+		t.Fatalf("expected expiring_soon (and a failed check) with a 100y threshold (not_after %s)", tr.NotAfter)
+	}
+
+	// Without the test CA the chain does not verify against the system pool.
+	results = ValidateEntriesWith(entries, ValidateOptions{TimeoutMs: 1000, TLS: true, ExpiryThreshold: time.Hour})
+	tr = results[0].TLS
+	if tr == nil || tr.Verified || tr.VerifyError == "" {
+		t.Fatalf("expected verification failure, got %#v", tr)
+	}
+	if tr.HostnameOK || tr.ExpiringSoon {
+		t.Fatalf("unexpected hostname/expiry flags: %#v", tr)
+	}
+}
+
+func TestValidateEntriesWith_TLSHandshakeFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close() // hang up in the middle of the handshake
+		}
+	}()
+	entries := []Entry{{Domain: "broken", Mapping: ln.Addr().String()}}
+	results := ValidateEntriesWith(entries, ValidateOptions{TimeoutMs: 1000, TLS: true})
+	if !results[0].Reachable || results[0].TLS == nil || results[0].TLS.Status != TLSFailed || results[0].TLS.Error == "" {
+		t.Fatalf("expected reachable entry with TLS error, got %#v", results[0])
+	}
+	if _, tlsIssues := ValidationFailures(results); tlsIssues != 1 {
+		t.Errorf("expected the handshake failure to count, got %d", tlsIssues)
+	}
+}
+
+func TestValidateEntriesWith_PlainHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	entries := []Entry{{Domain: "plain", Mapping: srv.Listener.Addr().String()}}
+	results := ValidateEntriesWith(entries, ValidateOptions{TimeoutMs: 1000, TLS: true})
+	if !results[0].Reachable || results[0].TLS == nil || results[0].TLS.Status != TLSNone || results[0].TLS.Error != "" {
+		t.Fatalf("expected a plain HTTP entry to have no TLS, got %#v", results[0].TLS)
+	}
+	if unreachable, tlsIssues := ValidationFailures(results); unreachable != 0 || tlsIssues != 0 {
+		t.Errorf("plain HTTP must not fail validation, got %d unreachable, %d TLS issues", unreachable, tlsIssues)
+	}
+	data, err := json.Marshal(results[0].TLS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "not_after") {
+		t.Errorf("no certificate, so not_after should be omitted: %s", data)
+	}
+}